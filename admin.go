@@ -0,0 +1,517 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrCannotKickSelf  = errors.New("cannot kick yourself")
+	ErrOutOfBounds     = errors.New("position is outside the level")
+	ErrTooManyMobs     = errors.New("too many mobs in the game")
+	ErrUnknownStat     = errors.New("unknown stat")
+	ErrUnknownMobTag   = errors.New("unknown mob type")
+	ErrNoSuchMob       = errors.New("no such mob")
+	ErrPlayerNotInGame = errors.New("player is not in a game")
+)
+
+// Room left in Game.Mobs for mobs spawned after the game starts.  Units hold
+// pointers into Game.Mobs, so the slice must never be reallocated.
+const MobSpawnHeadroom = 64
+
+// Records an administrative action in the system log
+func auditf(sess Session, format string, args ...interface{}) {
+	log.Printf("[audit] %s: %s", sess.UserName(), fmt.Sprintf(format, args...))
+}
+
+func (l *Lobby) Ban(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.banned == nil {
+		l.banned = make(map[string]bool)
+	}
+
+	l.banned[strings.ToLower(name)] = true
+}
+
+func (l *Lobby) Unban(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.banned, strings.ToLower(name))
+}
+
+func (l *Lobby) IsBanned(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.banned[strings.ToLower(name)]
+}
+
+// Removes a session from its game and the lobby, and disconnects it.  The
+// user's linkdead players are removed from their games too.
+func (l *Lobby) Kick(by Session, name string, reason string) error {
+	target := l.FindSession(name)
+
+	// the admin console wraps the administrator's session, so compare names
+	if target != nil && (target == by || target.UserName() == by.UserName()) {
+		return ErrCannotKickSelf
+	}
+
+	removed := l.removeLinkdead(name)
+	if target == nil {
+		if !removed {
+			return fmt.Errorf("%w \"%s\"", ErrNoSuchPlayer, name)
+		}
+
+		return nil
+	}
+
+	msg := "You have been kicked by an administrator"
+	if reason != "" {
+		msg += ": " + reason
+	}
+	target.Message(chat.Admin, msg)
+
+	if g := target.Game(); g != nil {
+		g.PlayerLeave(target)
+	}
+
+	l.RemoveSession(target)
+	target.Quit()
+
+	return nil
+}
+
+// Finds the session's player in its game
+func (l *Lobby) findPlayer(name string) (*Game, *Player, error) {
+	sess := l.FindSession(name)
+	if sess == nil {
+		return nil, nil, fmt.Errorf("%w \"%s\"", ErrNoSuchPlayer, name)
+	}
+
+	g := sess.Game()
+	pl := sess.Player()
+	if g == nil || pl == nil {
+		return nil, nil, fmt.Errorf("%s: %w", sess.UserName(), ErrPlayerNotInGame)
+	}
+
+	return g, pl, nil
+}
+
+func LookupMobTypeByTag(tag string) (MobType, error) {
+	for i := range mobTypes {
+		if mobTypes[i].Tag == tag {
+			return mobTypes[i].Type, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w \"%s\"", ErrUnknownMobTag, tag)
+}
+
+// Moves a player to the nearest open spot to (i,j)
+func (g *Game) TeleportPlayer(pl *Player, i, j int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if i < 0 || j < 0 || i >= g.Level.H || j >= g.Level.W {
+		return ErrOutOfBounds
+	}
+
+	pl.I, pl.J = g.findOpenSpot(i, j)
+	g.pickupItems(pl)
+
+	return nil
+}
+
+// Adds a new mob of the given type near (i,j)
+func (g *Game) SpawnMob(mobType MobType, i, j int) (*Mob, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if i < 0 || j < 0 || i >= g.Level.H || j >= g.Level.W {
+		return nil, ErrOutOfBounds
+	}
+
+	info, err := LookupMobInfo(mobType)
+	if err != nil {
+		return nil, err
+	}
+
+	var weapon Item = BareHands
+	if info.DefaultWeaponTag != "" && LookupItem != nil {
+		if weapon, err = LookupItem(info.DefaultWeaponTag); err != nil {
+			return nil, err
+		}
+	}
+
+	i, j = g.findOpenSpot(i, j)
+	m := Mob{
+		I:          i,
+		J:          j,
+		Stats:      info.Stats,
+		Type:       mobType,
+		MoveTick:   info.MoveRate,
+		Weapon:     weapon,
+		State:      info.InitialState,
+		StateArg:   info.InitialStateArg,
+		Aggression: info.DefaultAggression,
+	}
+	m.Stats.HP = m.Stats.MaxHP
+
+	// reuse the slot of a dead mob before growing the slice
+	for k := range g.Mobs {
+		if !g.Mobs[k].IsAlive() {
+			g.forgetUnit(&g.Mobs[k])
+			g.Mobs[k] = m
+			return &g.Mobs[k], nil
+		}
+	}
+
+	if len(g.Mobs) == cap(g.Mobs) {
+		return nil, ErrTooManyMobs
+	}
+
+	g.Mobs = append(g.Mobs, m)
+	return &g.Mobs[len(g.Mobs)-1], nil
+}
+
+func (g *Game) GiveItem(pl *Player, itm Item) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pl.Inventory = append(pl.Inventory, itm)
+}
+
+// Sets one of a player's stats by name
+func (g *Game) SetStat(pl *Player, stat string, value int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := &pl.Stats
+	switch strings.ToLower(stat) {
+	case "hp":
+		stats.HP = MinInt(value, stats.MaxHP)
+	case "maxhp", "max_hp":
+		stats.MaxHP = value
+		stats.HP = MinInt(stats.HP, value)
+	case "ac", "armor_class":
+		stats.ArmorClass = value
+	case "thac0":
+		stats.THAC0 = value
+	case "xp":
+		stats.XP = value
+		stats.ApplyLevel(LevelForXP(value))
+	case "level":
+		stats.XP = 0
+		if value > 1 && value <= len(ExperienceLevels) {
+			stats.XP = ExperienceLevels[value-1].XP
+		}
+		stats.ApplyLevel(value)
+	default:
+		return fmt.Errorf("%w \"%s\" (hp, maxhp, ac, thac0, xp or level)", ErrUnknownStat, stat)
+	}
+
+	return nil
+}
+
+// Restores a player to full health and removes poison
+func (g *Game) HealPlayer(pl *Player) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if pl.Dead {
+		return
+	}
+
+	pl.Stats.HP = pl.Stats.MaxHP
+	if eff := pl.Effects.Find(StatusPoison); eff != nil {
+		eff.Remaining = 1
+	}
+
+	g.messagef(chat.Admin, "%s is healed by the gods", pl.Name())
+}
+
+func (g *Game) KillPlayer(pl *Player) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if pl.Dead || !pl.IsAlive() {
+		return
+	}
+
+	pl.TakeDamage(pl.Stats.HP, nil)
+	pl.Killer = "the gods"
+}
+
+func (g *Game) KillMob(ind int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if ind < 0 || ind >= len(g.Mobs) || !g.Mobs[ind].IsAlive() {
+		return fmt.Errorf("%w %d", ErrNoSuchMob, ind)
+	}
+
+	mob := &g.Mobs[ind]
+	mob.TakeDamage(mob.Stats.HP, nil)
+	g.messagef(chat.Admin, "%s is struck down by the gods", mob.Name())
+
+	return nil
+}
+
+func parseCoords(si, sj string) (int, int, error) {
+	i, err := strconv.Atoi(si)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid row \"%s\"", ErrBadArguments, si)
+	}
+
+	j, err := strconv.Atoi(sj)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid column \"%s\"", ErrBadArguments, sj)
+	}
+
+	return i, j, nil
+}
+
+func registerAdminCommands(r *CommandRegistry) {
+	r.MustRegister(&Command{
+		Name:       "kick",
+		Args:       []ArgSpec{{Name: "player"}, {Name: "reason", Optional: true, Rest: true}},
+		Help:       "Disconnects a player",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			return ctx.Lobby.Kick(ctx.Session, ctx.Arg("player"), ctx.Arg("reason"))
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "ban",
+		Args:       []ArgSpec{{Name: "player"}, {Name: "reason", Optional: true, Rest: true}},
+		Help:       "Disconnects a player and refuses future logins",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			name := ctx.Arg("player")
+			ctx.Lobby.Ban(name)
+			ctx.Reply(chat.Admin, "%s is banned", name)
+
+			err := ctx.Lobby.Kick(ctx.Session, name, ctx.Arg("reason"))
+			if errors.Is(err, ErrNoSuchPlayer) {
+				return nil
+			}
+
+			return err
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "unban",
+		Args:       []ArgSpec{{Name: "player"}},
+		Help:       "Lifts a ban",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			ctx.Lobby.Unban(ctx.Arg("player"))
+			ctx.Reply(chat.Admin, "%s is no longer banned", ctx.Arg("player"))
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "teleport",
+		Aliases:    []string{"tp"},
+		Args:       []ArgSpec{{Name: "player"}, {Name: "row"}, {Name: "col"}},
+		Help:       "Moves a player to a position",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			i, j, err := parseCoords(ctx.Arg("row"), ctx.Arg("col"))
+			if err != nil {
+				return err
+			}
+
+			g, pl, err := ctx.Lobby.findPlayer(ctx.Arg("player"))
+			if err != nil {
+				return err
+			}
+
+			return g.TeleportPlayer(pl, i, j)
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "spawn",
+		Args:       []ArgSpec{{Name: "mob_tag"}, {Name: "row"}, {Name: "col"}},
+		Help:       "Spawns a mob in your game",
+		Permission: PermAdmin,
+		NeedsGame:  true,
+		Run: func(ctx *CommandContext) error {
+			mt, err := LookupMobTypeByTag(ctx.Arg("mob_tag"))
+			if err != nil {
+				return err
+			}
+
+			i, j, err := parseCoords(ctx.Arg("row"), ctx.Arg("col"))
+			if err != nil {
+				return err
+			}
+
+			mob, err := ctx.Game.SpawnMob(mt, i, j)
+			if err != nil {
+				return err
+			}
+
+			ctx.Reply(chat.Admin, "spawned %s at %d,%d", mob.Name(), mob.I, mob.J)
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "give",
+		Args:       []ArgSpec{{Name: "item_tag"}, {Name: "player", Optional: true}},
+		Help:       "Gives an item to a player (yourself by default)",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if LookupItem == nil {
+				return errors.New("no item database")
+			}
+
+			itm, err := LookupItem(ctx.Arg("item_tag"))
+			if err != nil {
+				return err
+			}
+
+			g, pl := ctx.Game, ctx.Session.Player()
+			if name := ctx.Arg("player"); name != "" {
+				if ctx.Lobby == nil {
+					return ErrNoLobby
+				}
+
+				if g, pl, err = ctx.Lobby.findPlayer(name); err != nil {
+					return err
+				}
+			}
+
+			if g == nil || pl == nil {
+				return ErrNotInGame
+			}
+
+			g.GiveItem(pl, itm)
+			ctx.Reply(chat.Admin, "gave %s to %s", itm.ShortName(), pl.Name())
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "setstat",
+		Args:       []ArgSpec{{Name: "player"}, {Name: "stat"}, {Name: "value"}},
+		Help:       "Sets a player's hp, maxhp, ac, thac0, xp or level",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			value, err := strconv.Atoi(ctx.Arg("value"))
+			if err != nil {
+				return fmt.Errorf("%w: invalid value \"%s\"", ErrBadArguments, ctx.Arg("value"))
+			}
+
+			g, pl, err := ctx.Lobby.findPlayer(ctx.Arg("player"))
+			if err != nil {
+				return err
+			}
+
+			return g.SetStat(pl, ctx.Arg("stat"), value)
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "heal",
+		Args:       []ArgSpec{{Name: "player"}},
+		Help:       "Restores a player to full health",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			g, pl, err := ctx.Lobby.findPlayer(ctx.Arg("player"))
+			if err != nil {
+				return err
+			}
+
+			g.HealPlayer(pl)
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "kill",
+		Args:       []ArgSpec{{Name: "target"}},
+		Help:       "Kills a player, or a mob in your game given as mob:<index> (see /listmobs)",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			target := ctx.Arg("target")
+			if strings.HasPrefix(target, "mob:") {
+				if ctx.Game == nil {
+					return ErrNotInGame
+				}
+
+				ind, err := strconv.Atoi(strings.TrimPrefix(target, "mob:"))
+				if err != nil {
+					return fmt.Errorf("%w: invalid mob index \"%s\"", ErrBadArguments, target)
+				}
+
+				return ctx.Game.KillMob(ind)
+			}
+
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			g, pl, err := ctx.Lobby.findPlayer(target)
+			if err != nil {
+				return err
+			}
+
+			g.KillPlayer(pl)
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "broadcast",
+		Aliases:    []string{"wall"},
+		Args:       []ArgSpec{{Name: "message", Rest: true}},
+		Help:       "Sends a message to everyone connected",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			ctx.Lobby.Broadcast(ctx.Arg("message"))
+			return nil
+		},
+	})
+}
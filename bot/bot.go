@@ -0,0 +1,347 @@
+// Package bot runs headless players.  A Bot is a Session without a UI: each
+// tick it shows a Strategy a View of the game, and carries out the Action
+// the strategy picks.
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown bot strategy")
+	ErrNoSuchBot       = errors.New("no such bot")
+	ErrNameTaken       = errors.New("name is already in use")
+)
+
+// Lines of game log kept by each bot
+const BotLogLines = 64
+
+// Picks a bot's action each tick
+type Strategy interface {
+	// Called once per tick while the bot is in a game
+	Act(v *View) Action
+}
+
+// Makes a strategy for a new bot.  Strategies may use dice for randomness.
+type StrategyFactory func(dice mpnethack.Dice) Strategy
+
+var (
+	strategiesMu sync.Mutex
+	strategies   = map[string]StrategyFactory{}
+)
+
+// Adds a strategy that can be started with /addbot
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	strategies[strings.ToLower(name)] = factory
+}
+
+// Names of the registered strategies, sorted
+func Strategies() []string {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func NewStrategy(name string, dice mpnethack.Dice) (Strategy, error) {
+	strategiesMu.Lock()
+	factory := strategies[strings.ToLower(name)]
+	strategiesMu.Unlock()
+
+	if factory == nil {
+		return nil, fmt.Errorf("%w \"%s\"", ErrUnknownStrategy, name)
+	}
+
+	return factory(dice), nil
+}
+
+// What a bot does on one tick
+type Action struct {
+	Type      mpnethack.ActionType
+	Direction mpnethack.Direction
+	Item      int16
+}
+
+var Wait = Action{Type: mpnethack.Nothing}
+
+func MoveAction(direc mpnethack.Direction) Action {
+	return Action{Type: mpnethack.Move, Direction: direc}
+}
+
+func AttackAction() Action {
+	return Action{Type: mpnethack.Attack}
+}
+
+func DefendAction() Action {
+	return Action{Type: mpnethack.Defend}
+}
+
+func UseAction(item int) Action {
+	return Action{Type: mpnethack.Use, Item: int16(item)}
+}
+
+// Headless player
+type Bot struct {
+	Name         string
+	StrategyName string
+	Lobby        *mpnethack.Lobby
+
+	strategy Strategy
+	log      *chat.Log
+
+	// only used by the game loop, in Update
+	view View
+
+	mu      sync.Mutex
+	g       *mpnethack.Game
+	pl      *mpnethack.Player
+	stopped bool
+}
+
+var _ mpnethack.Session = &Bot{}
+var _ mpnethack.Recordless = &Bot{}
+
+func New(name string, strategyName string, lobby *mpnethack.Lobby) (*Bot, error) {
+	dice, err := mpnethack.NewDice()
+	if err != nil {
+		return nil, err
+	}
+
+	strat, err := NewStrategy(strategyName, dice)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bot{
+		Name:         name,
+		StrategyName: strings.ToLower(strategyName),
+		Lobby:        lobby,
+		strategy:     strat,
+		log:          chat.NewLog(BotLogLines),
+	}, nil
+}
+
+func (b *Bot) IsAdministrator() bool { return false }
+func (b *Bot) UserName() string      { return b.Name }
+func (b *Bot) GetLog() *chat.Log     { return b.log }
+
+// Bots' players aren't saved to the player records
+func (b *Bot) Recordless() bool { return true }
+
+func (b *Bot) HasGame() bool {
+	return b.Game() != nil
+}
+
+func (b *Bot) Game() *mpnethack.Game {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.g
+}
+
+func (b *Bot) Player() *mpnethack.Player {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pl
+}
+
+// Bots can chat and run commands like any other player
+func (b *Bot) ConsoleInput(txt string) {
+	mpnethack.HandleConsoleInput(b, b.Lobby, txt)
+}
+
+func (b *Bot) Message(lvl chat.MsgLevel, txt string) error {
+	b.log.LogLine(lvl, txt)
+	return nil
+}
+
+func (b *Bot) Join(g *mpnethack.Game) error {
+	b.mu.Lock()
+	if b.g != nil {
+		b.mu.Unlock()
+		return mpnethack.ErrAlreadyInGame
+	}
+	b.g = g
+	b.mu.Unlock()
+
+	pl, err := g.PlayerJoin(b)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil {
+		b.g = nil
+		return err
+	}
+
+	b.pl = pl
+	return nil
+}
+
+// Called by the game after every tick
+func (b *Bot) Update() error {
+	// the game calls Player with its own lock held, so don't hold the
+	// bot's lock while calling the game
+	b.mu.Lock()
+	g, pl, stopped := b.g, b.pl, b.stopped
+	b.mu.Unlock()
+
+	if g == nil || pl == nil || stopped {
+		return nil
+	}
+
+	g.RLock()
+	b.view.fill(g, b, pl)
+	g.RUnlock()
+
+	if b.view.Dead {
+		return nil
+	}
+
+	act := b.strategy.Act(&b.view)
+
+	var err error
+	switch act.Type {
+	case mpnethack.Nothing:
+		return nil
+	case mpnethack.Move:
+		err = g.Move(b, act.Direction)
+	default:
+		err = g.UserAction(b, act.Type, act.Item)
+	}
+
+	// strategies may guess wrong about cooldowns; that's not worth a log line
+	if err != nil && !errors.Is(err, mpnethack.ErrOnCooldown) {
+		log.Printf("bot \"%s\": %v", b.Name, err)
+	}
+
+	return nil
+}
+
+// Marks the bot as stopped.  Called when the bot is kicked or removed.
+func (b *Bot) Quit() {
+	b.mu.Lock()
+	b.stopped = true
+	b.mu.Unlock()
+
+	forget(b)
+}
+
+// Takes the bot out of its game and the lobby
+func (b *Bot) Stop() {
+	if g := b.Game(); g != nil {
+		g.PlayerLeave(b)
+	}
+
+	if b.Lobby != nil {
+		b.Lobby.RemoveSession(b)
+	}
+
+	b.Quit()
+}
+
+var (
+	botsMu sync.Mutex
+	bots   = map[string]*Bot{}
+)
+
+// Starts a bot.  The bot joins g, or a new game if g is nil.  If name is
+// empty, the bot is named after its strategy.
+func Start(lobby *mpnethack.Lobby, name string, strategyName string, g *mpnethack.Game) (*Bot, error) {
+	if name == "" {
+		name = freeName(lobby, strategyName)
+	}
+
+	if lobby.FindSession(name) != nil {
+		return nil, fmt.Errorf("%w: \"%s\"", ErrNameTaken, name)
+	}
+
+	b, err := New(name, strategyName, lobby)
+	if err != nil {
+		return nil, err
+	}
+
+	botsMu.Lock()
+	if _, ok := bots[strings.ToLower(name)]; ok {
+		botsMu.Unlock()
+		return nil, fmt.Errorf("%w: \"%s\"", ErrNameTaken, name)
+	}
+	bots[strings.ToLower(name)] = b
+	botsMu.Unlock()
+
+	if g != nil {
+		err = b.Join(g)
+	} else {
+		lobby.AddSession(b)
+		_, err = lobby.NewGame(b)
+	}
+
+	if err != nil {
+		b.Stop()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Finds a running bot by name
+func Find(name string) *Bot {
+	botsMu.Lock()
+	defer botsMu.Unlock()
+
+	return bots[strings.ToLower(name)]
+}
+
+// Running bots, sorted by name
+func Running() []*Bot {
+	botsMu.Lock()
+	list := make([]*Bot, 0, len(bots))
+	for _, b := range bots {
+		list = append(list, b)
+	}
+	botsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+func forget(b *Bot) {
+	botsMu.Lock()
+	defer botsMu.Unlock()
+
+	key := strings.ToLower(b.Name)
+	if bots[key] == b {
+		delete(bots, key)
+	}
+}
+
+// Picks an unused name for a new bot ("wanderer1", "wanderer2", ...)
+func freeName(lobby *mpnethack.Lobby, strategyName string) string {
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s%d", strings.ToLower(strategyName), n)
+		if Find(name) == nil && lobby.FindSession(name) == nil {
+			return name
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/internal/testgame"
+)
+
+func TestViewFill(t *testing.T) {
+	g := testgame.New(t)
+	me := testgame.Join(t, g, "me")
+	other := testgame.Join(t, g, "other")
+
+	var v View
+	g.RLock()
+	v.fill(g, me, me.Player())
+	g.RUnlock()
+
+	if v.Width != 12 || v.Height != 12 {
+		t.Errorf("expected a 12x12 view, found %dx%d", v.Width, v.Height)
+	}
+
+	if v.Self.Name != "me" || v.Self.I != me.Player().I || v.Self.J != me.Player().J || v.Dead {
+		t.Errorf("unexpected self %+v (dead %v)", v.Self, v.Dead)
+	}
+
+	if len(v.Players) != 1 || v.Players[0].Name != "other" || v.Players[0].I != other.Player().I || v.Players[0].J != other.Player().J {
+		t.Errorf("expected to see other at (%d,%d), found %+v", other.Player().I, other.Player().J, v.Players)
+	}
+
+	if len(v.Mobs) != 1 || v.Mobs[0].I != 3 || v.Mobs[0].J != 3 {
+		t.Errorf("expected to see the lemming at (3,3), found %+v", v.Mobs)
+	}
+
+	tests := []struct {
+		i, j     int
+		blocked  bool
+		occupied bool
+	}{
+		{1, 5, true, false},  // wall
+		{0, 0, true, false},  // void
+		{-1, 5, true, false}, // off the map
+		{4, 4, false, false},
+		{3, 3, false, true}, // lemming
+		{other.Player().I, other.Player().J, false, true},
+	}
+
+	for _, tc := range tests {
+		if got := v.Blocked(tc.i, tc.j); got != tc.blocked {
+			t.Errorf("(%d,%d): expected blocked %v, found %v", tc.i, tc.j, tc.blocked, got)
+		}
+
+		if got := v.Occupied(tc.i, tc.j); got != tc.occupied {
+			t.Errorf("(%d,%d): expected occupied %v, found %v", tc.i, tc.j, tc.occupied, got)
+		}
+	}
+}
+
+// Builds a view from a map.  '#' is an obstacle, 'm' a mob, '@' the bot and
+// '$' an item.
+func testView(rows ...string) *View {
+	v := &View{Height: len(rows), Width: len(rows[0])}
+
+	n := v.Width * v.Height
+	v.blocked = make([]bool, n)
+	v.occupied = make([]bool, n)
+	v.prev = make([]int, n)
+
+	for i, row := range rows {
+		for j, ch := range row {
+			switch ch {
+			case '#':
+				v.blocked[i*v.Width+j] = true
+			case 'm':
+				v.Mobs = append(v.Mobs, UnitView{Name: "lemming", I: i, J: j})
+				v.occupy(i, j)
+			case '@':
+				v.Self = UnitView{Name: "bot", I: i, J: j, Facing: mpnethack.Up, HP: 10, MaxHP: 10}
+			case '$':
+				v.Items = append(v.Items, ItemView{I: i, J: j, Name: "potion"})
+			}
+		}
+	}
+
+	return v
+}
+
+func TestPath(t *testing.T) {
+	maze := []string{
+		"#######",
+		"#@..#.#",
+		"#.#.#.#",
+		"#.#...#",
+		"#######",
+	}
+
+	at := func(i0, j0 int) func(i, j int) bool {
+		return func(i, j int) bool { return i == i0 && j == j0 }
+	}
+
+	tests := []struct {
+		rows  []string
+		goal  func(i, j int) bool
+		direc mpnethack.Direction
+	}{
+		{maze, at(1, 5), mpnethack.Right},
+		{maze, at(3, 1), mpnethack.Down},
+		{maze, at(0, 0), mpnethack.NoDirection},
+		{maze, func(i, j int) bool { return false }, mpnethack.NoDirection},
+
+		// mobs block the way, but can be the goal
+		{[]string{"#######", "#@.m..#", "#######"}, at(1, 5), mpnethack.NoDirection},
+		{[]string{"#######", "#@.m..#", "#######"}, at(1, 3), mpnethack.Right},
+	}
+
+	for k, tc := range tests {
+		v := testView(tc.rows...)
+		if got := v.Path(tc.goal); got != tc.direc {
+			t.Errorf("case %d: expected %v but found %v", k, tc.direc, got)
+		}
+	}
+}
+
+func TestWalkable(t *testing.T) {
+	v := testView(
+		"#####",
+		"#@.m#",
+		"#####",
+	)
+
+	tests := []struct {
+		i, j     int
+		walkable bool
+	}{
+		{1, 2, true},
+		{1, 3, false}, // mob
+		{0, 2, false}, // wall
+		{1, 5, false}, // off the map
+	}
+
+	for _, tc := range tests {
+		if got := v.Walkable(tc.i, tc.j); got != tc.walkable {
+			t.Errorf("(%d,%d): expected walkable %v, found %v", tc.i, tc.j, tc.walkable, got)
+		}
+	}
+
+	if got := v.Toward(1, 2); got != mpnethack.Right {
+		t.Errorf("expected (1,2) to be right of the bot, found %v", got)
+	}
+
+	if got := v.Toward(1, 3); got != mpnethack.NoDirection {
+		t.Errorf("expected (1,3) not to be adjacent, found %v", got)
+	}
+}
+
+func TestWanderer(t *testing.T) {
+	w := NewWanderer(mpnethack.NewDiceFromSeed(7))
+
+	// a dead end: the only way out is right
+	v := testView(
+		"#####",
+		"#@..#",
+		"#####",
+	)
+
+	moved := false
+	for k := 0; k < 20; k++ {
+		switch act := w.Act(v); act {
+		case MoveAction(mpnethack.Right):
+			moved = true
+		case Wait:
+		default:
+			t.Fatalf("expected the wanderer to move right or wait, found %+v", act)
+		}
+	}
+
+	if !moved {
+		t.Errorf("expected the wanderer to move right at least once")
+	}
+
+	v.Cooldowns = mpnethack.Cooldowns{mpnethack.Move: 5}
+	if act := w.Act(v); act != Wait {
+		t.Errorf("expected the wanderer to wait out its move cooldown, found %+v", act)
+	}
+}
+
+func TestFighter(t *testing.T) {
+	f := NewFighter(mpnethack.NewDiceFromSeed(7))
+
+	// turns to face an adjacent mob, then attacks
+	v := testView(
+		"#####",
+		"#@m.#",
+		"#####",
+	)
+
+	if act := f.Act(v); act != MoveAction(mpnethack.Right) {
+		t.Errorf("expected the fighter to face the mob, found %+v", act)
+	}
+
+	v.Self.Facing = mpnethack.Right
+	if act := f.Act(v); act != AttackAction() {
+		t.Errorf("expected the fighter to attack, found %+v", act)
+	}
+
+	v.Self.HP = 1
+	if act := f.Act(v); act != DefendAction() {
+		t.Errorf("expected a hurt fighter to defend, found %+v", act)
+	}
+
+	// walks around the wall to reach a mob
+	v = testView(
+		"#####",
+		"#@#m#",
+		"#...#",
+		"#####",
+	)
+
+	if act := f.Act(v); act != MoveAction(mpnethack.Down) {
+		t.Errorf("expected the fighter to head for the mob, found %+v", act)
+	}
+}
+
+func TestExplorer(t *testing.T) {
+	// heads for tiles it hasn't seen
+	e := NewExplorer(mpnethack.NewDiceFromSeed(7))
+	v := testView(
+		"############",
+		"#@.........#",
+		"############",
+	)
+
+	if act := e.Act(v); act != MoveAction(mpnethack.Right) {
+		t.Errorf("expected the explorer to head for unseen tiles, found %+v", act)
+	}
+
+	// picks up nearby items before exploring further
+	e = NewExplorer(mpnethack.NewDiceFromSeed(7))
+	v = testView(
+		"############",
+		"#$.@.......#",
+		"############",
+	)
+
+	if act := e.Act(v); act != MoveAction(mpnethack.Left) {
+		t.Errorf("expected the explorer to head for the item, found %+v", act)
+	}
+
+	// wanders once there's nothing left to see
+	e = NewExplorer(mpnethack.NewDiceFromSeed(7))
+	v = testView(
+		"#####",
+		"#@..#",
+		"#####",
+	)
+
+	for k := 0; k < 5; k++ {
+		if act := e.Act(v); act != Wait && act != MoveAction(mpnethack.Right) {
+			t.Fatalf("expected the explorer to wander, found %+v", act)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+func init() {
+	registerBotCommands(mpnethack.Commands)
+}
+
+func registerBotCommands(r *mpnethack.CommandRegistry) {
+	r.MustRegister(&mpnethack.Command{
+		Name:       "addbot",
+		Args:       []mpnethack.ArgSpec{{Name: "strategy"}, {Name: "name", Optional: true}},
+		Help:       "Adds a bot to your game, or starts it in a new game",
+		Permission: mpnethack.PermAdmin,
+		Run: func(ctx *mpnethack.CommandContext) error {
+			if ctx.Lobby == nil {
+				return mpnethack.ErrNoLobby
+			}
+
+			b, err := Start(ctx.Lobby, ctx.Arg("name"), ctx.Arg("strategy"), ctx.Game)
+			if errors.Is(err, ErrUnknownStrategy) {
+				return fmt.Errorf("%w; strategies: %s", err, strings.Join(Strategies(), ", "))
+			} else if err != nil {
+				return err
+			}
+
+			ctx.Reply(chat.Admin, "Started %s bot %s", b.StrategyName, b.Name)
+			return nil
+		},
+	})
+
+	r.MustRegister(&mpnethack.Command{
+		Name:       "removebot",
+		Args:       []mpnethack.ArgSpec{{Name: "name"}},
+		Help:       "Removes a bot",
+		Permission: mpnethack.PermAdmin,
+		Run: func(ctx *mpnethack.CommandContext) error {
+			b := Find(ctx.Arg("name"))
+			if b == nil {
+				return fmt.Errorf("%w \"%s\"", ErrNoSuchBot, ctx.Arg("name"))
+			}
+
+			b.Stop()
+			ctx.Reply(chat.Admin, "Removed bot %s", b.Name)
+			return nil
+		},
+	})
+
+	r.MustRegister(&mpnethack.Command{
+		Name:       "bots",
+		Help:       "Lists running bots and bot strategies",
+		Permission: mpnethack.PermAdmin,
+		Run: func(ctx *mpnethack.CommandContext) error {
+			running := Running()
+			if len(running) == 0 {
+				ctx.Reply(chat.Info, "No bots are running")
+			}
+
+			for _, b := range running {
+				where := "lobby"
+				if g := b.Game(); g != nil {
+					where = "game " + g.LevelName
+					if g == ctx.Game {
+						where += " (yours)"
+					}
+				}
+
+				ctx.Reply(chat.Info, "  %s: %s, %s", b.Name, b.StrategyName, where)
+			}
+
+			ctx.Reply(chat.Info, "Strategies: %s", strings.Join(Strategies(), ", "))
+			return nil
+		},
+	})
+}
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"github.com/sfstewman/mpnethack"
+)
+
+func init() {
+	RegisterStrategy("wanderer", NewWanderer)
+	RegisterStrategy("fighter", NewFighter)
+	RegisterStrategy("explorer", NewExplorer)
+}
+
+// Chance, out of WandererTurnOdds, that a wanderer turns on any step
+const WandererTurnOdds = 8
+
+// Health fraction below which a fighter backs off and defends
+const FighterRetreatFraction = 4
+
+// How far an explorer sees the tiles around it
+const ExplorerSightRadius = 3
+
+// Walks in a straight line, turning at random and when blocked
+type Wanderer struct {
+	dice  mpnethack.Dice
+	direc mpnethack.Direction
+}
+
+func NewWanderer(dice mpnethack.Dice) Strategy {
+	return &Wanderer{dice: dice}
+}
+
+func (w *Wanderer) Act(v *View) Action {
+	if !v.Ready(mpnethack.Move) {
+		return Wait
+	}
+
+	if w.direc == mpnethack.NoDirection || w.dice.Roll1dN(WandererTurnOdds) == 1 {
+		w.direc = mpnethack.RollDirection(w.dice)
+	}
+
+	// try each direction once, starting with the current one
+	for tries := 0; tries < len(directions); tries++ {
+		di, dj, _, _ := w.direc.Vectors()
+		if v.Walkable(v.Self.I+di, v.Self.J+dj) {
+			return MoveAction(w.direc)
+		}
+
+		w.direc = mpnethack.RollDirection(w.dice)
+	}
+
+	return Wait
+}
+
+// Hunts the nearest mob and attacks it.  Falls back to wandering when no
+// mobs are left.
+type Fighter struct {
+	wander Wanderer
+}
+
+func NewFighter(dice mpnethack.Dice) Strategy {
+	return &Fighter{wander: Wanderer{dice: dice}}
+}
+
+func (f *Fighter) Act(v *View) Action {
+	target, ok := nearest(v, v.Mobs)
+	if !ok {
+		return f.wander.Act(v)
+	}
+
+	if v.Self.HP*FighterRetreatFraction < v.Self.MaxHP && v.Ready(mpnethack.Defend) {
+		return DefendAction()
+	}
+
+	// face the target, then swing
+	if direc := v.Toward(target.I, target.J); direc != mpnethack.NoDirection {
+		if v.Self.Facing != direc {
+			if v.Ready(mpnethack.Move) {
+				return MoveAction(direc)
+			}
+
+			return Wait
+		}
+
+		if v.Ready(mpnethack.Attack) {
+			return AttackAction()
+		}
+
+		return Wait
+	}
+
+	if !v.Ready(mpnethack.Move) {
+		return Wait
+	}
+
+	direc := v.Path(func(i, j int) bool {
+		return i == target.I && j == target.J
+	})
+	if direc == mpnethack.NoDirection {
+		return f.wander.Act(v)
+	}
+
+	return MoveAction(direc)
+}
+
+func nearest(v *View, units []UnitView) (UnitView, bool) {
+	best, bestDist := UnitView{}, -1
+	for _, u := range units {
+		if d := Distance(v.Self.I, v.Self.J, u.I, u.J); bestDist < 0 || d < bestDist {
+			best, bestDist = u, d
+		}
+	}
+
+	return best, bestDist >= 0
+}
+
+// Heads for the nearest tile it hasn't seen, picking up items on the way.
+// Wanders once everything reachable has been seen.
+type Explorer struct {
+	wander Wanderer
+
+	width, height int
+	seen          []bool
+}
+
+func NewExplorer(dice mpnethack.Dice) Strategy {
+	return &Explorer{wander: Wanderer{dice: dice}}
+}
+
+func (e *Explorer) Act(v *View) Action {
+	if e.width != v.Width || e.height != v.Height {
+		e.width, e.height = v.Width, v.Height
+		e.seen = make([]bool, v.Width*v.Height)
+	}
+
+	r := ExplorerSightRadius
+	for i := v.Self.I - r; i <= v.Self.I+r; i++ {
+		for j := v.Self.J - r; j <= v.Self.J+r; j++ {
+			if v.InBounds(i, j) {
+				e.seen[i*v.Width+j] = true
+			}
+		}
+	}
+
+	if !v.Ready(mpnethack.Move) {
+		return Wait
+	}
+
+	direc := v.Path(func(i, j int) bool {
+		return v.Walkable(i, j) && (!e.seen[i*v.Width+j] || hasItem(v, i, j))
+	})
+	if direc == mpnethack.NoDirection {
+		return e.wander.Act(v)
+	}
+
+	return MoveAction(direc)
+}
+
+func hasItem(v *View, i, j int) bool {
+	for _, itm := range v.Items {
+		if itm.I == i && itm.J == j {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"github.com/sfstewman/mpnethack"
+)
+
+// A unit as a bot sees it
+type UnitView struct {
+	Name   string
+	Marker rune
+	I, J   int
+	Facing mpnethack.Direction
+
+	HP, MaxHP int
+}
+
+type ItemView struct {
+	I, J int
+	Name string
+}
+
+// A bot's view of the game, refreshed every tick
+type View struct {
+	Frame         uint64
+	Width, Height int
+
+	Self      UnitView
+	Dead      bool
+	Cooldowns mpnethack.Cooldowns
+	Inventory []string
+
+	// Living units other than the bot
+	Players []UnitView
+	Mobs    []UnitView
+
+	Items []ItemView
+
+	// tiles that can't be walked on, and tiles with units on them
+	blocked  []bool
+	occupied []bool
+
+	// scratch space for Path
+	prev []int
+}
+
+var directions = [...]mpnethack.Direction{
+	mpnethack.Up, mpnethack.Right, mpnethack.Down, mpnethack.Left,
+}
+
+// Assumes the game's read lock is held
+func (v *View) fill(g *mpnethack.Game, sess mpnethack.Session, pl *mpnethack.Player) {
+	lvl := g.Level
+
+	v.Frame = g.FrameNum
+	v.Width, v.Height = lvl.W, lvl.H
+
+	v.Self = UnitView{
+		Name:   sess.UserName(),
+		Marker: pl.Marker,
+		I:      pl.I,
+		J:      pl.J,
+		Facing: pl.Facing,
+		HP:     pl.Stats.HP,
+		MaxHP:  pl.Stats.MaxHP,
+	}
+	v.Dead = pl.Dead || !pl.IsAlive()
+	v.Cooldowns = g.GetCooldowns(sess, v.Cooldowns)
+
+	v.Inventory = v.Inventory[:0]
+	for _, itm := range pl.Inventory {
+		v.Inventory = append(v.Inventory, itm.ShortName())
+	}
+
+	n := lvl.W * lvl.H
+	if len(v.blocked) != n {
+		v.blocked = make([]bool, n)
+		v.occupied = make([]bool, n)
+		v.prev = make([]int, n)
+	}
+
+	for i := 0; i < lvl.H; i++ {
+		for j := 0; j < lvl.W; j++ {
+			v.blocked[i*lvl.W+j] = lvl.Get(i, j) != mpnethack.MarkerEmpty
+			v.occupied[i*lvl.W+j] = false
+		}
+	}
+
+	v.Players = v.Players[:0]
+	for _, other := range g.Players {
+		if other == pl || other.Dead || !other.IsAlive() {
+			continue
+		}
+
+		v.Players = append(v.Players, UnitView{
+			Name:   other.Name(),
+			Marker: other.Marker,
+			I:      other.I,
+			J:      other.J,
+			Facing: other.Facing,
+			HP:     other.Stats.HP,
+			MaxHP:  other.Stats.MaxHP,
+		})
+		v.occupy(other.I, other.J)
+	}
+
+	v.Mobs = v.Mobs[:0]
+	for i := range g.Mobs {
+		mob := &g.Mobs[i]
+		if !mob.IsAlive() {
+			continue
+		}
+
+		var marker rune
+		if info, err := mpnethack.LookupMobInfo(mob.Type); err == nil {
+			marker = info.Marker
+		}
+
+		v.Mobs = append(v.Mobs, UnitView{
+			Name:   mob.Name(),
+			Marker: marker,
+			I:      mob.I,
+			J:      mob.J,
+			Facing: mob.Direc,
+			HP:     mob.Stats.HP,
+			MaxHP:  mob.Stats.MaxHP,
+		})
+		v.occupy(mob.I, mob.J)
+	}
+
+	v.Items = v.Items[:0]
+	for _, fi := range g.FloorItems {
+		v.Items = append(v.Items, ItemView{I: fi.I, J: fi.J, Name: fi.Item.ShortName()})
+	}
+}
+
+func (v *View) occupy(i, j int) {
+	if v.InBounds(i, j) {
+		v.occupied[i*v.Width+j] = true
+	}
+}
+
+func (v *View) InBounds(i, j int) bool {
+	return i >= 0 && j >= 0 && i < v.Height && j < v.Width
+}
+
+// Reports whether the tile is a wall, cactus or other obstacle.  Walking
+// into some obstacles hurts.
+func (v *View) Blocked(i, j int) bool {
+	return !v.InBounds(i, j) || v.blocked[i*v.Width+j]
+}
+
+// Reports whether a unit is standing on the tile
+func (v *View) Occupied(i, j int) bool {
+	return v.InBounds(i, j) && v.occupied[i*v.Width+j]
+}
+
+// Reports whether the bot could step onto the tile
+func (v *View) Walkable(i, j int) bool {
+	return !v.Blocked(i, j) && !v.Occupied(i, j)
+}
+
+// Reports whether the action is off cooldown
+func (v *View) Ready(act mpnethack.ActionType) bool {
+	return int(act) >= len(v.Cooldowns) || v.Cooldowns[act] == 0
+}
+
+// Direction from the bot to an adjacent tile, or NoDirection if the tile
+// isn't adjacent
+func (v *View) Toward(i, j int) mpnethack.Direction {
+	for _, d := range directions {
+		di, dj, _, _ := d.Vectors()
+		if v.Self.I+di == i && v.Self.J+dj == j {
+			return d
+		}
+	}
+
+	return mpnethack.NoDirection
+}
+
+func Distance(i0, j0, i1, j1 int) int {
+	di, dj := i1-i0, j1-j0
+	if di < 0 {
+		di = -di
+	}
+	if dj < 0 {
+		dj = -dj
+	}
+
+	return di + dj
+}
+
+// First step along a shortest walkable path from the bot to the first tile
+// that goal accepts.  The goal tile itself may be occupied, so a bot can path
+// to a unit.  Returns NoDirection if no tile can be reached.
+func (v *View) Path(goal func(i, j int) bool) mpnethack.Direction {
+	if !v.InBounds(v.Self.I, v.Self.J) {
+		return mpnethack.NoDirection
+	}
+
+	for k := range v.prev {
+		v.prev[k] = -1
+	}
+
+	start := v.Self.I*v.Width + v.Self.J
+	v.prev[start] = start
+
+	queue := []int{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		ci, cj := cur/v.Width, cur%v.Width
+		if cur != start && goal(ci, cj) {
+			return v.firstStep(start, cur)
+		}
+
+		// units block the path, except at the goal
+		if cur != start && v.Occupied(ci, cj) {
+			continue
+		}
+
+		for _, d := range directions {
+			di, dj, _, _ := d.Vectors()
+			ni, nj := ci+di, cj+dj
+			if v.Blocked(ni, nj) {
+				continue
+			}
+
+			next := ni*v.Width + nj
+			if v.prev[next] >= 0 {
+				continue
+			}
+
+			v.prev[next] = cur
+			queue = append(queue, next)
+		}
+	}
+
+	return mpnethack.NoDirection
+}
+
+func (v *View) firstStep(start, end int) mpnethack.Direction {
+	cur := end
+	for v.prev[cur] != start {
+		cur = v.prev[cur]
+	}
+
+	return v.Toward(cur/v.Width, cur%v.Width)
+}
@@ -23,6 +23,27 @@ const (
 	// MsgAdmin
 )
 
+func (lvl MsgLevel) String() string {
+	switch lvl {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Chat:
+		return "chat"
+	case Private:
+		return "private"
+	case Game:
+		return "game"
+	case Admin:
+		return "admin"
+	case System:
+		return "system"
+	default:
+		return fmt.Sprintf("level_%d", int(lvl))
+	}
+}
+
 type Message struct {
 	Level MsgLevel
 	Time  time.Time
@@ -92,6 +113,14 @@ func (gl *Log) Log(lvl MsgLevel, format string, args ...interface{}) {
 	}
 }
 
+// Sequence number the next line will get
+func (gl *Log) NextSeq() uint {
+	gl.mu.RLock()
+	defer gl.mu.RUnlock()
+
+	return gl.Seq
+}
+
 func (gl *Log) NumLines() int {
 	gl.mu.RLock()
 	defer gl.mu.RUnlock()
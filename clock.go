@@ -0,0 +1,252 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+const (
+	// Limits on the time between game ticks
+	MinTickInterval time.Duration = 10 * time.Millisecond
+	MaxTickInterval time.Duration = 5 * time.Second
+
+	// Most ticks that can be stepped at once
+	MaxStepTicks = 1000
+)
+
+var (
+	ErrBadTickInterval = errors.New("tick interval out of range")
+	ErrBadStepCount    = errors.New("step count out of range")
+)
+
+// State of a game's clock
+type ClockState struct {
+	Paused bool
+	Frame  uint64
+
+	// Ticks left to run while paused
+	Steps int
+
+	// Time between ticks
+	Interval time.Duration
+}
+
+// Status bar label for the clock, or "" if the clock is running normally
+func (c ClockState) Label() string {
+	var parts []string
+	if c.Paused {
+		if c.Steps > 0 {
+			parts = append(parts, fmt.Sprintf("STEP %d", c.Steps))
+		} else {
+			parts = append(parts, "PAUSED")
+		}
+	}
+
+	if c.Interval != 0 && c.Interval != GameRefreshInterval {
+		parts = append(parts, fmt.Sprintf("TICK %v", c.Interval))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (g *Game) Clock() ClockState {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.clock()
+}
+
+// Assumes the lock is held (either read or write)
+func (g *Game) clock() ClockState {
+	interval := g.tickInterval
+	if interval == 0 {
+		interval = GameRefreshInterval
+	}
+
+	return ClockState{
+		Paused:   g.paused,
+		Frame:    g.FrameNum,
+		Steps:    g.stepTicks,
+		Interval: interval,
+	}
+}
+
+// Stops the game clock.  Player input is queued until the game resumes or is
+// stepped.
+func (g *Game) Pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		return
+	}
+
+	g.paused = true
+	g.stepTicks = 0
+	g.messagef(chat.Info, "Game paused at frame %d", g.FrameNum)
+}
+
+func (g *Game) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		return
+	}
+
+	g.paused = false
+	g.stepTicks = 0
+	g.messagef(chat.Info, "Game resumed at frame %d", g.FrameNum)
+}
+
+// Pauses the game, if it isn't already paused, and runs n more ticks
+func (g *Game) Step(n int) error {
+	if n < 1 || n > MaxStepTicks {
+		return fmt.Errorf("%w: %d (must be 1 to %d)", ErrBadStepCount, n, MaxStepTicks)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.paused = true
+	g.stepTicks += n
+	if g.stepTicks > MaxStepTicks {
+		g.stepTicks = MaxStepTicks
+	}
+
+	return nil
+}
+
+// Changes the time between game ticks.  An interval of zero restores the
+// default.
+func (g *Game) SetTickInterval(d time.Duration) error {
+	if d == 0 {
+		d = GameRefreshInterval
+	}
+
+	if d < MinTickInterval || d > MaxTickInterval {
+		return fmt.Errorf("%w: %v (must be %v to %v)", ErrBadTickInterval, d, MinTickInterval, MaxTickInterval)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.tickInterval = d
+	g.pump.Reset(d)
+	g.messagef(chat.Info, "Tick interval is now %v", d)
+
+	return nil
+}
+
+// Reports whether the game loop should run a tick, and uses up a step if the
+// game is paused
+func (g *Game) takeTick() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		return true
+	}
+
+	if g.stepTicks > 0 {
+		g.stepTicks--
+		return true
+	}
+
+	return false
+}
+
+func registerClockCommands(r *CommandRegistry) {
+	r.MustRegister(&Command{
+		Name:       "pause",
+		Help:       "Pauses the game",
+		Permission: PermAdmin,
+		NeedsGame:  true,
+		Run: func(ctx *CommandContext) error {
+			ctx.Game.Pause()
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "resume",
+		Aliases:    []string{"unpause"},
+		Help:       "Resumes a paused game",
+		Permission: PermAdmin,
+		NeedsGame:  true,
+		Run: func(ctx *CommandContext) error {
+			ctx.Game.Resume()
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "step",
+		Args:       []ArgSpec{{Name: "ticks", Optional: true}},
+		Help:       "Pauses the game and advances it by some ticks (default 1)",
+		Permission: PermAdmin,
+		NeedsGame:  true,
+		Run: func(ctx *CommandContext) error {
+			n := 1
+			if arg := ctx.Arg("ticks"); arg != "" {
+				var err error
+				if n, err = strconv.Atoi(arg); err != nil {
+					return fmt.Errorf("%w: invalid tick count \"%s\"", ErrBadArguments, arg)
+				}
+			}
+
+			return ctx.Game.Step(n)
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "tickrate",
+		Args:       []ArgSpec{{Name: "interval", Optional: true}},
+		Help:       "Shows or sets the time between ticks (e.g. 250ms, or \"default\")",
+		Permission: PermAdmin,
+		NeedsGame:  true,
+		Run: func(ctx *CommandContext) error {
+			arg := ctx.Arg("interval")
+			if arg == "" {
+				c := ctx.Game.Clock()
+				ctx.Reply(chat.Info, "Tick interval is %v, frame %d", c.Interval, c.Frame)
+				if c.Paused {
+					ctx.Reply(chat.Info, "Game is paused (%d steps left)", c.Steps)
+				}
+
+				return nil
+			}
+
+			d, err := parseTickInterval(arg)
+			if err != nil {
+				return err
+			}
+
+			return ctx.Game.SetTickInterval(d)
+		},
+	})
+}
+
+// Parses a tick interval.  Bare numbers are milliseconds, and "default"
+// means the default interval.
+func parseTickInterval(s string) (time.Duration, error) {
+	if strings.EqualFold(s, "default") {
+		return 0, nil
+	}
+
+	if ms, err := strconv.Atoi(s); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid interval \"%s\"", ErrBadArguments, s)
+	}
+
+	return d, nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/sfstewman/mpnethack"
+	_ "github.com/sfstewman/mpnethack/bot" // registers the bot commands
 	"github.com/sfstewman/mpnethack/chat"
 	"github.com/sfstewman/mpnethack/network"
 	"github.com/sfstewman/mpnethack/store"
@@ -16,14 +17,44 @@ const ConsoleFlags = user.Authenticated | user.Administrator
 
 func main() {
 	var (
-		hostKeyPath  string
-		adminLogPath string
-		err          error
+		hostKeyPath   string
+		adminLogPath  string
+		replayPath    string
+		restorePath   string
+		telnetAddr    string
+		webAddr       string
+		jsonAddr      string
+		adminKeysPath string
+		recordReplays bool
+		err           error
 	)
 	var storePath string = "store.db"
 
+	rules := mpnethack.DefaultGameRules
+
 	flag.StringVar(&hostKeyPath, "hostkey", "", "Path to the host key")
 	flag.StringVar(&adminLogPath, "adminlog", "admin.log", "Path to the admin log")
+	flag.Func("pvp", "Player-versus-player policy: off, duel or free_for_all", func(s string) error {
+		return rules.PvP.UnmarshalText([]byte(s))
+	})
+	flag.BoolVar(&rules.SpectatorChat, "spectator-chat", false, "Let spectators chat with players")
+	flag.BoolVar(&recordReplays, "record", false, "Record games so admins can save replays with /replay")
+	flag.StringVar(&replayPath, "replay", "", "Rerun a saved replay, check that it matches, and exit")
+	flag.StringVar(&mpnethack.ReplayDir, "replay-dir", mpnethack.ReplayDir, "Directory /replay saves replays in")
+	flag.StringVar(&telnetAddr, "telnet", "", "Address to listen on for telnet logins (e.g. localhost:5613)")
+	flag.StringVar(&webAddr, "web", "", "Address to serve the web client on (e.g. localhost:8080)")
+	flag.StringVar(&jsonAddr, "json", "", "Address to listen on for JSON protocol clients (e.g. localhost:5614)")
+	flag.StringVar(&restorePath, "restore", "", "Restore a game saved with /save")
+	flag.StringVar(&mpnethack.SnapshotDir, "snapshot-dir", mpnethack.SnapshotDir, "Directory /save and /restore keep snapshots in")
+	flag.StringVar(&adminKeysPath, "admin-keys", "", "authorized_keys file of ssh keys that log in as administrators")
+
+	limits := &network.ConnectionLimits
+	flag.IntVar(&limits.MaxConnections, "max-conns", limits.MaxConnections, "Most connections open at once (0 for no limit)")
+	flag.IntVar(&limits.MaxConnectionsPerIP, "max-conns-per-ip", limits.MaxConnectionsPerIP, "Most connections open at once from one address (0 for no limit)")
+	flag.DurationVar(&limits.HandshakeTimeout, "handshake-timeout", limits.HandshakeTimeout, "Time a connection has to log in (0 for no limit)")
+	flag.DurationVar(&limits.IdleTimeout, "idle-timeout", limits.IdleTimeout, "Time before an idle connection is dropped (0 for no limit)")
+	flag.Float64Var(&limits.InputRate, "input-rate", limits.InputRate, "Actions and chat lines per second a session may send (0 for no limit)")
+	flag.IntVar(&limits.InputBurst, "input-burst", limits.InputBurst, "Actions and chat lines a session may send in a burst")
 	flag.Parse()
 
 	db, err := store.Open(storePath)
@@ -42,18 +73,69 @@ func main() {
 	}
 
 	mpnethack.LookupItem = db.LookupItem
+	mpnethack.LookupPlayerRecord = db.LookupPlayer
+	mpnethack.SavePlayerRecord = db.SavePlayer
+	mpnethack.ListPlayerRecords = db.ListPlayers
+
+	if replayPath != "" {
+		checkReplay(replayPath)
+		return
+	}
+
+	lobby := &mpnethack.Lobby{Rules: &rules, RecordReplays: recordReplays}
 
-	lobby := &mpnethack.Lobby{}
+	if restorePath != "" {
+		if _, err := lobby.RestoreGame(restorePath); err != nil {
+			log.Fatalf("error restoring game \"%s\": %v", restorePath, err)
+		}
+	}
 
 	session := user.NewSession("Asron the Limited", ConsoleFlags)
+	session.Lobby = lobby
 	lobby.AddSession(session)
 	session.UI = tui.SetupUI(session, lobby, systemLog)
+	lobby.OnShutdown = session.UI.App.Stop
+
+	if adminKeysPath != "" {
+		if err := network.LoadAdminKeys(adminKeysPath); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
 
 	if hostKeyPath != "" {
 		go network.AcceptNetworkLogins(hostKeyPath, lobby, systemLog)
 	}
 
+	if telnetAddr != "" {
+		go network.AcceptTelnetLogins(telnetAddr, lobby, systemLog)
+	}
+
+	if webAddr != "" {
+		go network.AcceptWebLogins(webAddr, lobby, systemLog)
+	}
+
+	if jsonAddr != "" {
+		go network.AcceptJSONLogins(jsonAddr, lobby)
+	}
+
 	if err := session.UI.Run(); err != nil {
 		panic(err)
 	}
+
+	if err := db.Close(); err != nil {
+		log.Printf("error closing store: %v", err)
+	}
+}
+
+func checkReplay(path string) {
+	replay, err := mpnethack.LoadReplay(path)
+	if err != nil {
+		log.Fatalf("error loading replay \"%s\": %v", path, err)
+	}
+
+	if _, err := mpnethack.RunReplay(replay); err != nil {
+		log.Fatalf("replay \"%s\" failed: %v", path, err)
+	}
+
+	log.Printf("replay \"%s\" matches: %d frames, %d events", path, replay.FinalFrame, len(replay.Events))
 }
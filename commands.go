@@ -0,0 +1,449 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrBadArguments     = errors.New("bad arguments")
+	ErrNotInGame        = errors.New("not in a game")
+	ErrUnmatchedQuote   = errors.New("unmatched quote")
+)
+
+// Path of a file named in a command, which must be a plain file name in dir.
+// Names with path separators or "..", and names starting with a dot, are
+// refused so commands can't reach files outside dir.
+func commandFilePath(dir string, name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.Contains(name, "..") ||
+		strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+		return "", fmt.Errorf("%w: invalid file name \"%s\"", ErrBadArguments, name)
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+// Who may run a command
+type Permission int
+
+const (
+	PermPlayer Permission = iota
+	PermAdmin
+)
+
+func (p Permission) String() string {
+	switch p {
+	case PermPlayer:
+		return "player"
+	case PermAdmin:
+		return "admin"
+	default:
+		return fmt.Sprintf("permission_%d", int(p))
+	}
+}
+
+func (p Permission) Allows(sess Session) bool {
+	switch p {
+	case PermPlayer:
+		return true
+	case PermAdmin:
+		return sess.IsAdministrator()
+	default:
+		return false
+	}
+}
+
+// One argument of a command
+//
+// Optional arguments may be left off the end of the command line.  A Rest
+// argument takes the rest of the command line, and must be the last argument.
+type ArgSpec struct {
+	Name     string
+	Optional bool
+	Rest     bool
+}
+
+func (a ArgSpec) String() string {
+	name := a.Name
+	if a.Rest {
+		name += "..."
+	}
+
+	if a.Optional {
+		return "[" + name + "]"
+	}
+
+	return "<" + name + ">"
+}
+
+type CommandContext struct {
+	Session Session
+	Lobby   *Lobby
+
+	// Game of the session, or nil if the session isn't in a game
+	Game *Game
+
+	// Name the command was invoked with
+	Name string
+
+	// Arguments by name.  Optional arguments that weren't given are
+	// missing.
+	Args map[string]string
+}
+
+func (ctx *CommandContext) Arg(name string) string {
+	return ctx.Args[name]
+}
+
+func (ctx *CommandContext) Reply(lvl chat.MsgLevel, format string, args ...interface{}) {
+	ctx.Session.Message(lvl, fmt.Sprintf(format, args...))
+}
+
+type Command struct {
+	Name    string
+	Aliases []string
+	Args    []ArgSpec
+	Help    string
+
+	Permission Permission
+
+	// Command can only be used in a game
+	NeedsGame bool
+
+	Run func(ctx *CommandContext) error
+}
+
+func (cmd *Command) Usage() string {
+	parts := []string{"/" + cmd.Name}
+	for _, a := range cmd.Args {
+		parts = append(parts, a.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Matches the command line arguments to the command's arguments.  A Rest
+// argument takes the rest of the line as written, quotes and all.
+func (cmd *Command) bindArgs(line string) (map[string]string, error) {
+	args := make(map[string]string, len(cmd.Args))
+
+	for _, spec := range cmd.Args {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			if spec.Optional {
+				break
+			}
+
+			return nil, fmt.Errorf("%w: missing %s; usage: %s", ErrBadArguments, spec, cmd.Usage())
+		}
+
+		if spec.Rest {
+			args[spec.Name] = strings.TrimSpace(line)
+			return args, nil
+		}
+
+		word, rest, err := nextWord(line)
+		if err != nil {
+			return nil, err
+		}
+
+		args[spec.Name] = word
+		line = rest
+	}
+
+	if strings.TrimSpace(line) != "" {
+		return nil, fmt.Errorf("%w: too many arguments; usage: %s", ErrBadArguments, cmd.Usage())
+	}
+
+	return args, nil
+}
+
+type CommandRegistry struct {
+	commands map[string]*Command
+	byName   map[string]*Command
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: make(map[string]*Command),
+		byName:   make(map[string]*Command),
+	}
+}
+
+// Adds a command.  Names and aliases must not already be registered.
+func (r *CommandRegistry) Register(cmd *Command) error {
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		if _, ok := r.commands[name]; ok {
+			return fmt.Errorf("command \"%s\" is already registered", name)
+		}
+	}
+
+	for i, a := range cmd.Args {
+		if a.Rest && i != len(cmd.Args)-1 {
+			return fmt.Errorf("command \"%s\": only the last argument may collect the rest", cmd.Name)
+		}
+	}
+
+	for _, name := range names {
+		r.commands[name] = cmd
+	}
+	r.byName[cmd.Name] = cmd
+
+	return nil
+}
+
+// Like Register, but panics on error.  Used for built-in commands.
+func (r *CommandRegistry) MustRegister(cmd *Command) {
+	if err := r.Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// Looks up a command by name or alias
+func (r *CommandRegistry) Lookup(name string) *Command {
+	return r.commands[strings.TrimPrefix(name, "/")]
+}
+
+// Commands the session may run, sorted by name
+func (r *CommandRegistry) Available(sess Session) []*Command {
+	var cmds []*Command
+	for _, cmd := range r.byName {
+		if cmd.Permission.Allows(sess) {
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+
+	return cmds
+}
+
+// Parses and runs a command line ("/name args...")
+func (r *CommandRegistry) Execute(sess Session, lobby *Lobby, line string) error {
+	word, rest, err := nextWord(strings.TrimLeft(line, " \t"))
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(word, "/") {
+		return ErrUnknownCommand
+	}
+
+	name := strings.ToLower(word[1:])
+	cmd := r.commands[name]
+	if cmd == nil {
+		return fmt.Errorf("%w /%s", ErrUnknownCommand, name)
+	}
+
+	if !cmd.Permission.Allows(sess) {
+		if cmd.Permission == PermAdmin {
+			auditf(sess, "denied: %s", line)
+		}
+
+		return fmt.Errorf("/%s: %w", name, ErrPermissionDenied)
+	}
+
+	if cmd.Permission == PermAdmin {
+		auditf(sess, "%s", line)
+	}
+
+	ctx := &CommandContext{
+		Session: sess,
+		Lobby:   lobby,
+		Game:    sess.Game(),
+		Name:    name,
+	}
+
+	if cmd.NeedsGame && ctx.Game == nil {
+		return fmt.Errorf("/%s: %w", name, ErrNotInGame)
+	}
+
+	ctx.Args, err = cmd.bindArgs(rest)
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Run(ctx); err != nil {
+		if cmd.Permission == PermAdmin {
+			auditf(sess, "/%s failed: %v", name, err)
+		}
+
+		return fmt.Errorf("/%s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Runs a command line and reports any errors to the session's log
+func (r *CommandRegistry) Dispatch(sess Session, lobby *Lobby, line string) {
+	if err := r.Execute(sess, lobby, line); err != nil {
+		sess.Message(chat.Info, err.Error())
+		if errors.Is(err, ErrUnknownCommand) {
+			sess.Message(chat.Info, "Type /help for a list of commands")
+		}
+	}
+}
+
+// Splits a command line into words.  Words are separated by spaces; single
+// or double quotes group words, and a backslash escapes the next character.
+func SplitCommandLine(line string) ([]string, error) {
+	var words []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return words, nil
+		}
+
+		word, rest, err := nextWord(line)
+		if err != nil {
+			return nil, err
+		}
+
+		words = append(words, word)
+		line = rest
+	}
+}
+
+// Reads the word at the start of line, which must not start with a space.
+// Returns the word and the rest of the line.
+func nextWord(line string) (string, string, error) {
+	var word strings.Builder
+
+	var quote rune
+	escaped := false
+
+	for i, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+
+		case r == '\\':
+			escaped = true
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+
+		case r == '"' || r == '\'':
+			quote = r
+
+		case r == ' ' || r == '\t':
+			return word.String(), line[i+1:], nil
+
+		default:
+			word.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return "", "", ErrUnmatchedQuote
+	}
+
+	if escaped {
+		word.WriteRune('\\')
+	}
+
+	return word.String(), "", nil
+}
+
+// Registry of the built-in commands
+var Commands = NewCommandRegistry()
+
+func init() {
+	Commands.MustRegister(&Command{
+		Name:    "help",
+		Aliases: []string{"?"},
+		Args:    []ArgSpec{{Name: "command", Optional: true}},
+		Help:    "Lists commands, or describes a command",
+		Run:     helpCommand,
+	})
+
+	Commands.MustRegister(&Command{
+		Name:    "quit",
+		Aliases: []string{"q"},
+		Help:    "Leaves the game",
+		Run: func(ctx *CommandContext) error {
+			ctx.Session.Quit()
+			return nil
+		},
+	})
+
+	Commands.MustRegister(&Command{
+		Name:      "duel",
+		Args:      []ArgSpec{{Name: "player"}},
+		Help:      "Challenges a player to a duel, or accepts a challenge",
+		NeedsGame: true,
+		Run: func(ctx *CommandContext) error {
+			return ctx.Game.Duel(ctx.Session, ctx.Arg("player"))
+		},
+	})
+
+	Commands.MustRegister(&Command{
+		Name:      "listmobs",
+		Help:      "Lists the mobs in the game",
+		NeedsGame: true,
+		Run: func(ctx *CommandContext) error {
+			g := ctx.Game
+
+			g.Lock()
+			defer g.Unlock()
+
+			for i := range g.Mobs {
+				m := &g.Mobs[i]
+				g.messagef(chat.Info, "[%3d] %+v", i, m)
+			}
+
+			return nil
+		},
+	})
+
+	registerChatCommands(Commands)
+	registerAdminCommands(Commands)
+	registerClockCommands(Commands)
+	registerReplayCommands(Commands)
+	registerSnapshotCommands(Commands)
+	registerSpectatorCommands(Commands)
+}
+
+func helpCommand(ctx *CommandContext) error {
+	sess := ctx.Session
+
+	if name := strings.ToLower(ctx.Arg("command")); name != "" {
+		cmd := Commands.Lookup(name)
+		if cmd == nil || !cmd.Permission.Allows(sess) {
+			return fmt.Errorf("%w /%s", ErrUnknownCommand, strings.TrimPrefix(name, "/"))
+		}
+
+		ctx.Reply(chat.Info, "usage: %s", cmd.Usage())
+		ctx.Reply(chat.Info, "  %s", cmd.Help)
+		if len(cmd.Aliases) > 0 {
+			ctx.Reply(chat.Info, "  aliases: /%s", strings.Join(cmd.Aliases, ", /"))
+		}
+
+		if cmd.NeedsGame {
+			ctx.Reply(chat.Info, "  only available in a game")
+		}
+
+		return nil
+	}
+
+	ctx.Reply(chat.Info, "Commands:")
+	for _, cmd := range Commands.Available(sess) {
+		ctx.Reply(chat.Info, "  %-28s %s", cmd.Usage(), cmd.Help)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package mpnethack
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		words []string
+	}{
+		{"/who", []string{"/who"}},
+		{"  /tell   bob  hi  ", []string{"/tell", "bob", "hi"}},
+		{`/tell "Asron the Limited" hello`, []string{"/tell", "Asron the Limited", "hello"}},
+		{`/say it\'s 'a "quote"'`, []string{"/say", "it's", `a "quote"`}},
+		{`/x a""b`, []string{"/x", "ab"}},
+	}
+
+	for _, tc := range tests {
+		words, err := SplitCommandLine(tc.line)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.line, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(words, tc.words) {
+			t.Errorf("%q: expected %q but found %q", tc.line, tc.words, words)
+		}
+	}
+
+	if _, err := SplitCommandLine(`/tell "bob hi`); err != ErrUnmatchedQuote {
+		t.Errorf("expected unmatched quote error, found %v", err)
+	}
+}
+
+func TestCommandRegistry(t *testing.T) {
+	r := NewCommandRegistry()
+
+	var got map[string]string
+	r.MustRegister(&Command{
+		Name:    "give",
+		Aliases: []string{"g"},
+		Args:    []ArgSpec{{Name: "player"}, {Name: "item"}, {Name: "note", Optional: true, Rest: true}},
+		Run: func(ctx *CommandContext) error {
+			got = ctx.Args
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "kick",
+		Permission: PermAdmin,
+		Run:        func(ctx *CommandContext) error { return nil },
+	})
+
+	if err := r.Register(&Command{Name: "g"}); err == nil {
+		t.Errorf("expected an error registering a duplicate alias")
+	}
+
+	sess := newTestSession("alice")
+
+	if err := r.Execute(sess, nil, `/G bob "lemming jerky" don't eat it`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{"player": "bob", "item": "lemming jerky", "note": "don't eat it"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected args %v but found %v", expected, got)
+	}
+
+	if err := r.Execute(sess, nil, "/give bob"); !errors.Is(err, ErrBadArguments) {
+		t.Errorf("expected bad arguments error, found %v", err)
+	}
+
+	if err := r.Execute(sess, nil, "/kick bob"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected permission denied error, found %v", err)
+	}
+
+	if err := r.Execute(sess, nil, "/nope"); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("expected unknown command error, found %v", err)
+	}
+
+	if cmds := r.Available(sess); len(cmds) != 1 || cmds[0].Name != "give" {
+		t.Errorf("expected only /give to be available, found %v", cmds)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	tests := []struct {
+		line  string
+		usage string
+		err   error
+	}{
+		{"/help quit", "usage: /quit", nil},
+		{"/help QUIT", "usage: /quit", nil},
+		{"/HELP /Quit", "usage: /quit", nil},
+		{"/help Kick", "", ErrUnknownCommand},
+		{"/help nope", "", ErrUnknownCommand},
+	}
+
+	for _, tc := range tests {
+		sess := newTestSession("alice")
+
+		err := Commands.Execute(sess, nil, tc.line)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%q: expected error %v but found %v", tc.line, tc.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.line, err)
+			continue
+		}
+
+		found := false
+		for _, l := range sess.log.Lines {
+			found = found || l.Text == tc.usage
+		}
+
+		if !found {
+			t.Errorf("%q: expected %q in the reply", tc.line, tc.usage)
+		}
+	}
+}
+
+func TestCommandFilePath(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"game1.toml", true},
+		{"my game", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{".hidden", false},
+		{"../store.db", false},
+		{"a..b", false},
+		{"sub/game.toml", false},
+		{"/etc/passwd", false},
+		{`sub\game.toml`, false},
+	}
+
+	for _, tc := range tests {
+		path, err := commandFilePath("replays", tc.name)
+		if !tc.ok {
+			if !errors.Is(err, ErrBadArguments) {
+				t.Errorf("%q: expected bad arguments error, found path %q, error %v", tc.name, path, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.name, err)
+		} else if path != filepath.Join("replays", tc.name) {
+			t.Errorf("%q: expected path %q but found %q", tc.name, filepath.Join("replays", tc.name), path)
+		}
+	}
+}
+
+func TestGameClock(t *testing.T) {
+	g := &Game{
+		pump:    time.NewTicker(GameRefreshInterval),
+		GameLog: chat.NewLog(GameLogNumLines),
+	}
+	defer g.pump.Stop()
+
+	if !g.takeTick() {
+		t.Errorf("expected a running game to tick")
+	}
+
+	g.Pause()
+	if g.takeTick() {
+		t.Errorf("expected a paused game not to tick")
+	}
+
+	if err := g.Step(2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if lbl := g.Clock().Label(); lbl != "STEP 2" {
+		t.Errorf("expected label \"STEP 2\" but found \"%s\"", lbl)
+	}
+
+	for i := 0; i < 2; i++ {
+		if !g.takeTick() {
+			t.Errorf("expected step %d to tick", i+1)
+		}
+	}
+
+	if g.takeTick() {
+		t.Errorf("expected the game to stop after its steps")
+	}
+
+	if err := g.Step(0); !errors.Is(err, ErrBadStepCount) {
+		t.Errorf("expected bad step count error, found %v", err)
+	}
+
+	if err := g.SetTickInterval(time.Millisecond); !errors.Is(err, ErrBadTickInterval) {
+		t.Errorf("expected bad tick interval error, found %v", err)
+	}
+
+	d, err := parseTickInterval("250")
+	if err != nil || d != 250*time.Millisecond {
+		t.Errorf("expected 250ms but found %v (err=%v)", d, err)
+	}
+
+	if err := g.SetTickInterval(d); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	g.Resume()
+	if lbl := g.Clock().Label(); lbl != "TICK 250ms" {
+		t.Errorf("expected label \"TICK 250ms\" but found \"%s\"", lbl)
+	}
+}
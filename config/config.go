@@ -323,6 +323,14 @@ func UnmarshalHelper(data interface{}, dest map[string]interface{}, flags Flags)
 			}
 
 			*obj = sval
+
+		case *bool:
+			bval, ok := v.(bool)
+			if !ok {
+				return ErrBadType
+			}
+
+			*obj = bval
 		}
 
 		names[k] = inSet
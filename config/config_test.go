@@ -233,3 +233,37 @@ func TestUnmarshalHelper_TextUnmarshaler(t *testing.T) {
 		t.Errorf("values[2] expected to be 3 but found %d", values[2])
 	}
 }
+
+func TestUnmarshalHelper_Bool(t *testing.T) {
+	values := []bool{false, true}
+
+	data := map[string]interface{}{
+		"b1": true,
+		"b2": false,
+	}
+
+	err := UnmarshalHelper(data, map[string]interface{}{
+		"b1": &values[0],
+		"b2": &values[1],
+	}, UnknownKeyIsError)
+
+	if err != nil {
+		t.Errorf("error unmarshaling: %v", err)
+	}
+
+	if values[0] != true {
+		t.Errorf("values[0] expected to be true but found %v", values[0])
+	}
+
+	if values[1] != false {
+		t.Errorf("values[1] expected to be false but found %v", values[1])
+	}
+
+	err = UnmarshalHelper(map[string]interface{}{"b1": "yes"}, map[string]interface{}{
+		"b1": &values[0],
+	}, UnknownKeyIsError)
+
+	if err != ErrBadType {
+		t.Errorf("expected error %v but found %v", ErrBadType, err)
+	}
+}
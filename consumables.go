@@ -0,0 +1,84 @@
+package mpnethack
+
+import (
+	"fmt"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+// Number of ticks the map stays revealed after using a consumable that
+// reveals the map
+const RevealMapTicks int16 = 300
+
+// Uses the item in the given inventory slot
+//
+// Assumes the write lock is held
+func (g *Game) useItem(pl *Player, slot int) {
+	if slot < 0 || slot >= len(pl.Inventory) {
+		pl.S.Message(chat.Info, "You have nothing in that slot.")
+		return
+	}
+
+	itm := pl.Inventory[slot]
+	c, ok := itm.(*Consumable)
+	if !ok {
+		pl.S.Message(chat.Info, fmt.Sprintf("You can't use the %s.", itm.ShortName()))
+		return
+	}
+
+	pl.Inventory = append(pl.Inventory[:slot], pl.Inventory[slot+1:]...)
+
+	if c.UseDescription != "" {
+		g.messagef(chat.Game, "%s uses the %s.  %s", pl.Name(), c.ShortName(), c.UseDescription)
+	} else {
+		g.messagef(chat.Game, "%s uses the %s.", pl.Name(), c.ShortName())
+	}
+
+	if heal := c.HealRoll(); heal.M > 0 && heal.N > 0 {
+		stats := &pl.Stats
+		amt := MinInt(heal.Roll(g.Dice), stats.MaxHP-stats.HP)
+		stats.HP += amt
+		g.messagef(chat.Game, "%s heals %d health", pl.Name(), amt)
+	}
+
+	if spec := c.StatusEffect(); spec.IsValid() {
+		g.applyStatusEffect(pl, spec, pl)
+	}
+
+	if c.Teleports() {
+		if i, j, ok := g.randomOpenSpot(); ok {
+			pl.I = i
+			pl.J = j
+			g.messagef(chat.Game, "%s vanishes in a puff of smoke!", pl.Name())
+		} else {
+			pl.S.Message(chat.Game, "You feel a wrenching sensation, but nothing happens.")
+		}
+	}
+
+	if c.RevealsMap() {
+		g.applyStatusEffect(pl, StatusEffectSpec{
+			Type:     StatusMapping,
+			Duration: RevealMapTicks,
+		}, pl)
+	}
+}
+
+// Picks a random spot on the level that is open.  Returns false if no open
+// spot was found.
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) randomOpenSpot() (int, int, bool) {
+	const maxTries = 100
+
+	lvl := g.Level
+	for try := 0; try < maxTries; try++ {
+		i := g.Dice.Roll1dN(lvl.H) - 1
+		j := g.Dice.Roll1dN(lvl.W) - 1
+
+		if _, hasColl := g.hasCollision(i, j); !hasColl {
+			return i, j, true
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,124 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrNotDead        = errors.New("player is not dead")
+	ErrGhostsDisabled = errors.New("ghosts are not allowed in this game")
+)
+
+// Assumes the write lock is held
+func (g *Game) updateDeadPlayer(pl *Player) {
+	if !pl.Dead {
+		g.playerDied(pl)
+		return
+	}
+
+	if pl.RespawnTick--; pl.RespawnTick <= 0 {
+		g.respawnPlayer(pl)
+	}
+}
+
+// Assumes the write lock is held
+func (g *Game) playerDied(pl *Player) {
+	pl.Dead = true
+	pl.Ghost = false
+	pl.RespawnTick = g.Rules.RespawnTicks
+	pl.Deaths++
+	pl.Effects = nil
+
+	killer := pl.Killer
+	if killer == "" {
+		killer = "misadventure"
+		pl.Killer = killer
+	}
+
+	g.messagef(chat.Game, "%s has died.", pl.Name())
+	g.endDuels(pl)
+
+	if g.Rules.DropInventoryOnDeath && len(pl.Inventory) > 0 {
+		g.dropItems(pl.I, pl.J, pl.Inventory...)
+		g.messagef(chat.Game, "%s's belongings scatter on the ground.", pl.Name())
+		pl.Inventory = []Item{}
+	}
+
+	g.loseExperience(pl, g.Rules.XPLossOnDeathPercent)
+
+	respawnSecs := int(math.Ceil(pl.RespawnIn().Seconds()))
+	pl.S.Message(chat.Game, fmt.Sprintf("You were killed by %s!  You will respawn in %d seconds.", killer, respawnSecs))
+}
+
+// Assumes the write lock is held
+func (g *Game) respawnPlayer(pl *Player) {
+	lvl := g.Level
+	i, j := g.findOpenSpot(lvl.PlayerI0, lvl.PlayerJ0)
+
+	pl.I = i
+	pl.J = j
+	pl.Facing = Up
+
+	pl.Stats.HP = pl.Stats.MaxHP
+	pl.BusyTick = 0
+	pl.HealthTick = 0
+
+	pl.Dead = false
+	pl.Ghost = false
+	pl.Killer = ""
+	pl.RespawnTick = 0
+
+	g.messagef(chat.Game, "%s has respawned.", pl.Name())
+}
+
+// Finds the nearest open spot to (i0,j0), searching in square rings of
+// increasing size.  Returns (i0,j0) if there are no open spots.
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) findOpenSpot(i0, j0 int) (int, int) {
+	lvl := g.Level
+
+	if _, hasColl := g.hasCollision(i0, j0); !hasColl {
+		return i0, j0
+	}
+
+	maxRadius := MaxInt(lvl.H, lvl.W)
+	for r := 1; r < maxRadius; r++ {
+		for i := i0 - r; i <= i0+r; i++ {
+			for j := j0 - r; j <= j0+r; j++ {
+				// only check the ring
+				if i != i0-r && i != i0+r && j != j0-r && j != j0+r {
+					continue
+				}
+
+				if _, hasColl := g.hasCollision(i, j); !hasColl {
+					return i, j
+				}
+			}
+		}
+	}
+
+	return i0, j0
+}
+
+// Toggles whether a dead player watches the game as a ghost
+func (g *Game) ToggleGhost(s Session) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pl := s.Player()
+	if pl == nil || !pl.Dead {
+		return ErrNotDead
+	}
+
+	if !g.Rules.AllowGhosts {
+		return ErrGhostsDisabled
+	}
+
+	pl.Ghost = !pl.Ghost
+	return nil
+}
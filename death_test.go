@@ -0,0 +1,70 @@
+package mpnethack
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlayerDeath(t *testing.T) {
+	g, alice := newTestGame(t)
+	g.Rules.RespawnTicks = 5
+
+	// keep the mobs out of the way
+	g.Mobs = g.Mobs[:0]
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+	pl := alice.pl
+
+	if err := g.ToggleGhost(alice); !errors.Is(err, ErrNotDead) {
+		t.Errorf("expected a living player not to become a ghost, found %v", err)
+	}
+
+	potion, _ := testLookupItem("potion")
+	pl.Inventory = []Item{potion}
+	pl.I, pl.J = 5, 5
+	pl.Stats.HP = 0
+
+	g.loopInner()
+
+	if !pl.Dead || pl.Deaths != 1 || pl.RespawnTick != g.Rules.RespawnTicks || pl.Killer != "misadventure" {
+		t.Fatalf("expected alice to be dead, found dead %v, deaths %d, respawn tick %d, killer %q",
+			pl.Dead, pl.Deaths, pl.RespawnTick, pl.Killer)
+	}
+
+	if len(pl.Inventory) != 0 {
+		t.Errorf("expected the inventory to be dropped, found %d items", len(pl.Inventory))
+	}
+
+	if len(g.FloorItems) != 1 || g.FloorItems[0].I != 5 || g.FloorItems[0].J != 5 || g.FloorItems[0].Item != potion {
+		t.Errorf("expected the potion on the floor at (5,5), found %+v", g.FloorItems)
+	}
+
+	// ghosts
+	if err := g.ToggleGhost(alice); err != nil || !pl.Ghost {
+		t.Errorf("expected alice to become a ghost, found ghost %v (error %v)", pl.Ghost, err)
+	}
+
+	g.Rules.AllowGhosts = false
+	if err := g.ToggleGhost(alice); !errors.Is(err, ErrGhostsDisabled) {
+		t.Errorf("expected ghosts to be disabled, found %v", err)
+	}
+
+	for k := 1; k < int(g.Rules.RespawnTicks); k++ {
+		g.loopInner()
+	}
+
+	if !pl.Dead {
+		t.Fatalf("expected alice to respawn after %d ticks, not sooner", g.Rules.RespawnTicks)
+	}
+
+	g.loopInner()
+
+	lvl := g.Level
+	if pl.Dead || pl.Ghost || pl.I != lvl.PlayerI0 || pl.J != lvl.PlayerJ0 || pl.Stats.HP != pl.Stats.MaxHP {
+		t.Errorf("expected alice to respawn at (%d,%d) with full health, found dead %v at (%d,%d) with %d/%d hp",
+			lvl.PlayerI0, lvl.PlayerJ0, pl.Dead, pl.I, pl.J, pl.Stats.HP, pl.Stats.MaxHP)
+	}
+}
@@ -0,0 +1,127 @@
+package mpnethack
+
+import "fmt"
+
+// How members of one faction regard members of another
+type FactionRelation int
+
+const (
+	FactionNeutral FactionRelation = iota
+	FactionFriendly
+	FactionHostile
+)
+
+func (rel FactionRelation) String() string {
+	switch rel {
+	case FactionNeutral:
+		return "neutral"
+	case FactionFriendly:
+		return "friendly"
+	case FactionHostile:
+		return "hostile"
+	default:
+		return fmt.Sprintf("relation_%d", int(rel))
+	}
+}
+
+func (rel FactionRelation) MarshalText() ([]byte, error) {
+	return []byte(rel.String()), nil
+}
+
+func (rel *FactionRelation) UnmarshalText(text []byte) error {
+	s := string(text)
+	switch s {
+	case "neutral":
+		*rel = FactionNeutral
+	case "friendly":
+		*rel = FactionFriendly
+	case "hostile":
+		*rel = FactionHostile
+	default:
+		return fmt.Errorf("unknown faction relation \"%s\"", s)
+	}
+
+	return nil
+}
+
+type factionPair struct {
+	a, b string
+}
+
+func makeFactionPair(a, b string) factionPair {
+	if b < a {
+		a, b = b, a
+	}
+
+	return factionPair{a, b}
+}
+
+// Relations between factions.  Relations are symmetric; factions that
+// aren't listed are neutral toward each other.
+var factionRelations = map[factionPair]FactionRelation{
+	makeFactionPair("lemmings", "vicious_lemmings"): FactionHostile,
+}
+
+func SetFactionRelation(a, b string, rel FactionRelation) {
+	factionRelations[makeFactionPair(a, b)] = rel
+}
+
+// Relation between two factions.  Members of the same faction are friendly.
+func LookupFactionRelation(a, b string) FactionRelation {
+	if a == b {
+		return FactionFriendly
+	}
+
+	return factionRelations[makeFactionPair(a, b)]
+}
+
+// Faction of the mob type.  Mob types without a faction are their own
+// faction.
+func (mi *MobInfo) FactionName() string {
+	if mi.Faction != "" {
+		return mi.Faction
+	}
+
+	return mi.Tag
+}
+
+// Whether the mob, given its current aggression, will attack the unit
+// unprovoked
+func (m *Mob) IsHostileTo(u Unit) bool {
+	if u == nil || u == Unit(m) || !u.IsAlive() {
+		return false
+	}
+
+	switch other := u.(type) {
+	case *Player:
+		switch m.Aggression {
+		case AggressionAttacks, AggressionAttacksMobs, AggressionBlindRage:
+			return true
+		default:
+			return false
+		}
+
+	case *Mob:
+		if m.Aggression == AggressionBlindRage {
+			return true
+		}
+
+		rel := FactionNeutral
+		mi, err1 := LookupMobInfo(m.Type)
+		oi, err2 := LookupMobInfo(other.Type)
+		if err1 == nil && err2 == nil {
+			rel = LookupFactionRelation(mi.FactionName(), oi.FactionName())
+		}
+
+		switch m.Aggression {
+		case AggressionAttacks:
+			return rel == FactionHostile
+		case AggressionAttacksMobs, AggressionAttacksOnlyMobs:
+			return rel != FactionFriendly
+		default:
+			return false
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package mpnethack
+
+import "github.com/sfstewman/mpnethack/chat"
+
+// An item lying on the floor of the level
+type FloorItem struct {
+	I, J int
+	Item Item
+}
+
+// Assumes the write lock is held
+func (g *Game) dropItems(i, j int, items ...Item) {
+	for _, itm := range items {
+		if itm == nil {
+			continue
+		}
+
+		g.FloorItems = append(g.FloorItems, FloorItem{I: i, J: j, Item: itm})
+	}
+}
+
+// Picks up any items at the player's current position
+//
+// Assumes the write lock is held
+func (g *Game) pickupItems(pl *Player) {
+	kept := g.FloorItems[:0]
+	for _, fi := range g.FloorItems {
+		if fi.I != pl.I || fi.J != pl.J {
+			kept = append(kept, fi)
+			continue
+		}
+
+		pl.Inventory = append(pl.Inventory, fi.Item)
+		g.messagef(chat.Game, "%s picks up %s", pl.Name(), fi.Item.Name())
+	}
+
+	g.FloorItems = kept
+}
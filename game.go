@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 	"unicode"
@@ -16,6 +17,8 @@ var (
 	ErrUnknownCommand  error = errors.New("unknown command")
 	ErrOnCooldown      error = errors.New("action still on cooldown")
 	ErrInvalidCooldown error = errors.New("action has no valid cooldown")
+	ErrPlayerDead      error = errors.New("player is dead")
+	ErrStunned         error = errors.New("player is stunned")
 )
 
 const (
@@ -29,6 +32,7 @@ const (
 	Move
 	Attack
 	Defend
+	Use
 
 	MaxActionType int = iota
 )
@@ -43,16 +47,35 @@ func (act ActionType) String() string {
 		return "ACT_ATT"
 	case Defend:
 		return "ACT_DEF"
+	case Use:
+		return "ACT_USE"
 	default:
 		return fmt.Sprintf("ACT_UNK_%d", int(act))
 	}
 }
 
+func (act ActionType) MarshalText() ([]byte, error) {
+	return []byte(act.String()), nil
+}
+
+func (act *ActionType) UnmarshalText(text []byte) error {
+	s := string(text)
+	for a := Nothing; int(a) < MaxActionType; a++ {
+		if a.String() == s {
+			*act = a
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown action type \"%s\"", s)
+}
+
 var UserActionCooldownTicks = [MaxActionType]uint64{
 	Nothing: 0,
 	Move:    1,
 	Attack:  5,
 	Defend:  150,
+	Use:     20,
 }
 
 type Session interface {
@@ -82,6 +105,9 @@ type UnitStats struct {
 	HP                 int
 	MaxHP              int
 	HealthRecoveryRate int16
+
+	Level int
+	XP    int
 }
 
 func (s *UnitStats) ToHit(other *UnitStats) int {
@@ -95,6 +121,7 @@ type Unit interface {
 	GetPos() (i int, j int, h int, w int)
 
 	GetStats() *UnitStats
+	GetStatusEffects() *StatusEffects
 
 	TakeDamage(dmg int, u Unit)
 	IsAlive() bool
@@ -117,20 +144,44 @@ type Game struct {
 	pump *time.Ticker
 	Ctx  context.Context
 
-	Dice Dice
+	Dice  Dice
+	Rules GameRules
+
+	// Name of the level the game was built from (see RegisterLevel), or ""
+	// if the level isn't registered
+	LevelName string
 
 	Active   []Session
 	GameLog  *chat.Log
+
+	// Sessions watching the game without a player (see spectate.go)
+	Spectators []Session
+
 	FrameNum uint64
 
 	pendingActions []Action
 
+	// Clock control (see clock.go)
+	paused       bool
+	stepTicks    int
+	tickInterval time.Duration
+
+	// Records the game for replay, if not nil (see replay.go)
+	recorder *ReplayRecorder
+
+	// Offline games neither load nor save player records
+	offline bool
+
 	Level   *Level
 	Players map[string]*Player
 	Markers map[rune]*Player
+
+	// Players restored from a snapshot, waiting for their users to rejoin
+	waiting map[string]*Player
 	// Rendered Board
 	Mobs           []Mob
 	EffectsOverlay []Effect
+	FloorItems     []FloorItem
 
 	Cancel context.CancelFunc
 }
@@ -180,6 +231,11 @@ func (g *Game) hasCollision(newI, newJ int) (Namer, bool) {
 
 	// TODO: better collision detect for players/mobs
 	for _, pl := range g.Markers {
+		// dead players are ghosts and do not block movement
+		if pl.Dead {
+			continue
+		}
+
 		if newI == pl.I && newJ == pl.J {
 			return pl, true
 		}
@@ -200,17 +256,30 @@ func (g *Game) hasCollision(newI, newJ int) (Namer, bool) {
 const GameLogNumLines = 100
 
 func NewGame(l *Level) (*Game, error) {
+	return NewGameWithRules(l, DefaultGameRules)
+}
+
+func NewGameWithRules(l *Level, rules GameRules) (*Game, error) {
 	dice, err := NewDice()
 	if err != nil {
 		return nil, err
 	}
 
+	g := newGame(l, rules, dice)
+
+	go g.Loop()
+	return g, nil
+}
+
+// Sets up a game without starting its loop
+func newGame(l *Level, rules GameRules, dice Dice) *Game {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	g := &Game{
 		pump: time.NewTicker(GameRefreshInterval),
 		Ctx:  ctx,
 
-		Dice: dice,
+		Dice:  dice,
+		Rules: rules,
 
 		GameLog: chat.NewLog(GameLogNumLines),
 
@@ -224,11 +293,13 @@ func NewGame(l *Level) (*Game, error) {
 		Cancel: cancelFunc,
 	}
 
-	g.Mobs = make([]Mob, len(l.Mobs))
+	g.Mobs = make([]Mob, len(l.Mobs), len(l.Mobs)+MobSpawnHeadroom)
 	copy(g.Mobs, l.Mobs)
 
-	go g.Loop()
-	return g, nil
+	g.FloorItems = make([]FloorItem, len(l.Items))
+	copy(g.FloorItems, l.Items)
+
+	return g
 }
 
 func (g *Game) PickMarker(user string) (rune, error) {
@@ -287,6 +358,33 @@ func (g *Game) PlayerJoin(sess Session) (*Player, error) {
 
 	name := sess.UserName()
 
+	xp := 0
+	if LookupPlayerRecord != nil && !g.offline && hasRecord(sess) {
+		rec, err := LookupPlayerRecord(name)
+		if err != nil {
+			log.Printf("error loading player record for \"%s\": %v", name, err)
+		} else if rec != nil {
+			xp = rec.XP
+		}
+	}
+
+	return g.addPlayer(sess, xp)
+}
+
+// Adds a player for the session, starting with the given experience
+//
+// Assumes the write lock is held
+func (g *Game) addPlayer(sess Session, xp int) (*Player, error) {
+	name := sess.UserName()
+
+	if pl, err := g.rejoinPlayer(sess); pl != nil || err != nil {
+		return pl, err
+	}
+
+	if pl := g.reattachPlayer(sess); pl != nil {
+		return pl, nil
+	}
+
 	marker, err := g.pickMarker(name)
 	if err != nil {
 		return nil, err
@@ -306,18 +404,19 @@ func (g *Game) PlayerJoin(sess Session) (*Player, error) {
 		Weapon:    rustySword,
 		Inventory: []Item{},
 		Stats: UnitStats{
-			ArmorClass:         10,
-			THAC0:              0,
-			HP:                 16,
-			MaxHP:              16,
-			HealthRecoveryRate: 50,
+			ArmorClass: 10,
+			XP:         xp,
 		},
 	}
 
+	pl.Stats.ApplyLevel(LevelForXP(pl.Stats.XP))
+	pl.Stats.HP = pl.Stats.MaxHP
+
 	g.Players[name] = pl
 	g.Markers[marker] = pl
 
 	g.Active = append(g.Active, sess)
+	g.record(ReplayEvent{Kind: ReplayJoin, User: name, XP: xp})
 	g.messagef(chat.Info, "%s (%c) joined the game!", name, marker)
 
 	return pl, nil
@@ -329,12 +428,33 @@ func (g *Game) PlayerLeave(sess Session) {
 
 	pl := sess.Player()
 	name := sess.UserName()
-	if pl.S != sess {
+	if pl == nil || pl.S != sess {
 		return
 	}
 
+	// already left, e.g. kicked before disconnecting
+	if g.Players[name] != pl {
+		return
+	}
+
+	g.removePlayer(pl)
+}
+
+// Assumes the write lock is held
+func (g *Game) removePlayer(pl *Player) {
+	sess := pl.S
+	name := pl.Name()
+
+	g.record(ReplayEvent{Kind: ReplayLeave, User: name})
+
+	g.savePlayer(pl)
+	g.endDuels(pl)
+	g.forgetUnit(pl)
+	g.dropPendingActions(pl)
+
 	// delete(g.Players, sess.User)
 	delete(g.Players, name)
+	delete(g.Markers, pl.Marker)
 
 	for i, activeSess := range g.Active {
 		if activeSess == sess {
@@ -343,7 +463,7 @@ func (g *Game) PlayerLeave(sess Session) {
 		}
 	}
 
-	g.Messagef(chat.Info, "%s left the game!", name)
+	g.messagef(chat.Info, "%s left the game!", name)
 }
 
 func (g *Game) Shutdown() {
@@ -352,6 +472,10 @@ func (g *Game) Shutdown() {
 
 func (g *Game) GetCooldowns(s Session, cds Cooldowns) Cooldowns {
 	pl := s.Player()
+	if pl == nil {
+		return make(Cooldowns, len(zeroCooldowns))
+	}
+
 	last := pl.Cooldowns
 
 	now := g.FrameNum
@@ -372,6 +496,10 @@ func (g *Game) UserAction(s Session, actType ActionType, arg int16) error {
 		return ErrInvalidCooldown
 	}
 
+	if !allowInput(s) {
+		return ErrInputRateLimited
+	}
+
 	// k := actionKey{s, act}
 
 	g.mu.Lock()
@@ -380,9 +508,21 @@ func (g *Game) UserAction(s Session, actType ActionType, arg int16) error {
 	now := g.FrameNum
 
 	pl := s.Player()
+	if pl == nil {
+		return ErrSpectating
+	}
+
 	actionCDs := pl.Cooldowns
 
-	if pl.BusyTick > 0 || pl.SwingState > 0 || !pl.IsAlive() {
+	if pl.Dead || !pl.IsAlive() {
+		return ErrPlayerDead
+	}
+
+	if pl.Effects.Has(StatusStun) {
+		return ErrStunned
+	}
+
+	if pl.BusyTick > 0 || pl.SwingState > 0 {
 		return ErrOnCooldown
 	}
 
@@ -391,22 +531,79 @@ func (g *Game) UserAction(s Session, actType ActionType, arg int16) error {
 		pl.Cooldowns = actionCDs
 	}
 
+	cooldown := UserActionCooldownTicks[actType]
+	if speed := pl.Effects.Modifiers().Speed; speed > 0 {
+		cooldown /= uint64(1 + speed)
+	}
+
 	last := actionCDs[actType]
-	if last > 0 && now-last < UserActionCooldownTicks[actType] {
+	if last > 0 && now-last < cooldown {
 		return ErrOnCooldown
 	}
 
-	if actType != Nothing {
-		actionCDs[actType] = now
-		log.Printf("action %v, cooldowns %v\n", actType, actionCDs)
+	// one action per player per tick
+	for _, act := range g.pendingActions {
+		if act.Player == pl {
+			return ErrOnCooldown
+		}
 	}
 
-	g.handleAction(Action{pl, actType, arg})
-	// g.pendingActions = append(g.pendingActions, Action{pl, actType, arg})
+	if actType != Nothing {
+		g.pendingActions = append(g.pendingActions, Action{pl, actType, arg})
+	}
 
 	return nil
 }
 
+// Applies an action queued by UserAction.  Actions are applied at the start
+// of a tick, so the outcome of a game depends only on its seed and the
+// actions taken on each tick.
+//
+// Assumes the write lock is held
+func (g *Game) applyAction(act Action) {
+	pl := act.Player
+	if len(pl.Cooldowns) == 0 {
+		pl.Cooldowns = make([]uint64, len(UserActionCooldownTicks))
+	}
+
+	pl.Cooldowns[act.Type] = g.FrameNum
+	log.Printf("action %v, cooldowns %v\n", act.Type, pl.Cooldowns)
+
+	g.record(ReplayEvent{Kind: ReplayAction, User: pl.Name(), Action: act.Type, Arg: act.Arg})
+	g.handleAction(act)
+}
+
+// Assumes the write lock is held
+func (g *Game) dropPendingActions(pl *Player) {
+	kept := g.pendingActions[:0]
+	for _, act := range g.pendingActions {
+		if act.Player != pl {
+			kept = append(kept, act)
+		}
+	}
+
+	g.pendingActions = kept
+}
+
+// Players sorted by name.  Game updates visit players in this order so the
+// outcome doesn't depend on map iteration order.
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) playerList() []*Player {
+	names := make([]string, 0, len(g.Players))
+	for name := range g.Players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	players := make([]*Player, len(names))
+	for i, name := range names {
+		players[i] = g.Players[name]
+	}
+
+	return players
+}
+
 func (g *Game) Move(s Session, direc Direction) error {
 	return g.UserAction(s, Move, int16(direc))
 }
@@ -449,6 +646,10 @@ func (g *Game) handleAction(act Action) {
 					if obj == MarkerCactus {
 						pl.TakeDamage(2, nil)
 						g.messagef(chat.Game, "Ouch!  %s takes %d damage from %s", user, 2, what.Name())
+
+						if !pl.IsAlive() {
+							pl.Killer = what.Name()
+						}
 					}
 				default:
 				}
@@ -456,6 +657,8 @@ func (g *Game) handleAction(act Action) {
 		} else {
 			pl.I = newI
 			pl.J = newJ
+
+			g.pickupItems(pl)
 		}
 
 		pl.Facing = direc
@@ -472,6 +675,9 @@ func (g *Game) handleAction(act Action) {
 		}
 	case Defend:
 		g.messagef(chat.Game, "%s is defending", user)
+
+	case Use:
+		g.useItem(pl, int(act.Arg))
 	}
 }
 
@@ -483,8 +689,9 @@ func (g *Game) meleeAttack(attacker, victim Unit, weaponItem Item) {
 		return
 	}
 
-	stats := victim.GetStats()
-	toHit := attacker.GetStats().ToHit(stats)
+	stats := EffectiveStats(victim)
+	attackerStats := EffectiveStats(attacker)
+	toHit := attackerStats.ToHit(&stats)
 
 	var dmg int
 	switch w := weaponItem.(type) {
@@ -499,13 +706,34 @@ func (g *Game) meleeAttack(attacker, victim Unit, weaponItem Item) {
 
 		victim.TakeDamage(dmg, attacker)
 
+		if w, ok := weaponItem.(*MeleeWeapon); ok && victim.IsAlive() {
+			if spec := w.OnHitEffect(); spec.IsValid() && spec.Roll(g.Dice) {
+				g.applyStatusEffect(victim, spec, attacker)
+			}
+		}
+
 		if !victim.IsAlive() {
 			g.messagef(chat.Game, "%s killed %s", attacker.Name(), victim.Name())
+
+			if mob, ok := victim.(*Mob); ok {
+				g.alertPack(mob, MobEventFriendDied, attacker)
+			}
+
+			if pl, ok := attacker.(*Player); ok {
+				if _, ok := victim.(*Player); ok {
+					pl.PlayerKills++
+				} else {
+					pl.MobKills++
+				}
+
+				g.awardExperience(pl, ExperienceFor(victim))
+			}
 		}
 	} else {
 		if mob, ok := victim.(*Mob); ok {
 			mob.Event = MobEventAttacked
 			mob.EventCause = attacker
+			mob.Threat.Add(attacker, ThreatPerAttack)
 		}
 		g.messagef(chat.Game, "%s swings wildy at %s with a %s but misses", attacker.Name(), victim.Name(), shortName)
 	}
@@ -576,8 +804,12 @@ func (g *Game) playerAttack(pl *Player) {
 				pl.SwingState = 0
 
 			case *Player:
-				g.messagef(chat.Game, "%s thwacks %s with the %s.  %s looks very miffed.",
-					pl.Name(), coll.Name(), shortName, coll.Name())
+				if g.canHurt(pl, victim) {
+					g.meleeAttack(pl, victim, weaponItem)
+				} else {
+					g.messagef(chat.Game, "%s thwacks %s with the %s.  %s looks very miffed.",
+						pl.Name(), coll.Name(), shortName, coll.Name())
+				}
 			}
 		}
 
@@ -684,7 +916,7 @@ func (g *Game) detectOthers(mob *Mob) []Unit {
 		return seenUnits
 	}
 
-	for _, pl := range g.Players {
+	for _, pl := range g.playerList() {
 		if pa.Inside(pl.I, pl.J) {
 			seenUnits = append(seenUnits, pl)
 		}
@@ -791,173 +1023,42 @@ func (g *Game) mobUpdate(mob *Mob) {
 		return
 	}
 
-	seenUnits := g.detectOthers(mob)
+	brain := mobBrain{
+		g:        g,
+		mob:      mob,
+		info:     mobInfo,
+		behavior: mobInfo.Behavior(),
+		seen:     g.detectOthers(mob),
+	}
 	// TODO: check for interesting objects within line of sight, too
 
 	if mob.Target != nil && !mob.Target.IsAlive() {
 		mob.Target = nil
 	}
 
-	// 1. handle any events that have happened to the mob
-	switch mob.Event {
-	case MobEventAttacked, MobEventHit:
-		if mob.Aggression != AggressionPassive {
-			if mob.EventCause != nil {
-				// TODO: handle multi-unit aggro
-				if mob.Target == nil || mob.State == MobSeekTarget {
-					mob.Target = mob.EventCause
-					ti, tj, _, _ := mob.Target.GetPos()
-					mob.LastTargetI = ti
-					mob.LastTargetJ = tj
-
-					mob.State = MobSeekTarget
-					mob.SeekTick = mobInfo.SeekTargetRate
-					mob.MoveTick = mobInfo.ChaseRate
-				}
-
-				// TODO: add log message
-			}
-		} else if mob.Event == MobEventHit || mob.State != MobSentry {
-			// passive mobs flee if they take damage or aren't sentries
-			if mob.EventCause != nil {
-				mob.Target = mob.EventCause
-				mob.State = MobFlee
-				mob.MoveTick = 1
-
-				ti, tj, _, _ := mob.Target.GetPos()
-				mob.LastTargetI = ti
-				mob.LastTargetJ = tj
-
-				// TODO: add log message
-			}
-		}
+	g.updateThreat(mob)
 
-	case MobEventBadlyHurt:
-		if mob.Aggression != AggressionBlindRage && mob.EventCause != nil {
-			mob.State = MobFlee
-			mob.MoveTick = 1
-
-			if mob.EventCause != nil {
-				u := mob.EventCause
-				mob.Target = u
-				ti, tj, _, _ := u.GetPos()
-				mob.LastTargetI = ti
-				mob.LastTargetJ = tj
-			} else if mob.Target == nil {
-				mob.LastTargetI = mob.I
-				mob.LastTargetJ = mob.J
-			}
-
-			// TODO: add log message
-		}
+	switch mob.Event {
+	case MobEventAttacked, MobEventHit, MobEventBadlyHurt, MobEventStunned:
+		g.alertPack(mob, MobEventPackAlert, mob.EventCause)
 	}
 
-	var targetInSight bool
-	var searchedForTarget bool
-	// targetSqDist := -1
-
-	// 2. handle any state changes based on seenUnits
-	switch mob.Aggression {
-	case AggressionPassive, AggressionDefends:
-		break
-
-	case AggressionAttacks, AggressionAttacksMobs, AggressionBlindRage:
-		// TODO: handle AttacksMobs and BlindRage
-		if mob.Target == nil {
-			mi := mob.I
-			mj := mob.J
-
-			// FIXME: nearest should probably take into account
-			// pathfinding.
-			//
-			// TODO: implement pathfinding...
-
-			// look for the nearest player unit
-			var nearest Unit
-			var nearestSqDist int
-			for _, u := range seenUnits {
-				if pl, ok := u.(*Player); ok {
-					di := pl.I - mi
-					dj := pl.J - mj
-					sqdist := di*di + dj*dj
-					if nearest == nil || sqdist < nearestSqDist {
-						nearest = pl
-						nearestSqDist = sqdist
-					}
-				}
-			}
+	// handle events and state changes according to the mob's behavior
+	brain.think()
 
-			if nearest != nil {
-				mob.Target = nearest
-				targetInSight = true
-				searchedForTarget = true
-				mob.State = MobAttack
-				// targetSqDist = nearestSqDist
-			}
+	// stunned mobs can't move, attack, or use abilities
+	if mob.StunTick > 0 {
+		mob.StunTick--
 
-			// TODO: add log message
-		}
+		mob.Event = MobEventNone
+		mob.EventCause = nil
+		return
 	}
 
-	// if mob already has a target, check if the mob can see the target
-	if mob.Target != nil && !searchedForTarget {
-		for _, u := range seenUnits {
-			if u == mob.Target {
-				ti, tj, _, _ := mob.Target.GetPos()
-
-				// di := ti - mob.I
-				// dj := tj - mob.J
-				// targetSqDist = di*di + dj*dj
-				targetInSight = true
-
-				mob.LastTargetI = ti
-				mob.LastTargetJ = tj
-			}
-		}
-	}
-
-	// handle state transitions
-	switch mob.State {
-	case MobStill, MobSentry:
-		break
-
-	case MobPatrol:
-		if mob.Direc == 0 {
-			mob.State = MobWander
-		}
-
-	case MobAttack:
-		// FIXME: this isn't the right transition
-		if !mob.Target.IsAlive() {
-			mob.Target = nil
-		}
-
-		if mob.Target == nil {
-			mob.State = MobWander
-		} else if !targetInSight {
-			mob.State = MobSeekTarget
-			mob.SeekTick = mobInfo.SeekTargetRate
-		}
-
-	case MobSeekTarget:
-		// FIXME: this isn't the right transition
-		if mob.Target == nil || mob.SeekTick == 0 {
-			mob.State = MobWander
-			mob.Target = nil
-			mob.LastTargetI = -1
-			mob.LastTargetJ = -1
-		} else if targetInSight {
-			mob.State = MobAttack
-		}
-
-	case MobFlee:
-		switch mob.Aggression {
-		case AggressionAttacks, AggressionAttacksMobs, AggressionBlindRage:
-			stats := mob.GetStats()
-			if stats.HP > stats.MaxHP/2 {
-				mob.State = MobAttack
-			}
-		}
+	// hasted mobs count down their timers faster
+	if speed := int16(mob.Effects.Modifiers().Speed); speed > 0 {
+		mob.MoveTick -= speed
+		mob.AttackTick -= speed
 	}
 
 	// 3. Actually move, attack, use ability, etc.
@@ -1038,34 +1139,41 @@ func (g *Game) mobUpdate(mob *Mob) {
 				weaponItem = BareHands
 			}
 
-			var attackRate int16 = 12
-			if w, ok := weaponItem.(*MeleeWeapon); ok {
-				attackRate = int16(w.swingTicks)
-			}
-
 			// FIXME: weapon length
 			if sqDist > 1 {
-				mob.AttackTick = attackRate
+				mob.AttackTick = mobAttackRate(weaponItem)
 				if mob.MoveTick--; mob.MoveTick <= 0 {
-					g.mobMoveRelative(mob, ti, tj, MoveCloser)
+					fi, fj := g.flankPosition(mob, ti, tj)
+					g.mobMoveRelative(mob, fi, fj, MoveCloser)
 					mob.MoveTick = mobInfo.MoveRate
 				}
 			} else {
 				mob.MoveTick = mobInfo.MoveRate
 				if mob.AttackTick--; mob.AttackTick <= 0 {
-					mob.AttackTick = attackRate
-					g.meleeAttack(mob, mob.Target, mob.Weapon)
+					weaponItem = brain.chooseAttack()
+					mob.AttackTick = mobAttackRate(weaponItem)
+					g.meleeAttack(mob, mob.Target, weaponItem)
 				}
 			}
 		}
 
+	case MobFollow:
+		if !g.mobFollow(mob, mobInfo.MoveRate) {
+			mob.State = MobWander
+		}
+
 	case MobFlee:
+		if mob.Target == nil {
+			break
+		}
+
 		ti, tj, _, _ := mob.Target.GetPos()
 		di := ti - mob.I
 		dj := tj - mob.J
 		sqDist := di*di + dj*dj
 
-		if sqDist < 50 {
+		fleeDist := brain.behavior.FleeDistance
+		if sqDist < fleeDist*fleeDist {
 			if mob.MoveTick--; mob.MoveTick <= 0 {
 				mob.MoveTick = mobInfo.ChaseRate // TODO: add a flee rate
 
@@ -1094,13 +1202,15 @@ func (g *Game) loopInner() {
 
 	/*** Game loop ***/
 
+	g.updateLinkdead()
+
 	// user actions
 	for _, act := range g.pendingActions {
 		if act.Type == Nothing {
 			continue
 		}
 
-		g.handleAction(act)
+		g.applyAction(act)
 	}
 	g.pendingActions = g.pendingActions[:0]
 
@@ -1109,7 +1219,14 @@ func (g *Game) loopInner() {
 	g.EffectsOverlay = g.EffectsOverlay[:0]
 
 	// player actions
-	for _, pl := range g.Players {
+	for _, pl := range g.playerList() {
+		if pl.Dead || !pl.IsAlive() {
+			g.updateDeadPlayer(pl)
+			continue
+		}
+
+		g.updateStatusEffects(pl)
+
 		if pl.BusyTick > 0 {
 			pl.BusyTick--
 		}
@@ -1138,6 +1255,10 @@ func (g *Game) loopInner() {
 	// update mobs
 	for i := range g.Mobs {
 		mob := &g.Mobs[i]
+		if mob.IsAlive() {
+			g.updateStatusEffects(mob)
+		}
+
 		g.mobUpdate(mob)
 	}
 
@@ -1158,8 +1279,9 @@ func (g *Game) sendUpdate() {
 		g.mu.RLock()
 		defer g.mu.RUnlock()
 
-		active := make([]Session, len(g.Active))
+		active := make([]Session, len(g.Active), len(g.Active)+len(g.Spectators))
 		copy(active, g.Active)
+		active = append(active, g.Spectators...)
 
 		return active
 	})()
@@ -1179,7 +1301,9 @@ func (g *Game) Loop() {
 
 GameLoop:
 	for {
-		g.loopInner()
+		if g.takeTick() {
+			g.loopInner()
+		}
 
 		select {
 		case <-doneCh:
@@ -1193,29 +1317,6 @@ GameLoop:
 	}
 }
 
-func (g *Game) Command(sess Session, txt string) error {
-	switch {
-	case txt == "/quit":
-		sess.Quit()
-
-	case txt == "/listmobs":
-		func() {
-			g.Lock()
-			defer g.Unlock()
-
-			for i := range g.Mobs {
-				m := &g.Mobs[i]
-				g.messagef(chat.Info, "[%3d] %+v", i, m)
-			}
-		}()
-
-	default:
-		return ErrUnknownCommand
-	}
-
-	return nil
-}
-
 func (g *Game) Input(l chat.MsgLevel, txt string) error {
 	return g.Message(l, txt)
 }
@@ -1248,6 +1349,12 @@ func (g *Game) message(lvl chat.MsgLevel, s string) error {
 		}
 	}
 
+	for _, sess := range g.Spectators {
+		if err := sess.Message(lvl, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	g.GameLog.LogLine(lvl, s)
 
 	if errs == nil {
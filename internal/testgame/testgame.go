@@ -0,0 +1,99 @@
+// Package testgame sets up a small game and idle sessions for the tests of
+// the packages built on mpnethack.
+package testgame
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+// Lines kept in a session's log
+const LogLines = 64
+
+// Session that holds a player and never acts
+type Session struct {
+	name   string
+	g      *mpnethack.Game
+	player *mpnethack.Player
+	log    *chat.Log
+}
+
+func (s *Session) IsAdministrator() bool                       { return false }
+func (s *Session) HasGame() bool                               { return s.g != nil }
+func (s *Session) Game() *mpnethack.Game                       { return s.g }
+func (s *Session) Player() *mpnethack.Player                   { return s.player }
+func (s *Session) UserName() string                            { return s.name }
+func (s *Session) GetLog() *chat.Log                           { return s.log }
+func (s *Session) ConsoleInput(string)                         {}
+func (s *Session) Join(g *mpnethack.Game) error                { return nil }
+func (s *Session) Update() error                               { return nil }
+func (s *Session) Quit()                                       {}
+func (s *Session) Message(lvl chat.MsgLevel, txt string) error { return nil }
+
+// Has mpnethack.LookupItem return a rusty sword for every tag until the test
+// ends
+func LookupItem(t *testing.T) {
+	var loaded struct {
+		Weapons []mpnethack.MeleeWeapon `toml:"weapons"`
+	}
+
+	if _, err := toml.Decode(`
+[[weapons]]
+tag = "rusty_sword"
+name = "rusty sword"
+short_name = "rusty sword"
+damage = "1d4"
+swing_arc = 1
+swing_length = 1
+swing_ticks = 3
+`, &loaded); err != nil {
+		t.Fatalf("error loading weapon: %v", err)
+	}
+
+	lookup := mpnethack.LookupItem
+	t.Cleanup(func() { mpnethack.LookupItem = lookup })
+
+	sword := &loaded.Weapons[0]
+	mpnethack.LookupItem = func(tag string) (mpnethack.Item, error) { return sword, nil }
+}
+
+// Starts a game on a 12x12 level with an 8x8 room, where players start at
+// (5,5) and a still lemming stands at (3,3).  The game's loop is stopped so
+// nothing moves.
+func New(t *testing.T) *mpnethack.Game {
+	LookupItem(t)
+
+	lvl := mpnethack.SingleRoomLevel(12, 12, 8, 8)
+	lvl.PlayerI0, lvl.PlayerJ0 = 5, 5
+
+	stats := mpnethack.UnitStats{ArmorClass: 8, THAC0: 4, HP: 10, MaxHP: 10}
+	if err := lvl.AddMob(mpnethack.MobLemming, stats, 3, 3, mpnethack.Down, mpnethack.MobStill); err != nil {
+		t.Fatalf("error adding mob: %v", err)
+	}
+
+	g, err := mpnethack.NewGame(lvl)
+	if err != nil {
+		t.Fatalf("error starting game: %v", err)
+	}
+
+	g.Cancel()
+	g.Shutdown()
+
+	return g
+}
+
+// Joins a new session to the game
+func Join(t *testing.T, g *mpnethack.Game, name string) *Session {
+	sess := &Session{name: name, g: g, log: chat.NewLog(LogLines)}
+
+	var err error
+	if sess.player, err = g.PlayerJoin(sess); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+
+	return sess
+}
@@ -126,6 +126,8 @@ type MeleeWeapon struct {
 	swingArc    int
 	swingLength int
 	swingTicks  int
+
+	onHit StatusEffectSpec
 }
 
 func (w *MeleeWeapon) DamageRoll(u Unit) Roll {
@@ -140,6 +142,11 @@ func (w *MeleeWeapon) SwingStats() (arc int, length int, ticks int) {
 	return w.swingArc, w.swingLength, w.swingTicks
 }
 
+// Status effect applied to units hit by the weapon
+func (w *MeleeWeapon) OnHitEffect() StatusEffectSpec {
+	return w.onHit
+}
+
 func (w *MeleeWeapon) UnmarshalTOML(data interface{}) error {
 	*w = MeleeWeapon{}
 	if err := w.BasicItem.UnmarshalTOML(data); err != nil {
@@ -152,6 +159,7 @@ func (w *MeleeWeapon) UnmarshalTOML(data interface{}) error {
 		"swing_arc":          &w.swingArc,
 		"swing_length":       &w.swingLength,
 		"swing_ticks":        &w.swingTicks,
+		"on_hit":             &w.onHit,
 	}, config.NoFlags)
 }
 
@@ -159,3 +167,49 @@ var _ Item = &MeleeWeapon{}
 
 var LookupItem func(tag string) (Item, error)
 var BareHands *MeleeWeapon
+
+// Single-use item, like a potion, food or scroll.  Using a consumable applies
+// every effect it defines.
+type Consumable struct {
+	BasicItem
+
+	UseDescription string
+
+	heal      Roll
+	status    StatusEffectSpec
+	teleport  bool
+	revealMap bool
+}
+
+func (c *Consumable) HealRoll() Roll {
+	return c.heal
+}
+
+func (c *Consumable) StatusEffect() StatusEffectSpec {
+	return c.status
+}
+
+func (c *Consumable) Teleports() bool {
+	return c.teleport
+}
+
+func (c *Consumable) RevealsMap() bool {
+	return c.revealMap
+}
+
+func (c *Consumable) UnmarshalTOML(data interface{}) error {
+	*c = Consumable{}
+	if err := c.BasicItem.UnmarshalTOML(data); err != nil {
+		return err
+	}
+
+	return config.UnmarshalHelper(data, map[string]interface{}{
+		"use_description": &c.UseDescription,
+		"heal":            &c.heal,
+		"effect":          &c.status,
+		"teleport":        &c.teleport,
+		"reveal_map":      &c.revealMap,
+	}, config.NoFlags)
+}
+
+var _ Item = &Consumable{}
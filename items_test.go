@@ -89,6 +89,7 @@ damage = "1d1"
 swing_arc = 0
 swing_length = 1
 swing_ticks = 2
+on_hit = { effect = "poison", duration = 50, magnitude = 1, chance = 5 }
 
 `)
 
@@ -130,6 +131,12 @@ swing_ticks = 2
 			swingArc:          0,
 			swingLength:       1,
 			swingTicks:        2,
+			onHit: StatusEffectSpec{
+				Type:      StatusPoison,
+				Duration:  50,
+				Magnitude: 1,
+				Chance:    5,
+			},
 		},
 	}
 
@@ -144,3 +151,76 @@ swing_ticks = 2
 		}
 	}
 }
+
+func TestUnmarshalConsumableFromTOML(t *testing.T) {
+	sr := strings.NewReader(`
+[[consumables]]
+tag             = "healing_potion"
+name            = "potion of healing"
+short_name      = "healing potion"
+description     = "A murky red liquid."
+weight          = 1
+use_description = "It tastes awful."
+heal            = "2d4"
+
+[[consumables]]
+tag         = "scroll_of_haste"
+name        = "scroll of haste"
+short_name  = "haste scroll"
+description = "The words blur as you read them."
+weight      = 0
+effect      = { effect = "haste", duration = 100 }
+teleport    = true
+reveal_map  = true
+`)
+
+	var loaded struct {
+		Consumables []Consumable `toml:"consumables"`
+	}
+
+	dec := toml.NewDecoder(sr)
+	if _, err := dec.Decode(&loaded); err != nil {
+		t.Errorf("error loading items: %v", err)
+		return
+	}
+
+	expected := []Consumable{
+		{
+			BasicItem: BasicItem{
+				tag:         "healing_potion",
+				name:        "potion of healing",
+				shortName:   "healing potion",
+				description: "A murky red liquid.",
+				weight:      1,
+			},
+			UseDescription: "It tastes awful.",
+			heal:           Roll{M: 2, N: 4},
+		},
+		{
+			BasicItem: BasicItem{
+				tag:         "scroll_of_haste",
+				name:        "scroll of haste",
+				shortName:   "haste scroll",
+				description: "The words blur as you read them.",
+				weight:      0,
+			},
+			status: StatusEffectSpec{
+				Type:     StatusHaste,
+				Duration: 100,
+			},
+			teleport:  true,
+			revealMap: true,
+		},
+	}
+
+	if len(loaded.Consumables) != len(expected) {
+		t.Errorf("expected %d consumables, but found %d consumables", len(expected), len(loaded.Consumables))
+		return
+	}
+
+	for i := range loaded.Consumables {
+		if loaded.Consumables[i] != expected[i] {
+			t.Errorf("consumable %d: expected %+v but found %+v", i, expected[i], loaded.Consumables[i])
+		}
+	}
+}
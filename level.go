@@ -96,7 +96,8 @@ type Board struct {
 
 type Level struct {
 	Board
-	Mobs []Mob
+	Mobs  []Mob
+	Items []FloorItem
 
 	PlayerI0, PlayerJ0 int
 }
@@ -185,6 +186,23 @@ func SingleRoomLevel(height, width, roomHeight, roomWidth int) *Level {
 	return lvl
 }
 
+var levelBuilders = map[string]func() *Level{}
+
+// Registers a function that builds a named level.  Replays refer to levels
+// by name, so a level must be registered for its games to be replayed.
+func RegisterLevel(name string, build func() *Level) {
+	levelBuilders[name] = build
+}
+
+func BuildLevel(name string) (*Level, error) {
+	build := levelBuilders[name]
+	if build == nil {
+		return nil, fmt.Errorf("unknown level \"%s\"", name)
+	}
+
+	return build(), nil
+}
+
 func (l *Level) AddMob(mobType MobType, stats UnitStats, i, j int, direc Direction, state MobState, args ...int16) error {
 	info, err := LookupMobInfo(mobType)
 	if err != nil {
@@ -222,3 +240,18 @@ func (l *Level) AddMob(mobType MobType, stats UnitStats, i, j int, direc Directi
 
 	return nil
 }
+
+func (l *Level) AddItem(tag string, i, j int) error {
+	itm, err := LookupItem(tag)
+	if err != nil {
+		return fmt.Errorf("error looking up item tag \"%s\": %w", tag, err)
+	}
+
+	if itm == nil {
+		return fmt.Errorf("unknown item tag \"%s\"", tag)
+	}
+
+	l.Items = append(l.Items, FloorItem{I: i, J: j, Item: itm})
+
+	return nil
+}
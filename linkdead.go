@@ -0,0 +1,182 @@
+package mpnethack
+
+import (
+	"strings"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+// Called when a session's connection drops.  The player is marked linkdead
+// and stays in the game for Rules.LinkdeadTicks, so the user can reconnect
+// to the character.  Does nothing if the player has already left.
+func (g *Game) PlayerDisconnect(sess Session) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pl := sess.Player()
+	if pl == nil || pl.S != sess || g.Players[sess.UserName()] != pl {
+		return
+	}
+
+	if g.Rules.LinkdeadTicks <= 0 {
+		g.removePlayer(pl)
+		return
+	}
+
+	pl.Linkdead = true
+	pl.LinkdeadTick = g.Rules.LinkdeadTicks
+
+	g.dropPendingActions(pl)
+	g.Active = removeSession(g.Active, sess)
+
+	g.messagef(chat.Info, "%s has lost their connection", pl.Name())
+	pl.linkdeadSeq = g.GameLog.NextSeq()
+	if log := sess.GetLog(); log != nil {
+		pl.linkdeadLogSeq = log.NextSeq()
+	}
+}
+
+func (g *Game) IsLinkdead(name string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	pl := g.Players[name]
+	return pl != nil && pl.Linkdead
+}
+
+// Removes a linkdead player whose name matches, ignoring case.  Reports
+// whether there was one.
+func (g *Game) RemoveLinkdead(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for plName, pl := range g.Players {
+		if pl.Linkdead && strings.EqualFold(plName, name) {
+			g.removePlayer(pl)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Removes the user's linkdead players from every game.  Reports whether
+// there were any.
+func (l *Lobby) removeLinkdead(name string) bool {
+	l.mu.Lock()
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	removed := false
+	for _, g := range games {
+		if g.RemoveLinkdead(name) {
+			removed = true
+		}
+	}
+
+	return removed
+}
+
+// Counts down linkdead players, and removes those whose users haven't come
+// back.  Runs at the start of a tick, where replays apply leave events.
+//
+// Assumes the write lock is held
+func (g *Game) updateLinkdead() {
+	for _, pl := range g.playerList() {
+		if !pl.Linkdead {
+			continue
+		}
+
+		if pl.LinkdeadTick--; pl.LinkdeadTick <= 0 {
+			g.removePlayer(pl)
+		}
+	}
+}
+
+// Gives a linkdead player to a new session for the same user, and replays
+// the log lines the user missed.  Returns nil if the user has no linkdead
+// player.
+//
+// Assumes the write lock is held
+func (g *Game) reattachPlayer(sess Session) *Player {
+	name := sess.UserName()
+
+	pl := g.Players[name]
+	if pl == nil || !pl.Linkdead {
+		return nil
+	}
+
+	old := pl.S
+
+	pl.S = sess
+	pl.Linkdead = false
+	pl.LinkdeadTick = 0
+	g.Active = append(g.Active, sess)
+
+	// lines the old session saw before the drop, then what was sent to
+	// it and to the game afterwards
+	oldLog := old.GetLog()
+	if oldLog != nil {
+		oldLog.VisitLines(0, func(msg chat.Message) bool {
+			if msg.Seq < pl.linkdeadLogSeq {
+				sess.Message(msg.Level, msg.Text)
+			}
+			return true
+		})
+	}
+
+	sess.Message(chat.System, "--- while you were away ---")
+	if oldLog != nil {
+		oldLog.VisitLines(0, func(msg chat.Message) bool {
+			if msg.Seq >= pl.linkdeadLogSeq {
+				sess.Message(msg.Level, msg.Text)
+			}
+			return true
+		})
+	}
+
+	g.GameLog.VisitLines(0, func(msg chat.Message) bool {
+		if msg.Seq >= pl.linkdeadSeq {
+			sess.Message(msg.Level, msg.Text)
+		}
+		return true
+	})
+
+	g.messagef(chat.Info, "%s has reconnected", name)
+
+	return pl
+}
+
+// Game with a linkdead player for the user, if any
+func (l *Lobby) linkdeadGame(name string) *Game {
+	l.mu.Lock()
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	for _, g := range games {
+		if g.IsLinkdead(name) {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// Reattaches a newly connected session to its user's linkdead player, if
+// there is one.  Returns the game, or nil if the user had no linkdead player.
+func (l *Lobby) Reconnect(sess Session) (*Game, error) {
+	g := l.linkdeadGame(sess.UserName())
+	if g == nil {
+		return nil, nil
+	}
+
+	if err := sess.Join(g); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.removeSession(sess)
+	l.mu.Unlock()
+
+	return g, nil
+}
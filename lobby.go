@@ -9,6 +9,22 @@ type Lobby struct {
 	Sessions []Session
 	Games    []*Game
 
+	// Rules for new games.  If nil, DefaultGameRules is used.
+	Rules *GameRules
+
+	// chat channel members, and who each session last heard from
+	channels map[string][]Session
+	replyTo  map[Session]string
+
+	// banned user names, in lower case
+	banned map[string]bool
+
+	// Record new games for replay
+	RecordReplays bool
+
+	// Called by Shutdown once the games have stopped
+	OnShutdown func()
+
 	mu sync.Mutex
 }
 
@@ -17,6 +33,58 @@ func (l *Lobby) NewGame(sess Session) (*Game, error) {
 		return g, nil
 	}
 
+	if g := l.waitingGame(sess.UserName()); g != nil {
+		if err := sess.Join(g); err != nil {
+			return nil, err
+		}
+
+		l.mu.Lock()
+		l.removeSession(sess)
+		l.mu.Unlock()
+
+		return g, nil
+	}
+
+	rules := DefaultGameRules
+	if l.Rules != nil {
+		rules = *l.Rules
+	}
+
+	dice, err := NewDice()
+	if err != nil {
+		return nil, err
+	}
+
+	g := newGame(LobbyLevel(), rules, dice)
+	g.LevelName = LobbyLevelName
+	if l.RecordReplays {
+		g.recorder = NewReplayRecorder(g)
+	}
+
+	go g.Loop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Games = append(l.Games, g)
+	err = sess.Join(g)
+	if err != nil {
+		return nil, err
+	}
+
+	l.removeSession(sess)
+
+	return g, nil
+}
+
+const LobbyLevelName = "lobby"
+
+func init() {
+	RegisterLevel(LobbyLevelName, LobbyLevel)
+}
+
+// Level for games started from the lobby
+func LobbyLevel() *Level {
 	lvl := SingleRoomLevel(64, 128, 32, 64)
 
 	lvl.PlayerI0 = 64 / 2
@@ -39,18 +107,24 @@ func (l *Lobby) NewGame(sess Session) (*Game, error) {
 	}
 
 	mobs := []struct {
-		Type  MobType
-		Stats UnitStats
-		I, J  int
-		Direc Direction
-		State MobState
+		Type   MobType
+		Stats  UnitStats
+		I, J   int
+		Direc  Direction
+		State  MobState
+		Pack   int
+		Leader bool
 	}{
-		{MobLemming, lemmingStats, 18, 34, Down, MobPatrol},
-		{MobLemming, lemmingStats, 18, 45, Right, MobPatrol},
-		{MobLemming, lemmingStats, 45, 92, Up, MobPatrol},
-		{MobViciousLemming, viciousLemmingStats, 18, 92, Left, MobWander},
+		{MobLemming, lemmingStats, 18, 34, Down, MobPatrol, 0, false},
+		{MobLemming, lemmingStats, 18, 45, Right, MobPatrol, 0, false},
+		{MobLemming, lemmingStats, 45, 92, Up, MobPatrol, 0, false},
+
+		// a pack of vicious lemmings
+		{MobViciousLemming, viciousLemmingStats, 18, 92, Left, MobWander, 1, true},
+		{MobViciousLemming, viciousLemmingStats, 20, 93, Left, MobFollow, 1, false},
+		{MobViciousLemming, viciousLemmingStats, 16, 93, Left, MobFollow, 1, false},
 
-		{MobViciousLemming, viciousLemmingStats, lvl.PlayerI0, lvl.PlayerJ0 + 3, Right /* NoDirection */, MobSentry},
+		{MobViciousLemming, viciousLemmingStats, lvl.PlayerI0, lvl.PlayerJ0 + 3, Right /* NoDirection */, MobSentry, 0, false},
 	}
 
 	for _, m := range mobs {
@@ -58,34 +132,33 @@ func (l *Lobby) NewGame(sess Session) (*Game, error) {
 		if err != nil {
 			log.Printf("error adding mob \"%v\" @ %d,%d [state=%v]: %v",
 				m.Type, m.I, m.J, m.Direc, err)
+			continue
 		}
-	}
 
-	lvl.Set(lvl.PlayerI0, lvl.PlayerJ0-3, MarkerCactus)
-	lvl.Set(lvl.PlayerI0-2, lvl.PlayerJ0, MarkerCactus)
-
-	g, err := NewGame(lvl)
-	if err != nil {
-		return nil, err
+		lvl.JoinPack(len(lvl.Mobs)-1, m.Pack, m.Leader)
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	l.Games = append(l.Games, g)
-	err = sess.Join(g)
-	if err != nil {
-		return nil, err
+	items := []struct {
+		Tag  string
+		I, J int
+	}{
+		{"healing_potion", lvl.PlayerI0 + 2, lvl.PlayerJ0},
+		{"lemming_jerky", lvl.PlayerI0 + 2, lvl.PlayerJ0 + 2},
+		{"scroll_of_teleport", 20, 40},
+		{"scroll_of_mapping", 40, 80},
 	}
 
-	for i, s := range l.Sessions {
-		if s == sess {
-			l.Sessions = append(l.Sessions[:i], l.Sessions[:i+1]...)
-			break
+	for _, itm := range items {
+		err := lvl.AddItem(itm.Tag, itm.I, itm.J)
+		if err != nil {
+			log.Printf("error adding item \"%s\" @ %d,%d: %v", itm.Tag, itm.I, itm.J, err)
 		}
 	}
 
-	return g, nil
+	lvl.Set(lvl.PlayerI0, lvl.PlayerJ0-3, MarkerCactus)
+	lvl.Set(lvl.PlayerI0-2, lvl.PlayerJ0, MarkerCactus)
+
+	return lvl
 }
 
 func (l *Lobby) AddSession(sess Session) {
@@ -0,0 +1,326 @@
+package mpnethack
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+type testSession struct {
+	name string
+	log  *chat.Log
+}
+
+func newTestSession(name string) *testSession {
+	return &testSession{name: name, log: chat.NewLog(32)}
+}
+
+func (s *testSession) IsAdministrator() bool { return false }
+func (s *testSession) HasGame() bool         { return false }
+func (s *testSession) Game() *Game           { return nil }
+func (s *testSession) Player() *Player       { return nil }
+func (s *testSession) UserName() string      { return s.name }
+func (s *testSession) GetLog() *chat.Log     { return s.log }
+func (s *testSession) ConsoleInput(string)   {}
+func (s *testSession) Join(g *Game) error    { return nil }
+func (s *testSession) Update() error         { return nil }
+func (s *testSession) Quit()                 {}
+
+func (s *testSession) Message(lvl chat.MsgLevel, txt string) error {
+	s.log.LogLine(lvl, txt)
+	return nil
+}
+
+func (s *testSession) lastLine() string {
+	if len(s.log.Lines) == 0 {
+		return ""
+	}
+
+	return s.log.Lines[s.log.LastLine].Text
+}
+
+func TestLobbyChat(t *testing.T) {
+	l := &Lobby{}
+	alice := newTestSession("alice")
+	bob := newTestSession("Bob")
+	l.AddSession(alice)
+	l.AddSession(bob)
+
+	if err := Commands.Execute(alice, l, "/tell bob hello there"); err != nil {
+		t.Fatalf("error sending /tell: %v", err)
+	}
+
+	if got := bob.lastLine(); got != "[from alice] hello there" {
+		t.Errorf("unexpected private message %q", got)
+	}
+
+	Commands.Execute(bob, l, "/reply hi")
+	if got := alice.lastLine(); got != "[from Bob] hi" {
+		t.Errorf("unexpected reply %q", got)
+	}
+
+	Commands.Execute(alice, l, "/join #Dev")
+	Commands.Execute(bob, l, "/join dev")
+	if err := l.ChannelMessage(alice, "#dev", "meeting"); err != nil {
+		t.Errorf("error sending channel message: %v", err)
+	}
+
+	if got := bob.lastLine(); got != "[#dev] alice: meeting" {
+		t.Errorf("unexpected channel message %q", got)
+	}
+
+	Commands.Execute(bob, l, "/leave dev")
+	if err := l.ChannelMessage(bob, "dev", "still here?"); err == nil {
+		t.Errorf("expected an error posting to a channel after leaving it")
+	}
+
+	l.Say(alice, "anyone?")
+	if got := bob.lastLine(); got != "alice: anyone?" {
+		t.Errorf("unexpected lobby chat %q", got)
+	}
+
+	who := l.Who()
+	if len(who) != 2 || who[0].Name != "alice" || who[1].Name != "Bob" {
+		t.Errorf("unexpected /who listing %+v", who)
+	}
+
+	l.RemoveSession(bob)
+	if err := l.Tell(alice, "bob", "gone?"); err == nil || !strings.Contains(err.Error(), "no such player") {
+		t.Errorf("expected no such player error, found %v", err)
+	}
+}
+
+type spectatorSession struct {
+	*testSession
+	g       *Game
+	follow  string
+	updates int
+}
+
+func (s *spectatorSession) HasGame() bool { return s.g != nil }
+func (s *spectatorSession) Game() *Game   { return s.g }
+func (s *spectatorSession) Update() error { s.updates++; return nil }
+
+func (s *spectatorSession) Spectate(g *Game, follow string) error {
+	if err := g.AddSpectator(s); err != nil {
+		return err
+	}
+
+	s.g, s.follow = g, follow
+	return nil
+}
+
+func (s *spectatorSession) StopSpectating() error {
+	if s.g == nil {
+		return ErrNotSpectating
+	}
+
+	err := s.g.RemoveSpectator(s)
+	s.g = nil
+	return err
+}
+
+func TestSpectator(t *testing.T) {
+	g, alice := newTestGame(t)
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+
+	l := &Lobby{Games: []*Game{g}}
+	carol := &spectatorSession{testSession: newTestSession("carol")}
+	l.AddSession(carol)
+
+	if err := l.Spectate(newTestSession("dave"), "alice"); err != ErrCannotSpectate {
+		t.Errorf("expected a plain session to be refused, found %v", err)
+	}
+
+	if err := Commands.Execute(carol, l, "/spectate alice"); err != nil {
+		t.Fatalf("error spectating: %v", err)
+	}
+
+	if carol.g != g || carol.follow != "alice" || !g.IsSpectator(carol) || len(l.Sessions) != 0 {
+		t.Fatalf("carol is not spectating alice's game")
+	}
+
+	g.sendUpdate()
+	if carol.updates != 1 {
+		t.Errorf("expected the spectator to be updated, found %d updates", carol.updates)
+	}
+
+	if err := g.UserAction(carol, Attack, 0); err != ErrSpectating {
+		t.Errorf("expected spectators to be refused actions, found %v", err)
+	}
+
+	if err := g.Say(carol, "hello"); err != ErrSpectatorChat {
+		t.Errorf("expected spectator chat to be refused, found %v", err)
+	}
+
+	g.Rules.SpectatorChat = true
+	HandleConsoleInput(carol, l, "hello")
+	if got := carol.lastLine(); got != "carol (spectating): hello" {
+		t.Errorf("unexpected spectator chat %q", got)
+	}
+
+	who := l.Who()
+	if len(who) != 2 || who[1].Name != "carol" || !strings.Contains(who[1].Where, "spectating") {
+		t.Errorf("unexpected /who listing %+v", who)
+	}
+
+	if err := Commands.Execute(carol, l, "/unspectate"); err != nil {
+		t.Fatalf("error leaving: %v", err)
+	}
+
+	if carol.g != nil || g.IsSpectator(carol) || len(l.Sessions) != 1 {
+		t.Errorf("carol is still spectating")
+	}
+}
+
+type limitedSession struct {
+	*testSession
+	input *InputLimiter
+}
+
+func (s *limitedSession) InputLimiter() *InputLimiter { return s.input }
+
+func TestInputLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	lim := NewInputLimiter(2, 3)
+	lim.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !lim.Allow() {
+			t.Fatalf("input %d of the burst was refused", i+1)
+		}
+	}
+
+	if lim.Allow() {
+		t.Errorf("input beyond the burst was allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !lim.Allow() {
+		t.Errorf("input was refused after the limiter refilled")
+	}
+
+	if lim.Allow() {
+		t.Errorf("limiter refilled too fast")
+	}
+
+	if NewInputLimiter(0, 10) != nil {
+		t.Errorf("expected no limiter for a zero rate")
+	}
+
+	l := &Lobby{}
+	alice := &limitedSession{testSession: newTestSession("alice"), input: lim}
+	bob := newTestSession("bob")
+	l.AddSession(alice)
+	l.AddSession(bob)
+
+	HandleConsoleInput(alice, l, "spam")
+	if got := bob.lastLine(); got != "" {
+		t.Errorf("rate limited chat was delivered: %q", got)
+	}
+
+	if got := alice.lastLine(); got != ErrInputRateLimited.Error() {
+		t.Errorf("expected a rate limit message, found %q", got)
+	}
+
+	now = now.Add(time.Second)
+	HandleConsoleInput(alice, l, "hello")
+	if got := bob.lastLine(); got != "alice: hello" {
+		t.Errorf("unexpected lobby chat %q", got)
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	lookup, list, save := LookupPlayerRecord, ListPlayerRecords, SavePlayerRecord
+	t.Cleanup(func() { LookupPlayerRecord, ListPlayerRecords, SavePlayerRecord = lookup, list, save })
+
+	var saved []string
+	SavePlayerRecord = func(rec *PlayerRecord) error {
+		saved = append(saved, rec.Name)
+		return nil
+	}
+
+	stored := []PlayerRecord{{Name: "zed", Level: 3, XP: 500}, {Name: "alice", Level: 1, XP: 1}}
+	LookupPlayerRecord = func(name string) (*PlayerRecord, error) {
+		for _, rec := range stored {
+			if rec.Name == name {
+				return &rec, nil
+			}
+		}
+
+		return nil, nil
+	}
+	ListPlayerRecords = func() ([]PlayerRecord, error) { return stored, nil }
+
+	g, alice := newTestGame(t)
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+	alice.pl.Stats.XP = 700
+	alice.pl.MobKills = 2
+
+	// bots aren't ranked or saved
+	bot := &recordlessSession{newReplaySession("wanderer1", g)}
+	if bot.pl, err = g.PlayerJoin(bot); err != nil {
+		t.Fatalf("error joining bot: %v", err)
+	}
+	bot.pl.Stats.XP = 900
+
+	l := &Lobby{Games: []*Game{g}}
+
+	games := l.GameList()
+	if len(games) != 1 || games[0].Level != "replay_test" || len(games[0].Players) != 2 || games[0].Players[0] != "alice" {
+		t.Errorf("unexpected game list %+v", games)
+	}
+
+	st, err := l.PlayerStats("ALICE")
+	if err != nil {
+		t.Fatalf("error getting stats: %v", err)
+	}
+
+	if st.Name != "alice" || !st.Online || st.XP != 700 || st.MobKills != 2 || st.Where != "game 1" {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	if st, err := l.PlayerStats("zed"); err != nil || st.Online || st.XP != 500 {
+		t.Errorf("unexpected stored stats %+v (error %v)", st, err)
+	}
+
+	if _, err := l.PlayerStats("nobody"); !errors.Is(err, ErrNoSuchPlayer) {
+		t.Errorf("expected no such player error, found %v", err)
+	}
+
+	scores, err := l.Scores(0)
+	if err != nil {
+		t.Fatalf("error getting scores: %v", err)
+	}
+
+	if len(scores) != 2 || scores[0].Name != "alice" || scores[0].XP != 700 || scores[1].Name != "zed" {
+		t.Errorf("unexpected scores %+v", scores)
+	}
+
+	if scores, _ := l.Scores(1); len(scores) != 1 {
+		t.Errorf("expected one score, found %+v", scores)
+	}
+
+	g.PlayerLeave(bot)
+	g.PlayerLeave(alice)
+	if len(saved) != 1 || saved[0] != "alice" {
+		t.Errorf("expected only alice's record saved, found %q", saved)
+	}
+}
+
+type recordlessSession struct {
+	*replaySession
+}
+
+func (s *recordlessSession) Recordless() bool { return true }
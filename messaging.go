@@ -0,0 +1,417 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrNoReplyTarget    = errors.New("nobody to reply to")
+	ErrBadChannelName   = errors.New("invalid channel name")
+	ErrNotInChannel     = errors.New("not in channel")
+	ErrAlreadyInChannel = errors.New("already in channel")
+)
+
+// Prefix that marks console input as a channel message ("#name text")
+const ChannelPrefix = '#'
+
+// All sessions connected to the lobby, whether or not they are in a game
+func (l *Lobby) AllSessions() []Session {
+	l.mu.Lock()
+	sessions := append([]Session{}, l.Sessions...)
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	for _, g := range games {
+		g.mu.RLock()
+		sessions = append(sessions, g.Active...)
+		sessions = append(sessions, g.Spectators...)
+		g.mu.RUnlock()
+	}
+
+	return sessions
+}
+
+// Finds a connected session by user name.  Names are matched without regard
+// to case.
+func (l *Lobby) FindSession(name string) Session {
+	for _, sess := range l.AllSessions() {
+		if strings.EqualFold(sess.UserName(), name) {
+			return sess
+		}
+	}
+
+	return nil
+}
+
+// Removes the session from the lobby and any channels it has joined
+func (l *Lobby) RemoveSession(sess Session) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeSession(sess)
+
+	for name, members := range l.channels {
+		l.channels[name] = removeSession(members, sess)
+	}
+
+	delete(l.replyTo, sess)
+}
+
+// Assumes the lock is held
+func (l *Lobby) removeSession(sess Session) {
+	l.Sessions = removeSession(l.Sessions, sess)
+}
+
+func removeSession(sessions []Session, sess Session) []Session {
+	for i, s := range sessions {
+		if s == sess {
+			return append(sessions[:i], sessions[i+1:]...)
+		}
+	}
+
+	return sessions
+}
+
+// Sends a chat message to every session that isn't in a game
+func (l *Lobby) Say(from Session, txt string) {
+	l.mu.Lock()
+	sessions := append([]Session{}, l.Sessions...)
+	l.mu.Unlock()
+
+	line := fmt.Sprintf("%s: %s", from.UserName(), txt)
+	for _, sess := range sessions {
+		sess.Message(chat.Chat, line)
+	}
+}
+
+// Sends a private message to the named user
+func (l *Lobby) Tell(from Session, to string, txt string) error {
+	dest := l.FindSession(to)
+	if dest == nil {
+		return fmt.Errorf("%w \"%s\"", ErrNoSuchPlayer, to)
+	}
+
+	l.mu.Lock()
+	if l.replyTo == nil {
+		l.replyTo = make(map[Session]string)
+	}
+	l.replyTo[dest] = from.UserName()
+	l.mu.Unlock()
+
+	dest.Message(chat.Private, fmt.Sprintf("[from %s] %s", from.UserName(), txt))
+	from.Message(chat.Private, fmt.Sprintf("[to %s] %s", dest.UserName(), txt))
+
+	return nil
+}
+
+// Replies to the last user who sent the session a private message
+func (l *Lobby) Reply(from Session, txt string) error {
+	l.mu.Lock()
+	to, ok := l.replyTo[from]
+	l.mu.Unlock()
+
+	if !ok {
+		return ErrNoReplyTarget
+	}
+
+	return l.Tell(from, to, txt)
+}
+
+func normalizeChannelName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, string(ChannelPrefix)))
+	if name == "" {
+		return "", ErrBadChannelName
+	}
+
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '_' && r != '-' {
+			return "", fmt.Errorf("%w \"%s\"", ErrBadChannelName, name)
+		}
+	}
+
+	return name, nil
+}
+
+func (l *Lobby) JoinChannel(sess Session, name string) error {
+	name, err := normalizeChannelName(name)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	if l.channels == nil {
+		l.channels = make(map[string][]Session)
+	}
+
+	members := l.channels[name]
+	for _, s := range members {
+		if s == sess {
+			l.mu.Unlock()
+			return fmt.Errorf("%w #%s", ErrAlreadyInChannel, name)
+		}
+	}
+
+	l.channels[name] = append(members, sess)
+	l.mu.Unlock()
+
+	l.channelMessage(name, fmt.Sprintf("%s joined the channel", sess.UserName()))
+	return nil
+}
+
+func (l *Lobby) LeaveChannel(sess Session, name string) error {
+	name, err := normalizeChannelName(name)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	members := l.channels[name]
+	remaining := removeSession(members, sess)
+	if len(remaining) == len(members) {
+		l.mu.Unlock()
+		return fmt.Errorf("%w #%s", ErrNotInChannel, name)
+	}
+
+	if len(remaining) == 0 {
+		delete(l.channels, name)
+	} else {
+		l.channels[name] = remaining
+	}
+	l.mu.Unlock()
+
+	sess.Message(chat.Info, fmt.Sprintf("You left #%s", name))
+	l.channelMessage(name, fmt.Sprintf("%s left the channel", sess.UserName()))
+	return nil
+}
+
+// Sends a message to the channel.  Only members may post to a channel.
+func (l *Lobby) ChannelMessage(from Session, name string, txt string) error {
+	name, err := normalizeChannelName(name)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	member := false
+	for _, s := range l.channels[name] {
+		if s == from {
+			member = true
+			break
+		}
+	}
+	l.mu.Unlock()
+
+	if !member {
+		return fmt.Errorf("%w #%s", ErrNotInChannel, name)
+	}
+
+	l.channelMessage(name, fmt.Sprintf("%s: %s", from.UserName(), txt))
+	return nil
+}
+
+func (l *Lobby) channelMessage(name string, txt string) {
+	l.mu.Lock()
+	members := append([]Session{}, l.channels[name]...)
+	l.mu.Unlock()
+
+	line := fmt.Sprintf("[#%s] %s", name, txt)
+	for _, sess := range members {
+		sess.Message(chat.Chat, line)
+	}
+}
+
+// Channels the session has joined
+func (l *Lobby) Channels(sess Session) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var names []string
+	for name, members := range l.channels {
+		for _, s := range members {
+			if s == sess {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+type WhoEntry struct {
+	Name  string `json:"name"`
+	Where string `json:"where"`
+}
+
+// Lists everyone connected, and where they are
+func (l *Lobby) Who() []WhoEntry {
+	l.mu.Lock()
+	lobbySessions := append([]Session{}, l.Sessions...)
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	var entries []WhoEntry
+	for _, sess := range lobbySessions {
+		entries = append(entries, WhoEntry{Name: sess.UserName(), Where: "lobby"})
+	}
+
+	for i, g := range games {
+		g.mu.RLock()
+		for _, sess := range g.Active {
+			where := fmt.Sprintf("game %d", i+1)
+			if pl := sess.Player(); pl != nil {
+				where = fmt.Sprintf("game %d, level %d", i+1, pl.Stats.Level)
+			}
+
+			entries = append(entries, WhoEntry{Name: sess.UserName(), Where: where})
+		}
+
+		for _, sess := range g.Spectators {
+			entries = append(entries, WhoEntry{Name: sess.UserName(), Where: fmt.Sprintf("game %d, spectating", i+1)})
+		}
+		g.mu.RUnlock()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+
+	return entries
+}
+
+// Handles a line typed at a session's console: slash commands, channel
+// messages, and chat to the session's game or the lobby.  Input beyond the
+// session's rate limit is dropped.
+func HandleConsoleInput(sess Session, lobby *Lobby, txt string) {
+	switch {
+	case txt == "":
+		/* nop */
+
+	case !allowInput(sess):
+		sess.Message(chat.Info, ErrInputRateLimited.Error())
+
+	case txt[0] == '/':
+		Commands.Dispatch(sess, lobby, txt)
+
+	case txt[0] == ChannelPrefix && lobby != nil:
+		name, msg := txt, ""
+		if ind := strings.IndexByte(txt, ' '); ind >= 0 {
+			name, msg = txt[:ind], strings.TrimSpace(txt[ind+1:])
+		}
+
+		if msg == "" {
+			sess.Message(chat.Info, "usage: #channel <message>")
+		} else if err := lobby.ChannelMessage(sess, name, msg); err != nil {
+			sess.Message(chat.Info, err.Error())
+		}
+
+	case sess.Game() != nil:
+		if err := sess.Game().Say(sess, txt); err != nil {
+			sess.Message(chat.Info, err.Error())
+		}
+
+	case lobby != nil:
+		lobby.Say(sess, txt)
+	}
+}
+
+var ErrNoLobby = errors.New("not connected to a lobby")
+
+func registerChatCommands(r *CommandRegistry) {
+	r.MustRegister(&Command{
+		Name:    "tell",
+		Aliases: []string{"t", "msg"},
+		Args:    []ArgSpec{{Name: "player"}, {Name: "message", Rest: true}},
+		Help:    "Sends a private message",
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			return ctx.Lobby.Tell(ctx.Session, ctx.Arg("player"), ctx.Arg("message"))
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:    "reply",
+		Aliases: []string{"r"},
+		Args:    []ArgSpec{{Name: "message", Rest: true}},
+		Help:    "Replies to the last private message",
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			return ctx.Lobby.Reply(ctx.Session, ctx.Arg("message"))
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name: "who",
+		Help: "Lists everyone connected",
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			entries := ctx.Lobby.Who()
+			ctx.Reply(chat.Info, "%d connected:", len(entries))
+			for _, ent := range entries {
+				ctx.Reply(chat.Info, "  %-24s %s", ent.Name, ent.Where)
+			}
+
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name: "join",
+		Args: []ArgSpec{{Name: "channel"}},
+		Help: "Joins a chat channel; post to it with #channel <message>",
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			return ctx.Lobby.JoinChannel(ctx.Session, ctx.Arg("channel"))
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name: "leave",
+		Args: []ArgSpec{{Name: "channel"}},
+		Help: "Leaves a chat channel",
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			return ctx.Lobby.LeaveChannel(ctx.Session, ctx.Arg("channel"))
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name: "channels",
+		Help: "Lists the chat channels you have joined",
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			names := ctx.Lobby.Channels(ctx.Session)
+			if len(names) == 0 {
+				ctx.Reply(chat.Info, "You are not in any channels")
+			} else {
+				ctx.Reply(chat.Info, "Channels: #%s", strings.Join(names, ", #"))
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,606 @@
+package mpnethack
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+// Conditions that mob behavior transitions can check
+type MobCondition int
+
+const (
+	MobCondAttacked MobCondition = iota + 1
+	MobCondHit
+	MobCondBadlyHurt
+	MobCondStunned
+	MobCondHasCause
+	MobCondHasTarget
+	MobCondNoTarget
+	MobCondTargetVisible
+	MobCondTargetLost
+	MobCondSeesPlayer
+	MobCondSeekExpired
+	MobCondHurt
+	MobCondRecovered
+	MobCondNoDirection
+	MobCondSeesHostile
+	MobCondCausedByMob
+	MobCondHasThreat
+	MobCondThreatShifted
+	MobCondPackAlert
+	MobCondFriendDied
+	MobCondHasLeader
+)
+
+var mobConditionNames = map[MobCondition]string{
+	MobCondAttacked:      "attacked",
+	MobCondHit:           "hit",
+	MobCondBadlyHurt:     "badly_hurt",
+	MobCondStunned:       "stunned",
+	MobCondHasCause:      "has_cause",
+	MobCondHasTarget:     "has_target",
+	MobCondNoTarget:      "no_target",
+	MobCondTargetVisible: "target_visible",
+	MobCondTargetLost:    "target_lost",
+	MobCondSeesPlayer:    "sees_player",
+	MobCondSeekExpired:   "seek_expired",
+	MobCondHurt:          "hurt",
+	MobCondRecovered:     "recovered",
+	MobCondNoDirection:   "no_direction",
+	MobCondSeesHostile:   "sees_hostile",
+	MobCondCausedByMob:   "caused_by_mob",
+	MobCondHasThreat:     "has_threat",
+	MobCondThreatShifted: "threat_shifted",
+	MobCondPackAlert:     "pack_alert",
+	MobCondFriendDied:    "friend_died",
+	MobCondHasLeader:     "has_leader",
+}
+
+func (c MobCondition) String() string {
+	if name, ok := mobConditionNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("condition_%d", int(c))
+}
+
+func (c *MobCondition) UnmarshalText(text []byte) error {
+	s := string(text)
+	for cond, name := range mobConditionNames {
+		if name == s {
+			*c = cond
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown mob condition \"%s\"", s)
+}
+
+// How a behavior transition picks the mob's target
+type MobTargetChoice int
+
+const (
+	MobTargetKeep MobTargetChoice = iota
+	MobTargetCause
+	MobTargetNearestPlayer
+	MobTargetNone
+	MobTargetNearestHostile
+	MobTargetMostThreat
+)
+
+func (tc MobTargetChoice) String() string {
+	switch tc {
+	case MobTargetKeep:
+		return "keep"
+	case MobTargetCause:
+		return "cause"
+	case MobTargetNearestPlayer:
+		return "nearest_player"
+	case MobTargetNone:
+		return "none"
+	case MobTargetNearestHostile:
+		return "nearest_hostile"
+	case MobTargetMostThreat:
+		return "most_threat"
+	default:
+		return fmt.Sprintf("target_%d", int(tc))
+	}
+}
+
+func (tc *MobTargetChoice) UnmarshalText(text []byte) error {
+	s := string(text)
+	switch s {
+	case "keep":
+		*tc = MobTargetKeep
+	case "cause":
+		*tc = MobTargetCause
+	case "nearest_player":
+		*tc = MobTargetNearestPlayer
+	case "none":
+		*tc = MobTargetNone
+	case "nearest_hostile":
+		*tc = MobTargetNearestHostile
+	case "most_threat":
+		*tc = MobTargetMostThreat
+	default:
+		return fmt.Errorf("unknown mob target choice \"%s\"", s)
+	}
+
+	return nil
+}
+
+type MobTransition struct {
+	From       []MobState     `toml:"from"`
+	On         []MobCondition `toml:"on"`
+	When       []MobCondition `toml:"when"`
+	Aggression []Aggression   `toml:"aggression"`
+
+	// New state.  If nil, the state is unchanged.
+	To     *MobState       `toml:"to"`
+	Target MobTargetChoice `toml:"target"`
+
+	// New aggression.  If nil, the aggression is unchanged.
+	Escalate *Aggression `toml:"escalate"`
+}
+
+type MobAttackChoice struct {
+	Weapon string `toml:"weapon"`
+	Weight int    `toml:"weight"`
+}
+
+// Data-driven description of how a mob reacts to the world
+type MobBehavior struct {
+	Tag string `toml:"tag"`
+
+	// Tag of a behavior whose transitions are checked after this
+	// behavior's transitions.  Unset thresholds and attacks are also taken
+	// from it.
+	Extends string `toml:"extends"`
+
+	FleeBelowHP  int `toml:"flee_below_hp"`
+	RallyAboveHP int `toml:"rally_above_hp"`
+	FleeDistance int `toml:"flee_distance"`
+
+	Attacks     []MobAttackChoice `toml:"attacks"`
+	Transitions []MobTransition   `toml:"transitions"`
+}
+
+const DefaultMobBehaviorTag = "lemming"
+
+//go:embed mob_behaviors.toml
+var builtinMobBehaviors string
+
+var mobBehaviors = map[string]*MobBehavior{}
+
+func init() {
+	if err := LoadMobBehaviors(strings.NewReader(builtinMobBehaviors)); err != nil {
+		panic(fmt.Sprintf("error loading builtin mob behaviors: %v", err))
+	}
+}
+
+// Loads the behaviors defined in a TOML file.  Behaviors replace any
+// behaviors with the same tag.
+func LoadMobBehaviors(r io.Reader) error {
+	var loaded struct {
+		Behaviors []MobBehavior `toml:"behaviors"`
+	}
+
+	dec := toml.NewDecoder(r)
+	if _, err := dec.Decode(&loaded); err != nil {
+		return fmt.Errorf("error decoding mob behaviors: %w", err)
+	}
+
+	for i := range loaded.Behaviors {
+		b := &loaded.Behaviors[i]
+		if b.Tag == "" {
+			return fmt.Errorf("mob behavior %d has no tag", i)
+		}
+
+		if err := b.inherit(); err != nil {
+			return err
+		}
+
+		AddMobBehavior(b)
+	}
+
+	return nil
+}
+
+// Fills in the parts of the behavior that come from the behavior it extends.
+// The extended behavior must already be loaded.
+func (b *MobBehavior) inherit() error {
+	if b.Extends == "" {
+		return nil
+	}
+
+	base := mobBehaviors[b.Extends]
+	if base == nil {
+		return fmt.Errorf("mob behavior \"%s\" extends unknown behavior \"%s\"", b.Tag, b.Extends)
+	}
+
+	if b.FleeBelowHP == 0 {
+		b.FleeBelowHP = base.FleeBelowHP
+	}
+
+	if b.RallyAboveHP == 0 {
+		b.RallyAboveHP = base.RallyAboveHP
+	}
+
+	if b.FleeDistance == 0 {
+		b.FleeDistance = base.FleeDistance
+	}
+
+	if len(b.Attacks) == 0 {
+		b.Attacks = base.Attacks
+	}
+
+	b.Transitions = append(b.Transitions, base.Transitions...)
+	return nil
+}
+
+func AddMobBehavior(b *MobBehavior) {
+	mobBehaviors[b.Tag] = b
+}
+
+func LookupMobBehavior(tag string) (*MobBehavior, error) {
+	if tag == "" {
+		tag = DefaultMobBehaviorTag
+	}
+
+	b := mobBehaviors[tag]
+	if b == nil {
+		return nil, fmt.Errorf("unknown mob behavior \"%s\"", tag)
+	}
+
+	return b, nil
+}
+
+// Behavior of the mob type.  Falls back to the default behavior if the
+// mob type's behavior can't be found.
+func (mi *MobInfo) Behavior() *MobBehavior {
+	b, err := LookupMobBehavior(mi.BehaviorTag)
+	if err != nil {
+		log.Printf("mob type \"%s\": %v", mi.Name, err)
+		b, _ = LookupMobBehavior(DefaultMobBehaviorTag)
+	}
+
+	return b
+}
+
+func containsState(states []MobState, st MobState) bool {
+	for _, s := range states {
+		if s == st {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAggression(aggs []Aggression, agg Aggression) bool {
+	for _, a := range aggs {
+		if a == agg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// State used to evaluate a behavior for a mob during one tick
+type mobBrain struct {
+	g        *Game
+	mob      *Mob
+	info     *MobInfo
+	behavior *MobBehavior
+	seen     []Unit
+}
+
+func (b *mobBrain) targetVisible() bool {
+	if b.mob.Target == nil {
+		return false
+	}
+
+	for _, u := range b.seen {
+		if u == b.mob.Target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FIXME: nearest should probably take into account pathfinding.
+//
+// TODO: implement pathfinding...
+func (b *mobBrain) nearestPlayer() *Player {
+	mi := b.mob.I
+	mj := b.mob.J
+
+	var nearest *Player
+	var nearestSqDist int
+	for _, u := range b.seen {
+		if pl, ok := u.(*Player); ok && pl.IsAlive() {
+			di := pl.I - mi
+			dj := pl.J - mj
+			sqdist := di*di + dj*dj
+			if nearest == nil || sqdist < nearestSqDist {
+				nearest = pl
+				nearestSqDist = sqdist
+			}
+		}
+	}
+
+	return nearest
+}
+
+// Nearest unit the mob would attack unprovoked
+func (b *mobBrain) nearestHostile() Unit {
+	mi := b.mob.I
+	mj := b.mob.J
+
+	var nearest Unit
+	var nearestSqDist int
+	for _, u := range b.seen {
+		if !b.mob.IsHostileTo(u) {
+			continue
+		}
+
+		ui, uj, _, _ := u.GetPos()
+		di := ui - mi
+		dj := uj - mj
+		sqdist := di*di + dj*dj
+		if nearest == nil || sqdist < nearestSqDist {
+			nearest = u
+			nearestSqDist = sqdist
+		}
+	}
+
+	return nearest
+}
+
+// Whether another unit has built up enough threat to pull the mob away from
+// its target
+func (b *mobBrain) threatShifted() bool {
+	mob := b.mob
+
+	top := mob.Threat.Top()
+	if top == nil || top == mob.Target {
+		return false
+	}
+
+	if mob.Target == nil {
+		return true
+	}
+
+	return mob.Threat.Of(top)*100 >= mob.Threat.Of(mob.Target)*ThreatSwitchPercent
+}
+
+func (b *mobBrain) check(cond MobCondition) bool {
+	mob := b.mob
+	stats := &mob.Stats
+
+	switch cond {
+	case MobCondAttacked:
+		return mob.Event == MobEventAttacked
+	case MobCondHit:
+		return mob.Event == MobEventHit
+	case MobCondBadlyHurt:
+		return mob.Event == MobEventBadlyHurt
+	case MobCondStunned:
+		return mob.Event == MobEventStunned
+	case MobCondHasCause:
+		return mob.EventCause != nil
+	case MobCondHasTarget:
+		return mob.Target != nil
+	case MobCondNoTarget:
+		return mob.Target == nil
+	case MobCondTargetVisible:
+		return b.targetVisible()
+	case MobCondTargetLost:
+		return mob.Target != nil && !b.targetVisible()
+	case MobCondSeesPlayer:
+		return b.nearestPlayer() != nil
+	case MobCondSeekExpired:
+		return mob.SeekTick <= 0
+	case MobCondHurt:
+		return stats.HP*100 < stats.MaxHP*b.behavior.FleeBelowHP
+	case MobCondRecovered:
+		return stats.HP*100 > stats.MaxHP*b.behavior.RallyAboveHP
+	case MobCondNoDirection:
+		return mob.Direc == NoDirection
+	case MobCondSeesHostile:
+		return b.nearestHostile() != nil
+	case MobCondCausedByMob:
+		_, ok := mob.EventCause.(*Mob)
+		return ok
+	case MobCondHasThreat:
+		return mob.Threat.Top() != nil
+	case MobCondThreatShifted:
+		return b.threatShifted()
+	case MobCondPackAlert:
+		return mob.Event == MobEventPackAlert
+	case MobCondFriendDied:
+		return mob.Event == MobEventFriendDied
+	case MobCondHasLeader:
+		leader := b.g.packLeader(mob)
+		return leader != nil && leader != mob
+	default:
+		return false
+	}
+}
+
+func (b *mobBrain) matches(tr *MobTransition) bool {
+	mob := b.mob
+
+	if len(tr.From) > 0 && !containsState(tr.From, mob.State) {
+		return false
+	}
+
+	if len(tr.Aggression) > 0 && !containsAggression(tr.Aggression, mob.Aggression) {
+		return false
+	}
+
+	if len(tr.On) > 0 {
+		found := false
+		for _, cond := range tr.On {
+			if b.check(cond) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for _, cond := range tr.When {
+		if !b.check(cond) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *mobBrain) setTarget(u Unit) {
+	mob := b.mob
+	mob.Target = u
+
+	if u != nil {
+		ti, tj, _, _ := u.GetPos()
+		mob.LastTargetI = ti
+		mob.LastTargetJ = tj
+	} else {
+		mob.LastTargetI = -1
+		mob.LastTargetJ = -1
+	}
+}
+
+func (b *mobBrain) apply(tr *MobTransition) {
+	mob := b.mob
+
+	switch tr.Target {
+	case MobTargetCause:
+		if mob.EventCause != nil {
+			b.setTarget(mob.EventCause)
+		}
+
+	case MobTargetNearestPlayer:
+		if pl := b.nearestPlayer(); pl != nil {
+			b.setTarget(pl)
+		}
+
+	case MobTargetNone:
+		b.setTarget(nil)
+
+	case MobTargetNearestHostile:
+		if u := b.nearestHostile(); u != nil {
+			b.setTarget(u)
+		}
+
+	case MobTargetMostThreat:
+		if u := mob.Threat.Top(); u != nil {
+			b.setTarget(u)
+		} else if mob.EventCause != nil {
+			b.setTarget(mob.EventCause)
+		}
+	}
+
+	if tr.Escalate != nil && mob.Aggression != *tr.Escalate {
+		mob.Aggression = *tr.Escalate
+
+		switch mob.Aggression {
+		case AggressionBlindRage:
+			b.g.messagef(chat.Game, "%s flies into a blind rage!", mob.Name())
+		case AggressionAttacksMobs, AggressionAttacksOnlyMobs:
+			b.g.messagef(chat.Game, "%s bristles at the creatures around it!", mob.Name())
+		}
+	}
+
+	if tr.To == nil {
+		return
+	}
+
+	mob.State = *tr.To
+
+	// state entry
+	switch mob.State {
+	case MobSeekTarget:
+		mob.SeekTick = b.info.SeekTargetRate
+		mob.MoveTick = b.info.ChaseRate
+
+	case MobFlee:
+		mob.MoveTick = 1
+	}
+
+	// TODO: add log message
+}
+
+// Runs the behavior's transitions for one tick
+func (b *mobBrain) think() {
+	for i := range b.behavior.Transitions {
+		tr := &b.behavior.Transitions[i]
+		if b.matches(tr) {
+			b.apply(tr)
+		}
+	}
+}
+
+// Ticks between a mob's attacks with a weapon
+func mobAttackRate(weapon Item) int16 {
+	if w, ok := weapon.(*MeleeWeapon); ok && w != nil {
+		return int16(w.swingTicks)
+	}
+
+	return 12
+}
+
+// Picks the weapon for the mob's next attack
+func (b *mobBrain) chooseAttack() Item {
+	mob := b.mob
+
+	weapon := mob.Weapon
+	if weapon == nil {
+		weapon = BareHands
+	}
+
+	attacks := b.behavior.Attacks
+
+	total := 0
+	for _, atk := range attacks {
+		if atk.Weight > 0 {
+			total += atk.Weight
+		}
+	}
+
+	if total == 0 || LookupItem == nil {
+		return weapon
+	}
+
+	roll := b.g.Dice.Roll1dN(total)
+	for _, atk := range attacks {
+		if atk.Weight <= 0 {
+			continue
+		}
+
+		if roll -= atk.Weight; roll <= 0 {
+			itm, err := LookupItem(atk.Weapon)
+			if err != nil || itm == nil {
+				log.Printf("mob \"%s\" could not find attack weapon \"%s\": %v", b.info.Name, atk.Weapon, err)
+				return weapon
+			}
+
+			return itm
+		}
+	}
+
+	return weapon
+}
@@ -30,6 +30,19 @@ type MobInfo struct {
 
 	InitialState    MobState
 	InitialStateArg int
+
+	// Experience awarded for killing the mob
+	XPReward int
+
+	// Tag of the mob's behavior; empty uses DefaultMobBehaviorTag
+	BehaviorTag string
+
+	// Mobs are friendly toward their own faction.  See
+	// LookupFactionRelation for other factions.
+	Faction string
+
+	// Stats of mobs spawned during a game
+	Stats UnitStats
 }
 
 const (
@@ -40,6 +53,7 @@ const (
 var mobTypes = []MobInfo{
 	MobInfo{
 		Type:              MobLemming,
+		Tag:               "lemming",
 		Name:              "Lemming",
 		Marker:            'L',
 		W:                 1,
@@ -52,9 +66,19 @@ var mobTypes = []MobInfo{
 		ViewDistance:      3,
 		FieldOfView:       3,
 		InitialState:      MobPatrol,
+		XPReward:          5,
+		Faction:           "lemmings",
+		Stats: UnitStats{
+			ArmorClass:         8,
+			THAC0:              4,
+			HP:                 10,
+			MaxHP:              10,
+			HealthRecoveryRate: 200,
+		},
 	},
 	MobInfo{
 		Type:              MobViciousLemming,
+		Tag:               "vicious_lemming",
 		Name:              "Vicious lemming",
 		Marker:            'V',
 		W:                 1,
@@ -67,6 +91,16 @@ var mobTypes = []MobInfo{
 		ViewDistance:      3,
 		FieldOfView:       3,
 		InitialState:      MobPatrol,
+		XPReward:          12,
+		BehaviorTag:       "vicious_lemming",
+		Faction:           "vicious_lemmings",
+		Stats: UnitStats{
+			ArmorClass:         8,
+			THAC0:              6,
+			HP:                 14,
+			MaxHP:              14,
+			HealthRecoveryRate: 200,
+		},
 	},
 }
 
@@ -107,6 +141,9 @@ func (mi *MobInfo) UnmarshalTOML(data interface{}) error {
 		"field_of_view":    &mi.FieldOfView,
 		"state":            &mi.InitialState,
 		"state_arg":        &mi.InitialStateArg,
+		"xp":               &mi.XPReward,
+		"behavior":         &mi.BehaviorTag,
+		"faction":          &mi.Faction,
 	}, config.NoFlags)
 
 	if err != nil {
@@ -138,9 +175,16 @@ const (
 	// Mob's health is below 25%
 	MobEventBadlyHurt
 
+	// Mob was stunned
+	MobEventStunned
+
+	// Another member of the mob's pack was attacked
+	MobEventPackAlert
+
+	// Another member of the mob's pack was killed
+	MobEventFriendDied
+
 	// Possible future events:
-	// MobEventStunned
-	// MobEventFriendDied
 	// MobEventHurt
 )
 
@@ -154,6 +198,7 @@ const (
 	MobSeekTarget
 	MobAttack
 	MobFlee
+	MobFollow
 )
 
 func (st MobState) String() string {
@@ -172,11 +217,17 @@ func (st MobState) String() string {
 		return "attack"
 	case MobFlee:
 		return "flee"
+	case MobFollow:
+		return "follow"
 	default:
 		return fmt.Sprintf("state_%d", int(st))
 	}
 }
 
+func (st MobState) MarshalText() ([]byte, error) {
+	return []byte(st.String()), nil
+}
+
 func (st *MobState) UnmarshalText(text []byte) error {
 	s := string(text)
 
@@ -195,6 +246,8 @@ func (st *MobState) UnmarshalText(text []byte) error {
 		*st = MobAttack
 	case "flee":
 		*st = MobFlee
+	case "follow":
+		*st = MobFollow
 	default:
 		const prefix = "state_"
 		if strings.HasPrefix(s, prefix) {
@@ -220,7 +273,8 @@ func (st *MobState) UnmarshalText(text []byte) error {
 // Passive           - mob is passive and will try to run away if attacked
 // Defends           - mob will not attack unless attacked
 // Attacks           - mob will attack players when they are found
-// Attacks mobs      - mob will attack other mobs that are not of its species/tribe/etc.
+// Attacks mobs      - mob will attack players and other mobs that are not of its
+//                     species/tribe/etc.
 // Attacks only mobs - mob will attack other mobs that are not of its species/tribe/etc.
 //                     but not players, unless attacked
 // Blind rage        - mob will attack anything
@@ -241,6 +295,7 @@ const (
 	AggressionDefends
 	AggressionAttacks
 	AggressionAttacksMobs
+	AggressionAttacksOnlyMobs
 	AggressionBlindRage
 )
 
@@ -255,6 +310,8 @@ func (agg Aggression) String() string {
 		return "attacks"
 	case AggressionAttacksMobs:
 		return "attacks_mobs"
+	case AggressionAttacksOnlyMobs:
+		return "attacks_only_mobs"
 	case AggressionBlindRage:
 		return "blind_rage"
 	default:
@@ -262,6 +319,10 @@ func (agg Aggression) String() string {
 	}
 }
 
+func (agg Aggression) MarshalText() ([]byte, error) {
+	return []byte(agg.String()), nil
+}
+
 func (agg *Aggression) UnmarshalText(text []byte) error {
 	s := string(text)
 	switch s {
@@ -273,6 +334,8 @@ func (agg *Aggression) UnmarshalText(text []byte) error {
 		*agg = AggressionAttacks
 	case "attacks_mobs":
 		*agg = AggressionAttacksMobs
+	case "attacks_only_mobs":
+		*agg = AggressionAttacksOnlyMobs
 	case "blind_rage":
 		*agg = AggressionBlindRage
 	default:
@@ -285,8 +348,9 @@ func (agg *Aggression) UnmarshalText(text []byte) error {
 type Mob struct {
 	I, J int
 
-	Stats UnitStats
-	Type  MobType
+	Stats   UnitStats
+	Effects StatusEffects
+	Type    MobType
 
 	MoveTick   int16
 	StunTick   int16
@@ -309,6 +373,12 @@ type Mob struct {
 	Target      Unit
 	LastTargetI int
 	LastTargetJ int
+
+	Threat ThreatTable
+
+	// Pack the mob belongs to (zero if none), and whether the mob leads it
+	Pack       int
+	PackLeader bool
 }
 
 var _ Unit = &Mob{}
@@ -321,8 +391,14 @@ func (m *Mob) TakeDamage(dmg int, u Unit) {
 	}
 
 	m.Stats.HP = hp
+	m.Threat.Add(u, dmg*ThreatPerDamage)
 
-	if hp < m.Stats.MaxHP/4 {
+	fleeBelow := 25
+	if mi, err := LookupMobInfo(m.Type); err == nil {
+		fleeBelow = mi.Behavior().FleeBelowHP
+	}
+
+	if hp*100 < m.Stats.MaxHP*fleeBelow {
 		m.Event = MobEventBadlyHurt
 		m.EventCause = u
 	} else {
@@ -339,6 +415,10 @@ func (m *Mob) GetStats() *UnitStats {
 	return &m.Stats
 }
 
+func (m *Mob) GetStatusEffects() *StatusEffects {
+	return &m.Effects
+}
+
 func (m *Mob) Name() string {
 	info, _ := LookupMobInfo(m.Type)
 
@@ -23,6 +23,7 @@ aggression       = "defends"
 view_distance    = 3
 field_of_view    = 3
 state            = "patrol"
+xp               = 5
 
 [[mobs]]
 tag              = "vicious_lemming"
@@ -38,6 +39,8 @@ aggression       = "attacks"
 view_distance    = 3
 field_of_view    = 3
 state            = "patrol"
+xp               = 12
+behavior         = "lemming"
 `)
 
 	var loaded struct {
@@ -65,6 +68,7 @@ state            = "patrol"
 			ViewDistance:      3,
 			FieldOfView:       3,
 			InitialState:      MobPatrol,
+			XPReward:          5,
 		},
 		MobInfo{
 			Tag:               "vicious_lemming",
@@ -80,6 +84,8 @@ state            = "patrol"
 			ViewDistance:      3,
 			FieldOfView:       3,
 			InitialState:      MobPatrol,
+			XPReward:          12,
+			BehaviorTag:       "lemming",
 		},
 	}
 
@@ -94,3 +100,249 @@ state            = "patrol"
 		}
 	}
 }
+
+func TestLoadMobBehaviors(t *testing.T) {
+	sr := strings.NewReader(`
+[[behaviors]]
+tag            = "coward"
+flee_below_hp  = 90
+rally_above_hp = 100
+flee_distance  = 10
+attacks        = [ { weapon = "lemming_teeth", weight = 2 } ]
+
+[[behaviors.transitions]]
+from   = ["wander", "patrol"]
+on     = ["attacked", "hit"]
+when   = ["has_cause"]
+to     = "flee"
+target = "cause"
+
+[[behaviors.transitions]]
+from = ["flee"]
+when = ["no_target"]
+to   = "wander"
+`)
+
+	if err := LoadMobBehaviors(sr); err != nil {
+		t.Fatalf("error loading behaviors: %v", err)
+	}
+	t.Cleanup(func() { delete(mobBehaviors, "coward") })
+
+	b, err := LookupMobBehavior("coward")
+	if err != nil {
+		t.Fatalf("error looking up behavior: %v", err)
+	}
+
+	if b.FleeBelowHP != 90 || b.RallyAboveHP != 100 || b.FleeDistance != 10 {
+		t.Errorf("unexpected thresholds: %+v", b)
+	}
+
+	if len(b.Attacks) != 1 || b.Attacks[0] != (MobAttackChoice{Weapon: "lemming_teeth", Weight: 2}) {
+		t.Errorf("unexpected attacks: %+v", b.Attacks)
+	}
+
+	if len(b.Transitions) != 2 {
+		t.Fatalf("expected 2 transitions, but found %d", len(b.Transitions))
+	}
+
+	tr := b.Transitions[0]
+	if len(tr.From) != 2 || tr.From[0] != MobWander || tr.From[1] != MobPatrol {
+		t.Errorf("transition 0: unexpected from states %v", tr.From)
+	}
+
+	if len(tr.On) != 2 || tr.On[0] != MobCondAttacked || tr.On[1] != MobCondHit {
+		t.Errorf("transition 0: unexpected events %v", tr.On)
+	}
+
+	if tr.To == nil || *tr.To != MobFlee || tr.Target != MobTargetCause {
+		t.Errorf("transition 0: unexpected result %+v", tr)
+	}
+
+	if b.Transitions[1].Target != MobTargetKeep {
+		t.Errorf("transition 1: expected target to be kept")
+	}
+
+	if _, err := LookupMobBehavior(""); err != nil {
+		t.Errorf("default behavior not found: %v", err)
+	}
+}
+
+func TestMobHostility(t *testing.T) {
+	lemming := &Mob{Type: MobLemming, Stats: UnitStats{HP: 5}, Aggression: AggressionDefends}
+	other := &Mob{Type: MobLemming, Stats: UnitStats{HP: 5}, Aggression: AggressionDefends}
+	vicious := &Mob{Type: MobViciousLemming, Stats: UnitStats{HP: 5}, Aggression: AggressionAttacks}
+	pl := &Player{Stats: UnitStats{HP: 5}}
+
+	if rel := LookupFactionRelation("lemmings", "vicious_lemmings"); rel != FactionHostile {
+		t.Errorf("expected lemmings and vicious lemmings to be hostile, found %v", rel)
+	}
+
+	if !vicious.IsHostileTo(pl) || !vicious.IsHostileTo(lemming) {
+		t.Errorf("vicious lemming should attack players and lemmings")
+	}
+
+	if lemming.IsHostileTo(pl) || lemming.IsHostileTo(vicious) {
+		t.Errorf("defending lemming should not attack unprovoked")
+	}
+
+	if vicious.IsHostileTo(vicious) {
+		t.Errorf("mob should not be hostile to itself")
+	}
+
+	other.Aggression = AggressionAttacksOnlyMobs
+	if other.IsHostileTo(pl) || other.IsHostileTo(lemming) || !other.IsHostileTo(vicious) {
+		t.Errorf("mob that attacks only mobs should only attack other factions")
+	}
+
+	other.Aggression = AggressionBlindRage
+	if !other.IsHostileTo(pl) || !other.IsHostileTo(lemming) {
+		t.Errorf("mob in a blind rage should attack anything")
+	}
+}
+
+func TestThreatTable(t *testing.T) {
+	a := &Player{Stats: UnitStats{HP: 5}}
+	b := &Player{Stats: UnitStats{HP: 5}}
+
+	mob := &Mob{Type: MobLemming, Stats: UnitStats{HP: 20, MaxHP: 20}}
+	mob.TakeDamage(1, a)
+	mob.TakeDamage(2, b)
+
+	if top := mob.Threat.Top(); top != b {
+		t.Errorf("expected b to have the most threat, found %v", top)
+	}
+
+	if got, want := mob.Threat.Of(a), 1*ThreatPerDamage; got != want {
+		t.Errorf("expected threat %d for a, found %d", want, got)
+	}
+
+	b.Stats.HP = 0
+	if top := mob.Threat.Top(); top != a {
+		t.Errorf("expected dead units to be skipped, found %v", top)
+	}
+
+	mob.Threat.Update()
+	if mob.Threat.Has(b) {
+		t.Errorf("expected dead units to be removed from the threat table")
+	}
+
+	if got, want := mob.Threat.Of(a), ThreatPerDamage-1; got != want {
+		t.Errorf("expected threat to decay to %d, found %d", want, got)
+	}
+
+	for i := 0; i < 50*ThreatDecayTicks; i++ {
+		mob.Threat.Update()
+	}
+
+	if len(mob.Threat.Entries) != 0 {
+		t.Errorf("expected threat to decay away, found %+v", mob.Threat.Entries)
+	}
+}
+
+func TestPackAlertAndLeadership(t *testing.T) {
+	st := UnitStats{HP: 10, MaxHP: 10}
+	g := &Game{
+		Mobs: []Mob{
+			{I: 10, J: 10, Type: MobViciousLemming, Stats: st, Pack: 1, PackLeader: true},
+			{I: 11, J: 10, Type: MobViciousLemming, Stats: st, Pack: 1},
+			{I: 40, J: 40, Type: MobViciousLemming, Stats: st, Pack: 1},
+			{I: 10, J: 11, Type: MobLemming, Stats: st},
+		},
+	}
+
+	pl := &Player{Stats: UnitStats{HP: 5}}
+	g.alertPack(&g.Mobs[0], MobEventPackAlert, pl)
+
+	if ev := g.Mobs[1].Event; ev != MobEventPackAlert || g.Mobs[1].EventCause != pl {
+		t.Errorf("expected nearby pack member to be alerted, found event %v", ev)
+	}
+
+	if ev := g.Mobs[2].Event; ev != MobEventNone {
+		t.Errorf("expected distant pack member not to be alerted, found event %v", ev)
+	}
+
+	if ev := g.Mobs[3].Event; ev != MobEventNone {
+		t.Errorf("expected mob outside the pack not to be alerted, found event %v", ev)
+	}
+
+	if leader := g.packLeader(&g.Mobs[2]); leader != &g.Mobs[0] {
+		t.Errorf("expected mob 0 to lead the pack")
+	}
+
+	g.Mobs[0].Stats.HP = 0
+	if leader := g.packLeader(&g.Mobs[2]); leader != &g.Mobs[1] || !g.Mobs[1].PackLeader {
+		t.Errorf("expected mob 1 to take over the pack")
+	}
+}
+
+func TestSpawnMob(t *testing.T) {
+	lvl := SingleRoomLevel(20, 20, 10, 10)
+	g := &Game{Level: lvl, Mobs: make([]Mob, 1, 2)}
+
+	mt, err := LookupMobTypeByTag("vicious_lemming")
+	if err != nil {
+		t.Fatalf("error looking up mob type: %v", err)
+	}
+
+	// the dead mob's slot is reused first
+	mob, err := g.SpawnMob(mt, 5, 5)
+	if err != nil {
+		t.Fatalf("error spawning mob: %v", err)
+	}
+
+	if mob != &g.Mobs[0] || !mob.IsAlive() || mob.Aggression != AggressionAttacks {
+		t.Errorf("unexpected spawned mob %+v", mob)
+	}
+
+	if _, err := g.SpawnMob(mt, 5, 6); err != nil {
+		t.Fatalf("error spawning mob: %v", err)
+	}
+
+	if _, err := g.SpawnMob(mt, 5, 7); err != ErrTooManyMobs {
+		t.Errorf("expected too many mobs error, found %v", err)
+	}
+
+	if _, err := LookupMobTypeByTag("bunny"); err == nil {
+		t.Errorf("expected an error looking up an unknown mob tag")
+	}
+}
+
+func TestPassiveMobFlees(t *testing.T) {
+	b, err := LookupMobBehavior(DefaultMobBehaviorTag)
+	if err != nil {
+		t.Fatalf("error looking up behavior: %v", err)
+	}
+
+	pl := &Player{Stats: UnitStats{HP: 5}}
+
+	tests := []struct {
+		state MobState
+		event MobEvent
+		flees bool
+	}{
+		{MobSentry, MobEventHit, true},
+		{MobSentry, MobEventStunned, false},
+		{MobSentry, MobEventAttacked, false},
+		{MobWander, MobEventStunned, true},
+		{MobWander, MobEventAttacked, true},
+	}
+
+	for _, tc := range tests {
+		mob := &Mob{
+			I: 10, J: 10,
+			Type:       MobLemming,
+			Stats:      UnitStats{HP: 10, MaxHP: 10},
+			State:      tc.state,
+			Aggression: AggressionPassive,
+			Event:      tc.event,
+			EventCause: pl,
+		}
+
+		brain := mobBrain{g: &Game{}, mob: mob, behavior: b}
+		brain.think()
+
+		if flees := mob.State == MobFlee && mob.Target == pl; flees != tc.flees {
+			t.Errorf("%v on %v: expected flee %v, found state %v", tc.state, tc.event, tc.flees, mob.State)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Permissions extension set on connections that authenticated with an
+// admin key
+const adminExtension = "mpnethack-admin"
+
+// Public keys whose holders log in as administrators.  Set with
+// LoadAdminKeys.
+var adminKeys []ssh.PublicKey
+
+// Loads administrators' public keys from a file in authorized_keys format.
+// Once admin keys are loaded, ssh clients must offer a public key or use
+// keyboard-interactive auth; other keys still log in as ordinary users.
+func LoadAdminKeys(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading admin keys: %w", err)
+	}
+
+	var keys []ssh.PublicKey
+	for len(bytes.TrimSpace(data)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return fmt.Errorf("error parsing admin keys \"%s\": %w", path, err)
+		}
+
+		keys = append(keys, key)
+		data = rest
+	}
+
+	adminKeys = keys
+	return nil
+}
+
+func isAdminKey(key ssh.PublicKey) bool {
+	wire := key.Marshal()
+	for _, k := range adminKeys {
+		if bytes.Equal(k.Marshal(), wire) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Accepts any key, and marks connections using an admin key
+func publicKeyAuth(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	if !isAdminKey(key) {
+		return nil, nil
+	}
+
+	return &ssh.Permissions{
+		Extensions: map[string]string{adminExtension: "yes"},
+	}, nil
+}
+
+// Accepts everyone without asking anything
+func keyboardInteractiveAuth(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	return nil, nil
+}
+
+// Sets up authentication so admin keys are recognized.  Without admin keys,
+// clients don't authenticate at all.
+func configureAuth(cfg *ssh.ServerConfig) {
+	if len(adminKeys) == 0 {
+		cfg.NoClientAuth = true
+		return
+	}
+
+	cfg.NoClientAuth = false
+	cfg.PublicKeyCallback = publicKeyAuth
+	cfg.KeyboardInteractiveCallback = keyboardInteractiveAuth
+}
+
+func isAdminConn(conn *ssh.ServerConn) bool {
+	return conn.Permissions != nil && conn.Permissions.Extensions[adminExtension] != ""
+}
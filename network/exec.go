@@ -0,0 +1,291 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var ErrNotInteractive = errors.New("exec sessions can't join games")
+
+// Option that makes an exec command print JSON instead of text, errors
+// included.  It may start or end the command line.
+const ExecJSONOption = "--json"
+
+// Rows "scores" lists unless asked for more or fewer
+const DefaultScoreCount = 10
+
+// Commands run without a terminal with "ssh host <command>"
+var ExecCommands = mpnethack.NewCommandRegistry()
+
+// Session for an ssh exec request.  Commands write their results to out.
+type execSession struct {
+	user  string
+	admin bool
+	json  bool
+	out   io.Writer
+	log   *chat.Log
+
+	// run once the reply has been sent
+	after func()
+}
+
+var _ mpnethack.Session = &execSession{}
+
+func newExecSession(name string, admin bool) *execSession {
+	return &execSession{
+		user:  name,
+		admin: admin,
+		log:   chat.NewLog(mpnethack.GameLogNumLines),
+	}
+}
+
+func (x *execSession) IsAdministrator() bool        { return x.admin }
+func (x *execSession) HasGame() bool                { return false }
+func (x *execSession) Game() *mpnethack.Game        { return nil }
+func (x *execSession) Player() *mpnethack.Player    { return nil }
+func (x *execSession) UserName() string             { return x.user }
+func (x *execSession) GetLog() *chat.Log            { return x.log }
+func (x *execSession) ConsoleInput(string)          {}
+func (x *execSession) Join(g *mpnethack.Game) error { return ErrNotInteractive }
+func (x *execSession) Update() error                { return nil }
+func (x *execSession) Quit()                        {}
+
+func (x *execSession) Message(lvl chat.MsgLevel, txt string) error {
+	if x.json {
+		return json.NewEncoder(x.out).Encode(struct {
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		}{lvl.String(), txt})
+	}
+
+	_, err := fmt.Fprintln(x.out, txt)
+	return err
+}
+
+// Writes a command's result as JSON, or as text with writeText
+func (x *execSession) result(v interface{}, writeText func(w io.Writer)) error {
+	if x.json {
+		return json.NewEncoder(x.out).Encode(v)
+	}
+
+	writeText(x.out)
+	return nil
+}
+
+func resultOf(ctx *mpnethack.CommandContext, v interface{}, writeText func(w io.Writer)) error {
+	return ctx.Session.(*execSession).result(v, writeText)
+}
+
+// Runs an exec command line
+func (x *execSession) run(lobby *mpnethack.Lobby, line string) error {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == ExecJSONOption:
+		x.json, line = true, ""
+	case strings.HasPrefix(line, ExecJSONOption+" "):
+		x.json, line = true, strings.TrimSpace(line[len(ExecJSONOption):])
+	case strings.HasSuffix(line, " "+ExecJSONOption):
+		x.json, line = true, strings.TrimSpace(line[:len(line)-len(ExecJSONOption)])
+	}
+
+	if line == "" {
+		line = "help"
+	}
+
+	return ExecCommands.Execute(x, lobby, "/"+line)
+}
+
+// Reports a command's error as JSON on the output, or as text on stderr
+func (x *execSession) writeError(stderr io.Writer, err error) {
+	msg := strings.TrimPrefix(err.Error(), "/")
+	if x.json {
+		json.NewEncoder(x.out).Encode(struct {
+			Error string `json:"error"`
+		}{msg})
+		return
+	}
+
+	fmt.Fprintf(stderr, "%s\n", msg)
+}
+
+type exitStatusMsg struct {
+	Status uint32
+}
+
+// Runs an exec request on the channel, reports its exit status, and closes
+// the channel
+func serveExec(channel ssh.Channel, x *execSession, lobby *mpnethack.Lobby, line string) {
+	log.Printf("exec from \"%s\": %q", x.user, line)
+
+	x.out = channel
+
+	var status uint32
+	if err := x.run(lobby, line); err != nil {
+		x.writeError(channel.Stderr(), err)
+		status = 1
+	}
+
+	channel.SendRequest("exit-status", false, ssh.Marshal(&exitStatusMsg{status}))
+	channel.Close()
+
+	if x.after != nil {
+		x.after()
+	}
+}
+
+func init() {
+	ExecCommands.MustRegister(&mpnethack.Command{
+		Name: "help",
+		Help: "Lists commands",
+		Run: func(ctx *mpnethack.CommandContext) error {
+			type entry struct {
+				Usage string `json:"usage"`
+				Help  string `json:"help"`
+			}
+
+			entries := []entry{}
+			for _, cmd := range ExecCommands.Available(ctx.Session) {
+				entries = append(entries, entry{strings.TrimPrefix(cmd.Usage(), "/"), cmd.Help})
+			}
+
+			return resultOf(ctx, entries, func(w io.Writer) {
+				for _, e := range entries {
+					fmt.Fprintf(w, "%-24s %s\n", e.Usage, e.Help)
+				}
+			})
+		},
+	})
+
+	ExecCommands.MustRegister(&mpnethack.Command{
+		Name: "who",
+		Help: "Lists everyone connected, and where they are",
+		Run: func(ctx *mpnethack.CommandContext) error {
+			who := ctx.Lobby.Who()
+			if who == nil {
+				who = []mpnethack.WhoEntry{}
+			}
+
+			return resultOf(ctx, who, func(w io.Writer) {
+				for _, e := range who {
+					fmt.Fprintf(w, "%-20s %s\n", e.Name, e.Where)
+				}
+			})
+		},
+	})
+
+	ExecCommands.MustRegister(&mpnethack.Command{
+		Name: "games",
+		Help: "Lists the running games",
+		Run: func(ctx *mpnethack.CommandContext) error {
+			games := ctx.Lobby.GameList()
+			return resultOf(ctx, games, func(w io.Writer) {
+				for _, gi := range games {
+					fmt.Fprintf(w, "game %d: %s, frame %d", gi.Number, gi.Level, gi.Frame)
+					if gi.Paused {
+						fmt.Fprintf(w, " (paused)")
+					}
+
+					fmt.Fprintf(w, ", players: %s", strings.Join(gi.Players, ", "))
+					if gi.Spectators > 0 {
+						fmt.Fprintf(w, ", %d spectating", gi.Spectators)
+					}
+
+					fmt.Fprintln(w)
+				}
+			})
+		},
+	})
+
+	ExecCommands.MustRegister(&mpnethack.Command{
+		Name: "stats",
+		Args: []mpnethack.ArgSpec{{Name: "player"}},
+		Help: "Shows a player's level, experience and kills",
+		Run: func(ctx *mpnethack.CommandContext) error {
+			st, err := ctx.Lobby.PlayerStats(ctx.Arg("player"))
+			if err != nil {
+				return err
+			}
+
+			return resultOf(ctx, st, func(w io.Writer) {
+				where := "offline"
+				if st.Where != "" {
+					where = st.Where
+				}
+
+				fmt.Fprintf(w, "%s (%s)\n", st.Name, where)
+				fmt.Fprintf(w, "level %d, %d xp\n", st.Level, st.XP)
+				if st.MaxHP > 0 {
+					fmt.Fprintf(w, "hp %d/%d\n", st.HP, st.MaxHP)
+					fmt.Fprintf(w, "kills: %d mobs, %d players; deaths: %d\n", st.MobKills, st.PlayerKills, st.Deaths)
+				}
+			})
+		},
+	})
+
+	ExecCommands.MustRegister(&mpnethack.Command{
+		Name: "scores",
+		Args: []mpnethack.ArgSpec{{Name: "count", Optional: true}},
+		Help: fmt.Sprintf("Lists the players with the most experience (default %d)", DefaultScoreCount),
+		Run: func(ctx *mpnethack.CommandContext) error {
+			n := DefaultScoreCount
+			if arg := ctx.Arg("count"); arg != "" {
+				var err error
+				if n, err = strconv.Atoi(arg); err != nil {
+					return fmt.Errorf("%w: invalid count \"%s\"", mpnethack.ErrBadArguments, arg)
+				}
+			}
+
+			scores, err := ctx.Lobby.Scores(n)
+			if err != nil {
+				return err
+			}
+
+			return resultOf(ctx, scores, func(w io.Writer) {
+				for i, rec := range scores {
+					fmt.Fprintf(w, "%3d. %-20s level %d, %d xp\n", i+1, rec.Name, rec.Level, rec.XP)
+				}
+			})
+		},
+	})
+
+	ExecCommands.MustRegister(&mpnethack.Command{
+		Name:       "broadcast",
+		Aliases:    []string{"wall"},
+		Args:       []mpnethack.ArgSpec{{Name: "message", Rest: true}},
+		Help:       "Sends a message to everyone connected",
+		Permission: mpnethack.PermAdmin,
+		Run: func(ctx *mpnethack.CommandContext) error {
+			ctx.Lobby.Broadcast(ctx.Arg("message"))
+			return resultOf(ctx, map[string]bool{"sent": true}, func(w io.Writer) {
+				fmt.Fprintln(w, "Message sent")
+			})
+		},
+	})
+
+	ExecCommands.MustRegister(&mpnethack.Command{
+		Name:       "shutdown",
+		Args:       []mpnethack.ArgSpec{{Name: "reason", Optional: true, Rest: true}},
+		Help:       "Saves the players and shuts down the server",
+		Permission: mpnethack.PermAdmin,
+		Run: func(ctx *mpnethack.CommandContext) error {
+			lobby, reason := ctx.Lobby, ctx.Arg("reason")
+			ctx.Session.(*execSession).after = func() {
+				lobby.Shutdown(reason)
+			}
+
+			return resultOf(ctx, map[string]bool{"shutting_down": true}, func(w io.Writer) {
+				fmt.Fprintln(w, "Shutting down")
+			})
+		},
+	})
+}
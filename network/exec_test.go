@@ -0,0 +1,92 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sfstewman/mpnethack"
+)
+
+func TestExecRun(t *testing.T) {
+	tests := []struct {
+		line  string
+		admin bool
+		json  bool
+		err   error
+
+		// text the output has, and doesn't have
+		has, lacks string
+	}{
+		{"who", false, false, nil, "", "["},
+		{"--json who", false, true, nil, "[]\n", ""},
+		{"who --json", false, true, nil, "[]\n", ""},
+		{"  games   --json ", false, true, nil, "[]\n", ""},
+		{"", false, false, nil, "who ", "broadcast"},
+		{"--json", false, true, nil, `{"usage":"who","help":`, "broadcast"},
+		{"", true, false, nil, "broadcast", ""},
+		{"broadcast hi", false, false, mpnethack.ErrPermissionDenied, "", "sent"},
+		{"--json wall hi", false, true, mpnethack.ErrPermissionDenied, "", "sent"},
+		{"shutdown now", false, false, mpnethack.ErrPermissionDenied, "", "Shutting"},
+		{"broadcast hi", true, false, nil, "Message sent", ""},
+		{"shutdown --json", true, true, nil, `{"shutting_down":true}`, ""},
+		{"nonsense", false, false, mpnethack.ErrUnknownCommand, "", ""},
+	}
+
+	for _, tc := range tests {
+		var out bytes.Buffer
+		x := newExecSession("alice", tc.admin)
+		x.out = &out
+
+		err := x.run(&mpnethack.Lobby{}, tc.line)
+		switch {
+		case tc.err == nil && err != nil:
+			t.Errorf("%q: unexpected error %v", tc.line, err)
+		case tc.err != nil && !errors.Is(err, tc.err):
+			t.Errorf("%q: expected error %v but found %v", tc.line, tc.err, err)
+		}
+
+		if x.json != tc.json {
+			t.Errorf("%q: expected json %v, found %v", tc.line, tc.json, x.json)
+		}
+
+		if !strings.Contains(out.String(), tc.has) || (tc.lacks != "" && strings.Contains(out.String(), tc.lacks)) {
+			t.Errorf("%q: expected output with %q and without %q, found %q", tc.line, tc.has, tc.lacks, out.String())
+		}
+
+		// only an administrator's shutdown stops the server
+		if shuts := x.after != nil; shuts != (tc.admin && strings.HasPrefix(tc.line, "shutdown")) {
+			t.Errorf("%q: unexpected shutdown %v", tc.line, shuts)
+		}
+	}
+}
+
+func TestExecError(t *testing.T) {
+	var out, stderr bytes.Buffer
+	x := newExecSession("alice", false)
+	x.out = &out
+
+	err := x.run(&mpnethack.Lobby{}, "broadcast hi")
+	x.writeError(&stderr, err)
+
+	if out.Len() != 0 || stderr.String() != "broadcast: permission denied\n" {
+		t.Errorf("expected the error as text on stderr, found %q and %q", out.String(), stderr.String())
+	}
+
+	out.Reset()
+	stderr.Reset()
+
+	err = x.run(&mpnethack.Lobby{}, "--json broadcast hi")
+	x.writeError(&stderr, err)
+
+	var reply map[string]string
+	if jerr := json.Unmarshal(out.Bytes(), &reply); jerr != nil || stderr.Len() != 0 {
+		t.Fatalf("expected the error as JSON on the output, found %q and %q", out.String(), stderr.String())
+	}
+
+	if len(reply) != 1 || reply["error"] != "broadcast: permission denied" {
+		t.Errorf("unexpected error reply %v", reply)
+	}
+}
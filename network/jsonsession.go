@@ -0,0 +1,536 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/chat"
+	"github.com/sfstewman/mpnethack/protocol"
+)
+
+// Messages queued for a JSON client.  A client that falls this far behind
+// is disconnected.
+const JSONSessionQueueLength = 256
+
+var (
+	ErrNotLoggedIn   = errors.New("not logged in")
+	ErrUnknownAction = errors.New("unknown action")
+	ErrClientTooSlow = errors.New("client is not keeping up")
+)
+
+// Session for a client speaking the JSON protocol (see the protocol
+// package)
+type JSONSession struct {
+	User  string
+	Lobby *mpnethack.Lobby
+
+	rw    io.ReadWriteCloser
+	out   chan []byte
+	log   *chat.Log
+	input *mpnethack.InputLimiter
+
+	mu    sync.Mutex
+	g     *mpnethack.Game
+	pl    *mpnethack.Player
+	tiles []byte
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+var _ mpnethack.Session = &JSONSession{}
+
+func NewJSONSession(rw io.ReadWriteCloser, lobby *mpnethack.Lobby) *JSONSession {
+	return &JSONSession{
+		Lobby: lobby,
+		rw:    rw,
+		out:   make(chan []byte, JSONSessionQueueLength),
+		log:   chat.NewLog(mpnethack.GameLogNumLines),
+		input: ConnectionLimits.inputLimiter(),
+		done:  make(chan struct{}),
+	}
+}
+
+func (s *JSONSession) IsAdministrator() bool { return false }
+func (s *JSONSession) UserName() string      { return s.User }
+func (s *JSONSession) GetLog() *chat.Log     { return s.log }
+
+func (s *JSONSession) InputLimiter() *mpnethack.InputLimiter { return s.input }
+
+func (s *JSONSession) HasGame() bool {
+	return s.Game() != nil
+}
+
+func (s *JSONSession) Game() *mpnethack.Game {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.g
+}
+
+func (s *JSONSession) Player() *mpnethack.Player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pl
+}
+
+func (s *JSONSession) ConsoleInput(txt string) {
+	mpnethack.HandleConsoleInput(s, s.Lobby, txt)
+}
+
+func (s *JSONSession) Message(lvl chat.MsgLevel, txt string) error {
+	s.log.LogLine(lvl, txt)
+	return s.send(&protocol.Message{Type: protocol.TypeLog, Level: lvl.String(), Text: txt})
+}
+
+func (s *JSONSession) Join(g *mpnethack.Game) error {
+	s.mu.Lock()
+	if s.g != nil {
+		s.mu.Unlock()
+		return mpnethack.ErrAlreadyInGame
+	}
+	s.g = g
+	s.mu.Unlock()
+
+	pl, err := g.PlayerJoin(s)
+	if err != nil {
+		s.mu.Lock()
+		s.g = nil
+		s.mu.Unlock()
+		return err
+	}
+
+	g.RLock()
+	m, tiles := mapTiles(g.Level)
+	g.RUnlock()
+
+	s.mu.Lock()
+	s.pl = pl
+	s.tiles = tiles
+	s.mu.Unlock()
+
+	return s.send(&protocol.Message{Type: protocol.TypeMap, Map: m})
+}
+
+// Leaves the game, if the session is in one.  If the connection dropped,
+// the player stays in the game for a while so the user can reconnect.
+func (s *JSONSession) leaveGame(dropped bool) {
+	g := s.Game()
+	if g == nil {
+		return
+	}
+
+	// both need the session's player
+	if dropped {
+		g.PlayerDisconnect(s)
+	} else {
+		g.PlayerLeave(s)
+	}
+
+	s.mu.Lock()
+	s.g = nil
+	s.pl = nil
+	s.tiles = nil
+	s.mu.Unlock()
+}
+
+// Disconnects the client
+func (s *JSONSession) Quit() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.rw.Close()
+	})
+}
+
+// Queues a message for the client.  Never blocks: a client that can't keep
+// up is disconnected.
+func (s *JSONSession) send(msg *protocol.Message) error {
+	data, err := protocol.Encode(msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-s.done:
+		return net.ErrClosed
+	default:
+	}
+
+	select {
+	case s.out <- data:
+		return nil
+	default:
+		log.Printf("json client \"%s\" is not keeping up; disconnecting", s.User)
+		s.Quit()
+		return ErrClientTooSlow
+	}
+}
+
+func (s *JSONSession) sendError(err error) {
+	s.send(&protocol.Message{Type: protocol.TypeError, Text: err.Error()})
+}
+
+func (s *JSONSession) writeLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case data := <-s.out:
+			if _, err := s.rw.Write(data); err != nil {
+				s.Quit()
+				return
+			}
+		}
+	}
+}
+
+func tileFor(m mpnethack.Marker) byte {
+	switch m {
+	case mpnethack.MarkerVoid:
+		return protocol.TileVoid
+	case mpnethack.MarkerEmpty:
+		return protocol.TileEmpty
+	case mpnethack.MarkerBorder, mpnethack.MarkerWall:
+		return protocol.TileWall
+	case mpnethack.MarkerCactus:
+		return protocol.TileCactus
+	default:
+		return protocol.TileUnknown
+	}
+}
+
+// Assumes the game lock is held
+func mapTiles(lvl *mpnethack.Level) (*protocol.Map, []byte) {
+	tiles := make([]byte, lvl.W*lvl.H)
+	m := &protocol.Map{Width: lvl.W, Height: lvl.H}
+
+	for i := 0; i < lvl.H; i++ {
+		row := tiles[i*lvl.W : (i+1)*lvl.W]
+		for j := range row {
+			row[j] = tileFor(lvl.Get(i, j))
+		}
+
+		m.Rows = append(m.Rows, string(row))
+	}
+
+	return m, tiles
+}
+
+var actionNames = map[mpnethack.ActionType]string{
+	mpnethack.Move:   protocol.ActionMove,
+	mpnethack.Attack: protocol.ActionAttack,
+	mpnethack.Defend: protocol.ActionDefend,
+	mpnethack.Use:    protocol.ActionUse,
+}
+
+// Sends the state of the game.  Called by the game after every tick.
+func (s *JSONSession) Update() error {
+	s.mu.Lock()
+	g, pl, tiles := s.g, s.pl, s.tiles
+	s.mu.Unlock()
+
+	if g == nil || pl == nil {
+		return nil
+	}
+
+	g.RLock()
+	upd := buildUpdate(g, s, pl, tiles)
+	g.RUnlock()
+
+	return s.send(&protocol.Message{Type: protocol.TypeUpdate, Update: upd})
+}
+
+// Assumes the game lock is held
+func buildUpdate(g *mpnethack.Game, sess mpnethack.Session, pl *mpnethack.Player, tiles []byte) *protocol.Update {
+	lvl := g.Level
+	upd := &protocol.Update{
+		Frame: g.FrameNum,
+		Units: []protocol.Unit{},
+		Items: []protocol.FloorItem{},
+	}
+
+	for i := 0; i < lvl.H && len(tiles) == lvl.W*lvl.H; i++ {
+		for j := 0; j < lvl.W; j++ {
+			t := tileFor(lvl.Get(i, j))
+			if ind := i*lvl.W + j; tiles[ind] != t {
+				tiles[ind] = t
+				upd.Cells = append(upd.Cells, protocol.Cell{I: i, J: j, Tile: string(t)})
+			}
+		}
+	}
+
+	for name, other := range g.Players {
+		// only the ghost itself can see a dead player
+		if other.Dead && other != pl {
+			continue
+		}
+
+		upd.Units = append(upd.Units, protocol.Unit{
+			ID:     "player:" + name,
+			Kind:   "player",
+			Name:   name,
+			Marker: string(other.Marker),
+			I:      other.I,
+			J:      other.J,
+			HP:     other.Stats.HP,
+			MaxHP:  other.Stats.MaxHP,
+			Alive:  other.IsAlive(),
+		})
+	}
+
+	for i := range g.Mobs {
+		mob := &g.Mobs[i]
+
+		u := protocol.Unit{
+			ID:    "mob:" + strconv.Itoa(i),
+			Kind:  "mob",
+			Name:  mob.Name(),
+			I:     mob.I,
+			J:     mob.J,
+			HP:    mob.Stats.HP,
+			MaxHP: mob.Stats.MaxHP,
+			Alive: mob.IsAlive(),
+		}
+
+		if info, err := mpnethack.LookupMobInfo(mob.Type); err == nil && info.Marker != 0 {
+			u.Marker = string(info.Marker)
+		}
+
+		upd.Units = append(upd.Units, u)
+	}
+
+	for _, fi := range g.FloorItems {
+		upd.Items = append(upd.Items, protocol.FloorItem{I: fi.I, J: fi.J, Name: fi.Item.ShortName()})
+	}
+
+	ps := &protocol.PlayerState{
+		Name:   sess.UserName(),
+		I:      pl.I,
+		J:      pl.J,
+		Facing: pl.Facing.Name(),
+
+		HP:    pl.Stats.HP,
+		MaxHP: pl.Stats.MaxHP,
+		Level: pl.Stats.Level,
+		XP:    pl.Stats.XP,
+
+		Dead:         pl.Dead,
+		Ghost:        pl.Ghost,
+		RespawnTicks: int(pl.RespawnTick),
+
+		Inventory: []string{},
+	}
+
+	if w, ok := pl.Weapon.(*mpnethack.MeleeWeapon); pl.Weapon != nil && (!ok || w != nil) {
+		ps.Weapon = pl.Weapon.ShortName()
+	}
+
+	for _, itm := range pl.Inventory {
+		ps.Inventory = append(ps.Inventory, itm.ShortName())
+	}
+
+	for _, eff := range pl.Effects {
+		ps.Effects = append(ps.Effects, eff.Type.String())
+	}
+
+	upd.Player = ps
+
+	upd.Cooldowns = make(map[string]int)
+	for act, ticks := range g.GetCooldowns(sess, nil) {
+		if name, ok := actionNames[mpnethack.ActionType(act)]; ok {
+			upd.Cooldowns[name] = int(ticks)
+		}
+	}
+
+	return upd
+}
+
+func parseDirection(s string) (mpnethack.Direction, error) {
+	switch strings.ToLower(s) {
+	case "up", "north", "n":
+		return mpnethack.Up, nil
+	case "down", "south", "s":
+		return mpnethack.Down, nil
+	case "left", "west", "w":
+		return mpnethack.Left, nil
+	case "right", "east", "e":
+		return mpnethack.Right, nil
+	default:
+		return mpnethack.NoDirection, fmt.Errorf("unknown direction \"%s\"", s)
+	}
+}
+
+func (s *JSONSession) action(msg *protocol.Message) error {
+	g := s.Game()
+	if g == nil {
+		return mpnethack.ErrNotInGame
+	}
+
+	switch msg.Action {
+	case protocol.ActionMove:
+		direc, err := parseDirection(msg.Direction)
+		if err != nil {
+			return err
+		}
+
+		return g.Move(s, direc)
+
+	case protocol.ActionAttack:
+		return g.UserAction(s, mpnethack.Attack, 0)
+
+	case protocol.ActionDefend:
+		return g.UserAction(s, mpnethack.Defend, 0)
+
+	case protocol.ActionUse:
+		if msg.Item == nil {
+			return fmt.Errorf("%w: use needs an item", mpnethack.ErrBadArguments)
+		}
+
+		return g.UserAction(s, mpnethack.Use, int16(*msg.Item))
+
+	default:
+		return fmt.Errorf("%w \"%s\"", ErrUnknownAction, msg.Action)
+	}
+}
+
+// Handles one request from a logged in client
+func (s *JSONSession) handle(msg *protocol.Message) error {
+	switch msg.Type {
+	case protocol.TypeNewGame:
+		if s.Lobby == nil {
+			return mpnethack.ErrNoLobby
+		}
+
+		_, err := s.Lobby.NewGame(s)
+		return err
+
+	case protocol.TypeAction:
+		return s.action(msg)
+
+	case protocol.TypeChat:
+		s.ConsoleInput(msg.Text)
+		return nil
+
+	case protocol.TypeQuit:
+		s.Quit()
+		return nil
+
+	case protocol.TypeLogin:
+		return fmt.Errorf("already logged in")
+
+	default:
+		return fmt.Errorf("unknown message type \"%s\"", msg.Type)
+	}
+}
+
+// Runs a JSON client until it disconnects.  If name is empty, the client
+// must log in first.
+func ServeJSONClient(rw io.ReadWriteCloser, name string, lobby *mpnethack.Lobby) {
+	s := NewJSONSession(rw, lobby)
+	defer s.Quit()
+
+	go s.writeLoop()
+
+	s.send(&protocol.Message{Type: protocol.TypeHello, Version: protocol.Version})
+
+	dec := protocol.NewDecoder(rw)
+
+	for name == "" {
+		msg, err := dec.Decode()
+		if err != nil {
+			return
+		}
+
+		if msg.Type != protocol.TypeLogin {
+			s.sendError(ErrNotLoggedIn)
+			continue
+		}
+
+		if err := checkLogin(lobby, msg.Name); err != nil {
+			s.sendError(err)
+			if errors.Is(err, ErrBannedUser) {
+				return
+			}
+
+			continue
+		}
+
+		name = msg.Name
+	}
+
+	if lobby.IsBanned(name) {
+		s.sendError(ErrBannedUser)
+		return
+	}
+
+	handshakeDone(rw)
+
+	s.User = name
+	s.send(&protocol.Message{Type: protocol.TypeWelcome, Name: name, Version: protocol.Version})
+
+	lobby.AddSession(s)
+	defer lobby.RemoveSession(s)
+
+	dropped := true
+	defer func() { s.leaveGame(dropped) }()
+
+	if _, err := lobby.Reconnect(s); err != nil {
+		s.sendError(err)
+	}
+
+	for {
+		msg, err := dec.Decode()
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("json client \"%s\": %v", name, err)
+			}
+			return
+		}
+
+		if msg.Type == protocol.TypeQuit {
+			dropped = false
+		}
+
+		if err := s.handle(msg); err != nil {
+			s.sendError(err)
+		}
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+	}
+}
+
+// Listens for JSON protocol clients on addr
+func AcceptJSONLogins(addr string, lobby *mpnethack.Lobby) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("error listening for json clients: %v", err)
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("error in json accept: %v", err)
+			continue
+		}
+
+		lc := limitConn(conn, "json")
+		if lc == nil {
+			continue
+		}
+
+		log.Printf("json client connected from %v", conn.RemoteAddr())
+		go ServeJSONClient(lc, "", lobby)
+	}
+}
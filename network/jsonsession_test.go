@@ -0,0 +1,78 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/internal/testgame"
+	"github.com/sfstewman/mpnethack/protocol"
+)
+
+func TestBuildUpdate(t *testing.T) {
+	g := testgame.New(t)
+	alice := testgame.Join(t, g, "alice")
+
+	g.RLock()
+	m, tiles := mapTiles(g.Level)
+	upd := buildUpdate(g, alice, alice.Player(), tiles)
+	g.RUnlock()
+
+	if m.Width != 12 || m.Height != 12 || m.Rows[0] != "............" || m.Rows[1] != ".##########." || m.Rows[5] != ".#        #." {
+		t.Errorf("unexpected map %+v", m)
+	}
+
+	if len(upd.Cells) != 0 {
+		t.Errorf("expected no map changes, found %+v", upd.Cells)
+	}
+
+	if len(upd.Units) != 2 || upd.Units[0].ID != "player:alice" || upd.Units[1].ID != "mob:0" || !upd.Units[1].Alive {
+		t.Errorf("expected alice and the lemming, found %+v", upd.Units)
+	}
+
+	if upd.Player == nil || upd.Player.Name != "alice" || upd.Player.I != 5 || upd.Player.J != 5 || upd.Player.Weapon != "rusty sword" {
+		t.Errorf("unexpected player state %+v", upd.Player)
+	}
+
+	for _, act := range []string{protocol.ActionMove, protocol.ActionAttack, protocol.ActionDefend, protocol.ActionUse} {
+		if _, ok := upd.Cooldowns[act]; !ok {
+			t.Errorf("expected a cooldown for %s, found %v", act, upd.Cooldowns)
+		}
+	}
+
+	// only changed tiles are sent, once
+	g.Lock()
+	g.Level.Set(1, 1, mpnethack.MarkerEmpty)
+	g.Level.Set(4, 5, mpnethack.MarkerCactus)
+	g.Unlock()
+
+	expected := []protocol.Cell{
+		{I: 1, J: 1, Tile: string(protocol.TileEmpty)},
+		{I: 4, J: 5, Tile: string(protocol.TileCactus)},
+	}
+
+	g.RLock()
+	upd = buildUpdate(g, alice, alice.Player(), tiles)
+	g.RUnlock()
+
+	if !reflect.DeepEqual(upd.Cells, expected) {
+		t.Errorf("expected changes %+v but found %+v", expected, upd.Cells)
+	}
+
+	g.RLock()
+	upd = buildUpdate(g, alice, alice.Player(), tiles)
+	g.RUnlock()
+
+	if len(upd.Cells) != 0 {
+		t.Errorf("expected no changes the second time, found %+v", upd.Cells)
+	}
+
+	// without a map, no changes are sent
+	g.RLock()
+	upd = buildUpdate(g, alice, alice.Player(), nil)
+	g.RUnlock()
+
+	if len(upd.Cells) != 0 {
+		t.Errorf("expected no changes without a map, found %+v", upd.Cells)
+	}
+}
@@ -0,0 +1,210 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sfstewman/mpnethack"
+)
+
+var (
+	ErrTooManyConnections       = errors.New("too many connections")
+	ErrTooManyConnectionsFromIP = errors.New("too many connections from this address")
+)
+
+// Limits on connections from all front ends.  Zero means no limit.
+type Limits struct {
+	// Most connections open at once, and from a single IP address
+	MaxConnections      int
+	MaxConnectionsPerIP int
+
+	// Time a connection has to log in and set up its terminal
+	HandshakeTimeout time.Duration
+
+	// Time a connection may go without sending anything
+	IdleTimeout time.Duration
+
+	// Actions and chat lines per second a session may send, and how many
+	// it may send in a burst
+	InputRate  float64
+	InputBurst int
+}
+
+var DefaultLimits = Limits{
+	MaxConnections:      256,
+	MaxConnectionsPerIP: 8,
+	HandshakeTimeout:    30 * time.Second,
+	IdleTimeout:         30 * time.Minute,
+	InputRate:           20,
+	InputBurst:          40,
+}
+
+// Limits used by the front ends.  Set before accepting logins.
+var ConnectionLimits = DefaultLimits
+
+func (lim *Limits) inputLimiter() *mpnethack.InputLimiter {
+	return mpnethack.NewInputLimiter(lim.InputRate, lim.InputBurst)
+}
+
+// Counts open connections, overall and by IP address
+type connTracker struct {
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+var openConns = connTracker{perIP: make(map[string]int)}
+
+func remoteIP(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP.String()
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
+
+func (t *connTracker) acquire(ip string, lim *Limits) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if lim.MaxConnections > 0 && t.total >= lim.MaxConnections {
+		return ErrTooManyConnections
+	}
+
+	if lim.MaxConnectionsPerIP > 0 && t.perIP[ip] >= lim.MaxConnectionsPerIP {
+		return ErrTooManyConnectionsFromIP
+	}
+
+	t.total++
+	t.perIP[ip]++
+
+	return nil
+}
+
+func (t *connTracker) release(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total--
+	if t.perIP[ip]--; t.perIP[ip] <= 0 {
+		delete(t.perIP, ip)
+	}
+}
+
+// Connection counted against the connection limits.  The connection is
+// closed if its handshake doesn't finish in time, and after that, reads fail
+// once it has been idle for the idle timeout.
+type limitedConn struct {
+	net.Conn
+
+	ip        string
+	idle      time.Duration
+	handshake *time.Timer
+
+	// set once the handshake is done; until then the connection's owner
+	// may set its own read deadlines
+	loggedIn int32
+
+	closeOnce sync.Once
+}
+
+// Checks a new connection against ConnectionLimits.  Refused connections
+// are logged and closed, and nil is returned.
+func limitConn(conn net.Conn, frontEnd string) *limitedConn {
+	lim := ConnectionLimits
+	ip := remoteIP(conn.RemoteAddr())
+
+	if err := openConns.acquire(ip, &lim); err != nil {
+		log.Printf("refusing %s connection from %v: %v", frontEnd, conn.RemoteAddr(), err)
+		conn.Close()
+		return nil
+	}
+
+	lc := &limitedConn{
+		Conn: conn,
+		ip:   ip,
+		idle: lim.IdleTimeout,
+	}
+
+	if lim.HandshakeTimeout > 0 {
+		lc.handshake = time.AfterFunc(lim.HandshakeTimeout, func() {
+			log.Printf("%s connection from %v timed out during handshake", frontEnd, conn.RemoteAddr())
+			lc.closeConn()
+		})
+	}
+
+	return lc
+}
+
+// Stops the handshake timer once the connection has logged in, and starts
+// the idle timeout
+func (c *limitedConn) HandshakeDone() {
+	if c.handshake != nil {
+		c.handshake.Stop()
+	}
+
+	if atomic.CompareAndSwapInt32(&c.loggedIn, 0, 1) && c.idle > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.idle))
+	}
+}
+
+func (c *limitedConn) Read(p []byte) (int, error) {
+	if c.idle > 0 && atomic.LoadInt32(&c.loggedIn) != 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.idle))
+	}
+
+	return c.Conn.Read(p)
+}
+
+func (c *limitedConn) Close() error {
+	if c.handshake != nil {
+		c.handshake.Stop()
+	}
+
+	return c.closeConn()
+}
+
+// Releases the connection's place in the limits and closes it.  The
+// handshake timer calls this rather than Close, since the timer can fire
+// before limitConn has stored it.
+func (c *limitedConn) closeConn() error {
+	c.closeOnce.Do(func() { openConns.release(c.ip) })
+	return c.Conn.Close()
+}
+
+// Stops the handshake timer of a connection from limitConn.  Does nothing
+// for other connections.
+func handshakeDone(rw interface{}) {
+	if lc, ok := rw.(*limitedConn); ok {
+		lc.HandshakeDone()
+	}
+}
+
+// Listener whose connections are checked with limitConn.  Refused
+// connections are closed without being returned.
+type limitedListener struct {
+	net.Listener
+	frontEnd string
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if lc := limitConn(conn, l.frontEnd); lc != nil {
+			return lc, nil
+		}
+	}
+}
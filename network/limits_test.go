@@ -0,0 +1,200 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Address given as host:port
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "tcp" }
+func (a fakeAddr) String() string  { return string(a) }
+
+// Connection from a fixed address that records its read deadline and when
+// it is closed
+type addrConn struct {
+	fakeConn
+
+	addr   fakeAddr
+	closed chan struct{}
+
+	mu        sync.Mutex
+	closeOnce sync.Once
+	deadline  time.Time
+}
+
+func newAddrConn(addr string) *addrConn {
+	return &addrConn{
+		fakeConn: *newFakeConn(nil),
+		addr:     fakeAddr(addr),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *addrConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *addrConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *addrConn) readDeadline() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.deadline
+}
+
+func (c *addrConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Replaces the connection limits and open connection counts until the test
+// ends
+func setTestLimits(t *testing.T, lim Limits) {
+	saved := ConnectionLimits
+	t.Cleanup(func() { ConnectionLimits = saved })
+	ConnectionLimits = lim
+
+	openConns.mu.Lock()
+	total, perIP := openConns.total, openConns.perIP
+	openConns.total, openConns.perIP = 0, make(map[string]int)
+	openConns.mu.Unlock()
+
+	t.Cleanup(func() {
+		openConns.mu.Lock()
+		openConns.total, openConns.perIP = total, perIP
+		openConns.mu.Unlock()
+	})
+}
+
+func openCounts(ip string) (int, int) {
+	openConns.mu.Lock()
+	defer openConns.mu.Unlock()
+
+	return openConns.total, openConns.perIP[ip]
+}
+
+func TestConnectionLimits(t *testing.T) {
+	setTestLimits(t, Limits{MaxConnections: 3, MaxConnectionsPerIP: 2})
+
+	accept := func(addr string) (*limitedConn, *addrConn) {
+		conn := newAddrConn(addr)
+		return limitConn(conn, "test"), conn
+	}
+
+	a1, _ := accept("10.0.0.1:1001")
+	a2, _ := accept("10.0.0.1:1002")
+	if a1 == nil || a2 == nil {
+		t.Fatalf("expected two connections from 10.0.0.1 to be accepted")
+	}
+
+	// refused at the per-IP limit
+	if lc, conn := accept("10.0.0.1:1003"); lc != nil || !conn.isClosed() {
+		t.Errorf("expected a third connection from 10.0.0.1 to be refused and closed")
+	}
+
+	b1, _ := accept("10.0.0.2:1001")
+	if b1 == nil {
+		t.Fatalf("expected a connection from 10.0.0.2 to be accepted")
+	}
+
+	// refused at the overall limit
+	if lc, conn := accept("10.0.0.3:1001"); lc != nil || !conn.isClosed() {
+		t.Errorf("expected a fourth connection to be refused and closed")
+	}
+
+	if total, n := openCounts("10.0.0.1"); total != 3 || n != 2 {
+		t.Errorf("expected 3 connections, 2 from 10.0.0.1, found %d and %d", total, n)
+	}
+
+	// closing twice only releases the connection once
+	a1.Close()
+	a1.Close()
+
+	if total, n := openCounts("10.0.0.1"); total != 2 || n != 1 {
+		t.Errorf("expected 2 connections, 1 from 10.0.0.1, after a close, found %d and %d", total, n)
+	}
+
+	a3, _ := accept("10.0.0.1:1004")
+	if a3 == nil {
+		t.Fatalf("expected a connection to be accepted once another closed")
+	}
+
+	a2.Close()
+	a3.Close()
+	b1.Close()
+
+	if total, n := openCounts("10.0.0.1"); total != 0 || n != 0 {
+		t.Errorf("expected no connections, found %d", total)
+	}
+
+	if len(openConns.perIP) != 0 {
+		t.Errorf("expected no addresses to be tracked, found %v", openConns.perIP)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	setTestLimits(t, Limits{HandshakeTimeout: 10 * time.Millisecond, IdleTimeout: time.Minute})
+
+	// a connection that doesn't finish its handshake is closed
+	conn := newAddrConn("10.0.0.1:1001")
+	if lc := limitConn(conn, "test"); lc == nil {
+		t.Fatalf("expected the connection to be accepted")
+	}
+
+	select {
+	case <-conn.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the connection to be closed after the handshake timeout")
+	}
+
+	if total, _ := openCounts("10.0.0.1"); total != 0 {
+		t.Errorf("expected the timed out connection to be released, found %d open", total)
+	}
+
+	// one that does is kept, and reads start the idle timeout
+	conn = newAddrConn("10.0.0.1:1002")
+	lc := limitConn(conn, "test")
+	if lc == nil {
+		t.Fatalf("expected the connection to be accepted")
+	}
+	defer lc.Close()
+
+	lc.Read(make([]byte, 1))
+	if !conn.readDeadline().IsZero() {
+		t.Errorf("expected no idle deadline before the handshake is done")
+	}
+
+	lc.HandshakeDone()
+	if d := conn.readDeadline(); d.IsZero() || time.Until(d) < 30*time.Second {
+		t.Errorf("expected an idle deadline about a minute away, found %v", d)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if conn.isClosed() {
+		t.Errorf("expected the connection to stay open after the handshake")
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	lc.Read(make([]byte, 1))
+	if conn.readDeadline().IsZero() {
+		t.Errorf("expected a read to reset the idle deadline")
+	}
+}
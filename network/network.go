@@ -1,10 +1,13 @@
 package network
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/ssh"
 
@@ -14,19 +17,51 @@ import (
 	"github.com/sfstewman/mpnethack/user"
 )
 
+// Longest user name accepted by any front end
+const MaxNameLength = 32
+
+var (
+	ErrBannedUser   = errors.New("user is banned")
+	ErrBadUserName  = errors.New("names may only have letters, digits, spaces, '_' and '-'")
+	ErrLongUserName = fmt.Errorf("names may have at most %d characters", MaxNameLength)
+)
+
+// Checks that a user may log in with the name
+func checkLogin(lobby *mpnethack.Lobby, name string) error {
+	if name == "" {
+		return ErrBadUserName
+	}
+
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return ErrLongUserName
+	}
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '_' && r != '-' {
+			return ErrBadUserName
+		}
+	}
+
+	if lobby.IsBanned(name) {
+		return ErrBannedUser
+	}
+
+	return nil
+}
+
 func authLog(conn ssh.ConnMetadata, method string, err error) {
 	log.Printf("login attempt[%s] %v : %v\n", method, conn, err)
 }
 
 func AcceptNetworkLogins(hostKeyPath string, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
 	cfg := &ssh.ServerConfig{
-		NoClientAuth:    true,
 		AuthLogCallback: authLog,
 		BannerCallback: func(conn ssh.ConnMetadata) string {
 			return "WELCOME to multiplayer nethack\r\n"
 		},
 		ServerVersion: "SSH-2.0-mpnethack",
 	}
+	configureAuth(cfg)
 
 	{
 		hkData, err := ioutil.ReadFile(hostKeyPath)
@@ -54,7 +89,12 @@ func AcceptNetworkLogins(hostKeyPath string, lobby *mpnethack.Lobby, systemLog *
 			continue
 		}
 
-		go handleConnection(conn, cfg, lobby, systemLog)
+		lc := limitConn(conn, "ssh")
+		if lc == nil {
+			continue
+		}
+
+		go handleConnection(lc, cfg, lobby, systemLog)
 	}
 }
 
@@ -70,36 +110,120 @@ type PtyReq struct {
 	Modes []byte
 }
 
-func channelRequests(sess *user.Session, in <-chan *ssh.Request, cfgCh chan<- tui.IOScreenConfig) {
+// SSH subsystem that speaks the JSON protocol
+const JSONSubsystem = "mpnethack-json"
+
+type SubsystemReq struct {
+	Name string
+}
+
+type ExecReq struct {
+	Command string
+}
+
+type EnvReq struct {
+	Name, Value string
+}
+
+// Environment variables a client may set with env requests
+var EnvAllowlist = []string{"LANG", "LC_ALL", "LC_CTYPE", "COLORTERM"}
+
+func envAllowed(name string) bool {
+	for _, allowed := range EnvAllowlist {
+		if name == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// What a client asked for before starting its shell
+type shellRequest struct {
+	// Pty is false if the client never requested a pty
+	Pty    bool
+	Config tui.IOScreenConfig
+
+	// Environment variables sent with env requests
+	Env map[string]string
+}
+
+func channelRequests(sess *user.Session, in <-chan *ssh.Request, shellCh chan<- shellRequest, subsysCh chan<- string, execCh chan<- string) {
+	shell := shellRequest{Env: make(map[string]string)}
+
 	for req := range in {
 		log.Printf("request '%s' reply=%v len(payload)=%d\n", req.Type, req.WantReply, len(req.Payload))
 		switch req.Type {
 		case "shell":
+			if shellCh == nil {
+				req.Reply(false, nil)
+				continue
+			}
+
 			req.Reply(true, nil)
+			shell.Config.TrueColor = shell.Pty && tui.DetectTrueColor(shell.Config.Term, shell.Env["COLORTERM"])
+			shell.Config.Charset = tui.CharsetFromEnv(shell.Env)
+			shellCh <- shell
+			close(shellCh)
+			shellCh = nil
 
 		case "pty-req":
-			if cfgCh == nil {
+			if shellCh == nil {
 				req.Reply(false, nil)
 				continue
 			}
 
-			req.Reply(true, nil)
 			pty := PtyReq{}
 			err := ssh.Unmarshal(req.Payload, &pty)
 			if err != nil {
 				log.Printf("error pty request: %v\n", err)
+				req.Reply(false, nil)
 				continue
 			}
 
+			req.Reply(true, nil)
 			log.Printf("pty request: %+v\n", pty)
-			cfgCh <- tui.IOScreenConfig{
-				Term:      pty.Term,
-				Width:     int(pty.Width),
-				Height:    int(pty.Height),
-				TrueColor: false,
+			shell.Pty = true
+			shell.Config = tui.IOScreenConfig{
+				Term:   pty.Term,
+				Width:  int(pty.Width),
+				Height: int(pty.Height),
 			}
-			close(cfgCh)
-			cfgCh = nil
+
+		case "env":
+			env := EnvReq{}
+			if err := ssh.Unmarshal(req.Payload, &env); err != nil || shellCh == nil || !envAllowed(env.Name) {
+				req.Reply(false, nil)
+				continue
+			}
+
+			log.Printf("env request: %s=%q\n", env.Name, env.Value)
+			shell.Env[env.Name] = env.Value
+			req.Reply(true, nil)
+
+		case "subsystem":
+			sub := SubsystemReq{}
+			if err := ssh.Unmarshal(req.Payload, &sub); err != nil || subsysCh == nil || sub.Name != JSONSubsystem {
+				req.Reply(false, nil)
+				continue
+			}
+
+			req.Reply(true, nil)
+			subsysCh <- sub.Name
+			close(subsysCh)
+			subsysCh = nil
+
+		case "exec":
+			exec := ExecReq{}
+			if err := ssh.Unmarshal(req.Payload, &exec); err != nil || execCh == nil {
+				req.Reply(false, nil)
+				continue
+			}
+
+			req.Reply(true, nil)
+			execCh <- exec.Command
+			close(execCh)
+			execCh = nil
 
 		case "window-change":
 			log.Printf("window change: %d bytes\n", len(req.Payload))
@@ -109,6 +233,11 @@ func channelRequests(sess *user.Session, in <-chan *ssh.Request, cfgCh chan<- tu
 				log.Printf("window dims: %d x %d (%dpx x %dpx)\n",
 					wsz.Width, wsz.Height, wsz.WidthPix, wsz.HeightPix)
 
+				// before the shell starts, the size goes into its config
+				if shellCh != nil {
+					shell.Config.Width, shell.Config.Height = int(wsz.Width), int(wsz.Height)
+				}
+
 				sess.WindowResize(int(wsz.Width), int(wsz.Height))
 				if req.WantReply {
 					req.Reply(true, nil)
@@ -122,7 +251,9 @@ func channelRequests(sess *user.Session, in <-chan *ssh.Request, cfgCh chan<- tu
 	}
 }
 
-func handleConnection(c net.Conn, cfg *ssh.ServerConfig, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
+func handleConnection(c *limitedConn, cfg *ssh.ServerConfig, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
+	defer c.Close()
+
 	conn, chans, reqs, err := ssh.NewServerConn(c, cfg)
 	if err != nil {
 		log.Printf("failed to handshake: %v", err)
@@ -145,68 +276,138 @@ func handleConnection(c net.Conn, cfg *ssh.ServerConfig, lobby *mpnethack.Lobby,
 			return
 		}
 
-		cfgCh := make(chan tui.IOScreenConfig)
-		sess := user.NewSession("Grufmore the Dominable", user.Authenticated)
+		// buffered so a late request can't block the request handler
+		shellCh := make(chan shellRequest, 1)
+		subsysCh := make(chan string, 1)
+		execCh := make(chan string, 1)
 
-		fmt.Fprintf(channel, "\r\nConfiguring terminal\r\n")
+		name := conn.User()
+		if name == "" {
+			name = "Grufmore the Dominable"
+		}
 
-		go channelRequests(sess, requests, cfgCh)
-		cfg := <-cfgCh
+		if lobby.IsBanned(name) {
+			log.Printf("refusing login from banned user \"%s\" [%v]", name, conn.RemoteAddr())
+			fmt.Fprintf(channel, "\r\nYou are banned from this server.\r\n")
+			channel.Close()
+			return
+		}
 
-		tty := &SshTty{
-			Config:          cfg,
-			ReadWriteCloser: channel,
+		flags := user.Authenticated
+		if isAdminConn(conn) {
+			flags |= user.Administrator
 		}
 
-		fmt.Fprintf(channel, "\r\nConfiguring terminal: %+v\r\n\r\n", cfg)
+		sess := user.NewSession(name, flags)
+		sess.Lobby = lobby
 
-		log.Printf("creating screen with config: %+v", cfg)
+		go channelRequests(sess, requests, shellCh, subsysCh, execCh)
 
-		scr, err := tui.NewIOScreenFromTty(tty, cfg)
-		if err != nil {
-			log.Printf("error creating screen: %v", err)
+		var shell shellRequest
+		select {
+		case shell = <-shellCh:
+		case <-subsysCh:
+			c.HandshakeDone()
+			log.Printf("json client \"%s\" connected over ssh [%v]", name, conn.RemoteAddr())
+			ServeJSONClient(channel, name, lobby)
+			return
+
+		case line := <-execCh:
+			c.HandshakeDone()
+			serveExec(channel, newExecSession(name, isAdminConn(conn)), lobby, line)
 			return
 		}
 
-		if err := scr.Init(); err != nil {
-			log.Printf("error initializing screen: %v", err)
+		c.HandshakeDone()
+
+		if !shell.Pty {
+			log.Printf("session [%s] [%v] has no pty", name, conn.RemoteAddr())
+			refuseNoPty(channel)
 			return
 		}
 
-		// !!! FIXME !!!
-		lobby := &mpnethack.Lobby{}
-
-		ui := tui.SetupUI(sess, lobby, systemLog)
-		sess.UI = ui
-		sess.Tty = tty
-		ui.App.SetScreen(scr)
-
-		/*
-			term := terminal.NewTerminal(channel, "> ")
-			go func() {
-				defer channel.Close()
-				for {
-					line, err := term.ReadLine()
-
-					if err == io.EOF {
-						fmt.Printf("connection closed\n")
-						break
-					}
-					if err != nil {
-						log.Printf("error reading line: %v", err)
-						break
-					}
-
-					fmt.Fprintf(term, "received: %s\n", line)
-					fmt.Println(line)
-				}
-			}()
-		*/
+		cfg := shell.Config
+		if term, fellBack := tui.ResolveTerm(cfg.Term); fellBack {
+			log.Printf("session [%s] has unknown terminal \"%s\", using %s", name, cfg.Term, term)
+			fmt.Fprintf(channel, "\r\nUnknown terminal \"%s\", using %s instead.\r\n", cfg.Term, term)
+			cfg.Term = term
+		}
 
-		if err := ui.App.Run(); err != nil {
-			log.Printf("session [%s : %p] error: %v", sess.User, sess, err)
+		tty := &SshTty{
+			Config:          cfg,
+			ReadWriteCloser: channel,
 		}
 
+		fmt.Fprintf(channel, "\r\nConfiguring terminal: %+v\r\n\r\n", cfg)
+
+		runSession(sess, tty, lobby, systemLog)
 		return
 	}
 }
+
+// Tells a client that asked for a shell without a pty how to connect, and
+// closes the channel
+func refuseNoPty(channel ssh.Channel) {
+	fmt.Fprintf(channel.Stderr(), "mpnethack needs a terminal, but this connection has no pty.\n"+
+		"Connect with \"ssh -t\", or run a command without one (try \"ssh <host> help\").\n")
+
+	channel.SendRequest("exit-status", false, ssh.Marshal(&exitStatusMsg{1}))
+	channel.Close()
+}
+
+// Sets up the screen and UI for a connected session, and runs the UI until
+// the session ends
+func runSession(sess *user.Session, tty *SshTty, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
+	cfg := tty.Config
+
+	log.Printf("creating screen with config: %+v", cfg)
+
+	scr, err := tui.NewIOScreenFromTty(tty, cfg)
+	if err != nil {
+		log.Printf("error creating screen: %v", err)
+		return
+	}
+
+	if err := scr.Init(); err != nil {
+		log.Printf("error initializing screen: %v", err)
+		return
+	}
+
+	lobby.AddSession(sess)
+	defer lobby.RemoveSession(sess)
+
+	if g, err := lobby.Reconnect(sess); err != nil {
+		log.Printf("session [%s : %p] error reconnecting: %v", sess.User, sess, err)
+	} else if g != nil {
+		log.Printf("session [%s : %p] reconnected to game %p", sess.User, sess, g)
+	}
+
+	if sess.Input == nil {
+		sess.Input = ConnectionLimits.inputLimiter()
+	}
+
+	ui := tui.SetupUI(sess, lobby, systemLog)
+	sess.UI = ui
+	sess.Tty = tty
+	ui.App.SetScreen(scr)
+
+	err = ui.App.Run()
+	if err != nil {
+		log.Printf("session [%s : %p] error: %v", sess.User, sess, err)
+	}
+
+	switch {
+	case sess.G == nil:
+		// in the lobby
+
+	case sess.P == nil:
+		sess.StopSpectating()
+
+	case err != nil:
+		// the connection dropped; hold the character for a reconnect
+		sess.G.PlayerDisconnect(sess)
+
+	default:
+		sess.G.PlayerLeave(sess)
+	}
+}
@@ -0,0 +1,33 @@
+package network
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sfstewman/mpnethack"
+)
+
+func TestCheckLogin(t *testing.T) {
+	lobby := &mpnethack.Lobby{}
+	lobby.Ban("mallory")
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"alice", nil},
+		{"Sir Robin-the_2nd", nil},
+		{"", ErrBadUserName},
+		{"bob!", ErrBadUserName},
+		{strings.Repeat("x", MaxNameLength), nil},
+		{strings.Repeat("x", MaxNameLength+1), ErrLongUserName},
+		{strings.Repeat("é", MaxNameLength), nil},
+		{"Mallory", ErrBannedUser},
+	}
+
+	for _, tc := range tests {
+		if err := checkLogin(lobby, tc.name); err != tc.err {
+			t.Errorf("%q: expected %v but found %v", tc.name, tc.err, err)
+		}
+	}
+}
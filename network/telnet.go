@@ -0,0 +1,428 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/chat"
+	"github.com/sfstewman/mpnethack/tui"
+	"github.com/sfstewman/mpnethack/user"
+)
+
+// Telnet commands and options (RFC 854, 857, 858, 1073, 1091)
+const (
+	telnetSE   byte = 240
+	telnetSB   byte = 250
+	telnetWILL byte = 251
+	telnetWONT byte = 252
+	telnetDO   byte = 253
+	telnetDONT byte = 254
+	telnetIAC  byte = 255
+
+	telnetOptEcho  byte = 1
+	telnetOptSGA   byte = 3
+	telnetOptTTYPE byte = 24
+	telnetOptNAWS  byte = 31
+
+	telnetTTypeIs   byte = 0
+	telnetTTypeSend byte = 1
+)
+
+const (
+	// Terminal settings used until the client tells us otherwise
+	DefaultTelnetTerm   = "xterm"
+	DefaultTelnetWidth  = 80
+	DefaultTelnetHeight = 24
+
+	// Longest user name accepted at the telnet login prompt
+	MaxTelnetNameLength = MaxNameLength
+)
+
+type telnetState int
+
+const (
+	telnetData telnetState = iota
+	telnetCommand
+	telnetOption
+	telnetSub
+	telnetSubIAC
+	telnetCR
+)
+
+// Telnet connection.  Reads strip out and handle option negotiation, and
+// writes escape IAC bytes, so the connection can be used as a plain tty.
+//
+// The server echoes input and suppresses go-ahead, which puts clients in
+// character mode.  Window size (NAWS) and terminal type (TTYPE) are
+// requested from the client.
+type TelnetConn struct {
+	conn net.Conn
+
+	wmu sync.Mutex
+
+	// read state; only used by the reading goroutine
+	state telnetState
+	cmd   byte
+	sub   []byte
+	rbuf  []byte
+
+	mu       sync.Mutex
+	term     string
+	width    int
+	height   int
+	onResize func(w, h int)
+}
+
+func NewTelnetConn(conn net.Conn) *TelnetConn {
+	return &TelnetConn{
+		conn:   conn,
+		rbuf:   make([]byte, 1024),
+		width:  DefaultTelnetWidth,
+		height: DefaultTelnetHeight,
+	}
+}
+
+// Asks the client for character mode, its window size and its terminal type
+func (tc *TelnetConn) Negotiate() error {
+	_, err := tc.writeRaw([]byte{
+		telnetIAC, telnetWILL, telnetOptEcho,
+		telnetIAC, telnetWILL, telnetOptSGA,
+		telnetIAC, telnetDO, telnetOptSGA,
+		telnetIAC, telnetDO, telnetOptNAWS,
+		telnetIAC, telnetDO, telnetOptTTYPE,
+	})
+
+	return err
+}
+
+// Terminal settings the client has reported so far
+func (tc *TelnetConn) Config() tui.IOScreenConfig {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	term := tc.term
+	if term == "" {
+		term = DefaultTelnetTerm
+	}
+
+	if resolved, fellBack := tui.ResolveTerm(term); fellBack {
+		log.Printf("telnet client has unknown terminal \"%s\", using %s", term, resolved)
+		term = resolved
+	}
+
+	// telnet has no way to send COLORTERM, so go by the terminfo entry
+	return tui.IOScreenConfig{
+		Term:      term,
+		Width:     tc.width,
+		Height:    tc.height,
+		TrueColor: tui.DetectTrueColor(term, ""),
+	}
+}
+
+// Sets the function called when the client's window changes size
+func (tc *TelnetConn) NotifyResize(cb func(w, h int)) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.onResize = cb
+}
+
+func (tc *TelnetConn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for {
+		max := len(p)
+		if max > len(tc.rbuf) {
+			max = len(tc.rbuf)
+		}
+
+		nr, err := tc.conn.Read(tc.rbuf[:max])
+
+		n := 0
+		for _, b := range tc.rbuf[:nr] {
+			if tc.filter(b) {
+				p[n] = b
+				n++
+			}
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// Runs one byte through the telnet state machine.  Returns true if the byte
+// is data.
+func (tc *TelnetConn) filter(b byte) bool {
+	switch tc.state {
+	case telnetCR:
+		// clients send CR NUL or CR LF for the return key
+		tc.state = telnetData
+		if b == 0 || b == '\n' {
+			return false
+		}
+		return tc.filter(b)
+
+	case telnetCommand:
+		switch b {
+		case telnetIAC:
+			tc.state = telnetData
+			return true
+
+		case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+			tc.cmd = b
+			tc.state = telnetOption
+
+		case telnetSB:
+			tc.sub = tc.sub[:0]
+			tc.state = telnetSub
+
+		default:
+			tc.state = telnetData
+		}
+
+	case telnetOption:
+		tc.state = telnetData
+		tc.option(tc.cmd, b)
+
+	case telnetSub:
+		if b == telnetIAC {
+			tc.state = telnetSubIAC
+		} else if len(tc.sub) < 256 {
+			tc.sub = append(tc.sub, b)
+		}
+
+	case telnetSubIAC:
+		switch b {
+		case telnetSE:
+			tc.state = telnetData
+			tc.subnegotiation(tc.sub)
+		case telnetIAC:
+			tc.state = telnetSub
+			tc.sub = append(tc.sub, telnetIAC)
+		default:
+			tc.state = telnetSub
+		}
+
+	default:
+		switch b {
+		case telnetIAC:
+			tc.state = telnetCommand
+		case '\r':
+			tc.state = telnetCR
+			return true
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (tc *TelnetConn) option(cmd byte, opt byte) {
+	switch cmd {
+	case telnetWILL:
+		switch opt {
+		case telnetOptTTYPE:
+			tc.writeRaw([]byte{telnetIAC, telnetSB, telnetOptTTYPE, telnetTTypeSend, telnetIAC, telnetSE})
+		case telnetOptNAWS, telnetOptSGA:
+			// requested in Negotiate
+		default:
+			tc.writeRaw([]byte{telnetIAC, telnetDONT, opt})
+		}
+
+	case telnetDO:
+		switch opt {
+		case telnetOptEcho, telnetOptSGA:
+			// offered in Negotiate
+		default:
+			tc.writeRaw([]byte{telnetIAC, telnetWONT, opt})
+		}
+	}
+}
+
+func (tc *TelnetConn) subnegotiation(sub []byte) {
+	if len(sub) == 0 {
+		return
+	}
+
+	switch sub[0] {
+	case telnetOptNAWS:
+		if len(sub) < 5 {
+			return
+		}
+
+		w := int(binary.BigEndian.Uint16(sub[1:3]))
+		h := int(binary.BigEndian.Uint16(sub[3:5]))
+		if w == 0 || h == 0 {
+			return
+		}
+
+		tc.mu.Lock()
+		tc.width = w
+		tc.height = h
+		cb := tc.onResize
+		tc.mu.Unlock()
+
+		log.Printf("telnet window size: %d x %d", w, h)
+		if cb != nil {
+			cb(w, h)
+		}
+
+	case telnetOptTTYPE:
+		if len(sub) < 2 || sub[1] != telnetTTypeIs {
+			return
+		}
+
+		term := strings.ToLower(string(sub[2:]))
+		log.Printf("telnet terminal type: %s", term)
+
+		tc.mu.Lock()
+		tc.term = term
+		tc.mu.Unlock()
+	}
+}
+
+// Writes data, escaping IAC bytes
+func (tc *TelnetConn) Write(p []byte) (int, error) {
+	if bytes.IndexByte(p, telnetIAC) < 0 {
+		return tc.writeRaw(p)
+	}
+
+	escaped := bytes.ReplaceAll(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+	if _, err := tc.writeRaw(escaped); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (tc *TelnetConn) writeRaw(p []byte) (int, error) {
+	tc.wmu.Lock()
+	defer tc.wmu.Unlock()
+
+	return tc.conn.Write(p)
+}
+
+func (tc *TelnetConn) Close() error {
+	return tc.conn.Close()
+}
+
+// Reads a line in character mode, echoing what the user types
+func (tc *TelnetConn) readLine(max int) (string, error) {
+	var line []rune
+	buf := make([]byte, 1)
+
+	for {
+		if _, err := io.ReadFull(tc, buf); err != nil {
+			return "", err
+		}
+
+		switch b := buf[0]; {
+		case b == '\r' || b == '\n':
+			tc.Write([]byte("\r\n"))
+			return string(line), nil
+
+		case b == 8 || b == 127:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				tc.Write([]byte("\b \b"))
+			}
+
+		case b == 3 || b == 4:
+			return "", io.EOF
+
+		case b >= ' ' && b < 127 && len(line) < max:
+			line = append(line, rune(b))
+			tc.Write(buf)
+		}
+	}
+}
+
+// Listens for telnet connections on addr
+func AcceptTelnetLogins(addr string, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("error listening for telnet connections: %v", err)
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("error in telnet accept: %v", err)
+			continue
+		}
+
+		lc := limitConn(conn, "telnet")
+		if lc == nil {
+			continue
+		}
+
+		go handleTelnetConnection(lc, lobby, systemLog)
+	}
+}
+
+func handleTelnetConnection(conn *limitedConn, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
+	tc := NewTelnetConn(conn)
+	defer tc.Close()
+
+	if err := tc.Negotiate(); err != nil {
+		log.Printf("telnet negotiation with %v failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	fmt.Fprintf(tc, "WELCOME to multiplayer nethack\r\n\r\n")
+
+	var name string
+	for {
+		fmt.Fprintf(tc, "Name: ")
+
+		line, err := tc.readLine(MaxTelnetNameLength)
+		if err != nil {
+			return
+		}
+
+		name = strings.TrimSpace(line)
+
+		err = checkLogin(lobby, name)
+		if err == nil {
+			break
+		}
+
+		if errors.Is(err, ErrBannedUser) {
+			log.Printf("refusing telnet login from banned user \"%s\" [%v]", name, conn.RemoteAddr())
+			fmt.Fprintf(tc, "\r\nYou are banned from this server.\r\n")
+			return
+		}
+
+		fmt.Fprintf(tc, "%v\r\n", err)
+	}
+
+	conn.HandshakeDone()
+
+	sess := user.NewSession(name, user.Authenticated)
+	sess.Lobby = lobby
+
+	cfg := tc.Config()
+	tty := &SshTty{
+		Config:          cfg,
+		ReadWriteCloser: tc,
+	}
+
+	sess.Tty = tty
+	tc.NotifyResize(sess.WindowResize)
+
+	log.Printf("telnet login from \"%s\" [%v], terminal %+v", name, conn.RemoteAddr(), cfg)
+	runSession(sess, tty, lobby, systemLog)
+}
@@ -0,0 +1,166 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+// Connection that reads from a fixed input and records what is written
+type fakeConn struct {
+	net.Conn
+
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func newFakeConn(input []byte) *fakeConn {
+	return &fakeConn{in: bytes.NewReader(input)}
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+func (c *fakeConn) Close() error                { return nil }
+
+func TestTelnetRead(t *testing.T) {
+	const (
+		IAC  = telnetIAC
+		SB   = telnetSB
+		SE   = telnetSE
+		WILL = telnetWILL
+		DO   = telnetDO
+	)
+
+	tests := []struct {
+		name  string
+		input []byte
+		data  string
+		reply []byte
+	}{
+		{"plain", []byte("hello"), "hello", nil},
+		{"escaped IAC", []byte{'a', IAC, IAC, 'b'}, "a\xffb", nil},
+		{"CR NUL", []byte{'a', '\r', 0, 'b'}, "a\rb", nil},
+		{"CR LF", []byte("a\r\nb"), "a\rb", nil},
+		{"bare CR", []byte("a\rb"), "a\rb", nil},
+		{"CR then IAC", []byte{'\r', IAC, IAC}, "\r\xff", nil},
+		{"option", []byte{'a', IAC, WILL, telnetOptSGA, 'b'}, "ab", nil},
+		{"command", []byte{'a', IAC, 241, 'b'}, "ab", nil},
+		{"refused option", []byte{IAC, DO, 42}, "", []byte{IAC, telnetWONT, 42}},
+		{"ttype offered", []byte{IAC, WILL, telnetOptTTYPE}, "",
+			[]byte{IAC, SB, telnetOptTTYPE, telnetTTypeSend, IAC, SE}},
+		{"naws", []byte{'a', IAC, SB, telnetOptNAWS, 0, 100, 0, 40, IAC, SE, 'b'}, "ab", nil},
+		{"ttype", []byte{IAC, SB, telnetOptTTYPE, telnetTTypeIs, 'X', 'T', 'E', 'R', 'M', IAC, SE}, "", nil},
+	}
+
+	for _, tc := range tests {
+		conn := newFakeConn(tc.input)
+		tn := NewTelnetConn(conn)
+
+		data, err := io.ReadAll(tn)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+
+		if string(data) != tc.data {
+			t.Errorf("%s: expected data %q but found %q", tc.name, tc.data, data)
+		}
+
+		if !bytes.Equal(conn.out.Bytes(), tc.reply) {
+			t.Errorf("%s: expected reply %v but found %v", tc.name, tc.reply, conn.out.Bytes())
+		}
+	}
+}
+
+func TestTelnetSubnegotiation(t *testing.T) {
+	const (
+		IAC = telnetIAC
+		SB  = telnetSB
+		SE  = telnetSE
+	)
+
+	// a width of 255 has to be escaped inside the subnegotiation
+	input := []byte{
+		IAC, SB, telnetOptNAWS, 0, IAC, IAC, 0, 50, IAC, SE,
+		IAC, SB, telnetOptTTYPE, telnetTTypeIs, 'V', 'T', '1', '0', '0', IAC, SE,
+	}
+
+	tc := NewTelnetConn(newFakeConn(input))
+
+	var resized [2]int
+	tc.NotifyResize(func(w, h int) { resized = [2]int{w, h} })
+
+	if _, err := io.ReadAll(tc); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if resized != [2]int{255, 50} {
+		t.Errorf("expected a resize to 255x50, found %v", resized)
+	}
+
+	if tc.width != 255 || tc.height != 50 || tc.term != "vt100" {
+		t.Errorf("expected a 255x50 vt100, found %dx%d %q", tc.width, tc.height, tc.term)
+	}
+
+	// zero sizes are ignored
+	tc = NewTelnetConn(newFakeConn([]byte{IAC, SB, telnetOptNAWS, 0, 0, 0, 50, IAC, SE}))
+	if _, err := io.ReadAll(tc); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if tc.width != DefaultTelnetWidth || tc.height != DefaultTelnetHeight {
+		t.Errorf("expected the default size, found %dx%d", tc.width, tc.height)
+	}
+}
+
+func TestTelnetWrite(t *testing.T) {
+	tests := []struct {
+		data string
+		raw  string
+	}{
+		{"hello", "hello"},
+		{"a\xffb", "a\xff\xffb"},
+		{"\xff\xff", "\xff\xff\xff\xff"},
+	}
+
+	for _, tc := range tests {
+		conn := newFakeConn(nil)
+		n, err := NewTelnetConn(conn).Write([]byte(tc.data))
+		if err != nil || n != len(tc.data) {
+			t.Errorf("%q: expected %d bytes written, found %d (error %v)", tc.data, len(tc.data), n, err)
+		}
+
+		if got := conn.out.String(); got != tc.raw {
+			t.Errorf("%q: expected %q but found %q", tc.data, tc.raw, got)
+		}
+	}
+}
+
+func TestTelnetConfig(t *testing.T) {
+	tests := []struct {
+		term      string
+		expected  string
+		trueColor bool
+	}{
+		{"", DefaultTelnetTerm, false},
+		{"vt100", "vt100", false},
+		{"xterm-direct", "xterm-direct", true},
+		{"no-such-terminal", "xterm-256color", false},
+	}
+
+	for _, tc := range tests {
+		tn := NewTelnetConn(newFakeConn(nil))
+		tn.term = tc.term
+
+		cfg := tn.Config()
+		if cfg.Term != tc.expected || cfg.TrueColor != tc.trueColor {
+			t.Errorf("%q: expected %s (truecolor %v), found %s (truecolor %v)",
+				tc.term, tc.expected, tc.trueColor, cfg.Term, cfg.TrueColor)
+		}
+
+		if cfg.Width != DefaultTelnetWidth || cfg.Height != DefaultTelnetHeight {
+			t.Errorf("%q: expected the default size, found %dx%d", tc.term, cfg.Width, cfg.Height)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package network
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/sfstewman/mpnethack"
+	"github.com/sfstewman/mpnethack/chat"
+	"github.com/sfstewman/mpnethack/tui"
+	"github.com/sfstewman/mpnethack/user"
+)
+
+//go:embed web/index.html web/term.js web/term.css
+var webClientFiles embed.FS
+
+// Terminal type of the web client
+const WebClientTerm = "xterm-256color"
+
+// Control message from the web client
+type webMessage struct {
+	Type    string `json:"type"`
+	Cols    int    `json:"cols,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Serves the web client on addr, and bridges its WebSocket to a session
+func AcceptWebLogins(addr string, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
+	mux := http.NewServeMux()
+
+	mux.Handle("/", webClientHandler())
+
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(w, r, lobby, systemLog)
+	})
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("error listening for web logins: %v", err)
+	}
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: ConnectionLimits.HandshakeTimeout,
+	}
+
+	if err := srv.Serve(&limitedListener{Listener: ln, frontEnd: "web"}); err != nil {
+		log.Fatalf("error serving web logins: %v", err)
+	}
+}
+
+// Serves the page, script and styles of the web client
+func webClientHandler() http.Handler {
+	files, err := fs.Sub(webClientFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+
+	return http.FileServer(http.FS(files))
+}
+
+// Terminal size from a query parameter, or def if it's missing or invalid
+func queryDimension(r *http.Request, key string, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil || n <= 0 || n > 1000 {
+		return def
+	}
+
+	return n
+}
+
+func handleWebSocket(w http.ResponseWriter, r *http.Request, lobby *mpnethack.Lobby, systemLog *chat.SystemLog) {
+	ws, err := UpgradeWebSocket(w, r)
+	if err != nil {
+		log.Printf("websocket upgrade from %v failed: %v", r.RemoteAddr, err)
+		return
+	}
+	defer ws.Close()
+
+	name := r.URL.Query().Get("name")
+	if err := checkLogin(lobby, name); err != nil {
+		if errors.Is(err, ErrBannedUser) {
+			log.Printf("refusing web login from banned user \"%s\" [%v]", name, r.RemoteAddr)
+		}
+
+		msg, _ := json.Marshal(webMessage{Type: "error", Message: fmt.Sprintf("Login refused: %v", err)})
+		ws.WriteText(string(msg))
+		return
+	}
+
+	handshakeDone(ws.conn)
+
+	sess := user.NewSession(name, user.Authenticated)
+	sess.Lobby = lobby
+
+	cfg := tui.IOScreenConfig{
+		Term:      WebClientTerm,
+		Width:     queryDimension(r, "cols", DefaultTelnetWidth),
+		Height:    queryDimension(r, "rows", DefaultTelnetHeight),
+		TrueColor: true,
+	}
+
+	tty := &SshTty{
+		Config:          cfg,
+		ReadWriteCloser: ws,
+	}
+	sess.Tty = tty
+
+	ws.OnText = func(data []byte) {
+		var msg webMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("bad message from web client \"%s\": %v", name, err)
+			return
+		}
+
+		if msg.Type == "resize" && msg.Cols > 0 && msg.Rows > 0 {
+			sess.WindowResize(msg.Cols, msg.Rows)
+		}
+	}
+
+	log.Printf("web login from \"%s\" [%v], terminal %+v", name, r.RemoteAddr, cfg)
+	runSession(sess, tty, lobby, systemLog)
+}
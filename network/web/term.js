@@ -0,0 +1,871 @@
+// Terminal for the mpnethack web client.
+//
+// This understands the xterm-256color sequences that tcell sends (cursor
+// movement, erasing, scrolling, SGR colors including truecolor, the
+// alternate screen and the DEC line drawing set), and turns keyboard input
+// into the sequences tcell expects.  It is served by the game, so the client
+// works without loading anything from a third party.
+(function (global) {
+"use strict";
+
+var ANSI_COLORS = [
+  "#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+  "#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff"
+];
+
+var CUBE_LEVELS = [0, 95, 135, 175, 215, 255];
+
+function rgb(r, g, b) {
+  return "rgb(" + r + "," + g + "," + b + ")";
+}
+
+function color256(n) {
+  if (n < 16) {
+    return ANSI_COLORS[n];
+  }
+
+  if (n < 232) {
+    n -= 16;
+    return rgb(CUBE_LEVELS[Math.floor(n / 36)], CUBE_LEVELS[Math.floor(n / 6) % 6], CUBE_LEVELS[n % 6]);
+  }
+
+  var g = 8 + (n - 232) * 10;
+  return rgb(g, g, g);
+}
+
+// DEC special graphics, selected with ESC ( 0
+var LINE_DRAWING = {
+  "`": "◆", "a": "▒", "f": "°", "g": "±", "h": "░", "i": "␋",
+  "j": "┘", "k": "┐", "l": "┌", "m": "└", "n": "┼", "o": "⎺",
+  "p": "⎻", "q": "─", "r": "⎼", "s": "⎽", "t": "├", "u": "┤",
+  "v": "┴", "w": "┬", "x": "│", "y": "≤", "z": "≥", "{": "π",
+  "|": "≠", "}": "£", "~": "·", "0": "█", "+": "→", ",": "←",
+  "-": "↑", ".": "↓"
+};
+
+var ATTR_BOLD = 1, ATTR_DIM = 2, ATTR_ITALIC = 4, ATTR_UNDERLINE = 8,
+    ATTR_BLINK = 16, ATTR_REVERSE = 32, ATTR_HIDDEN = 64, ATTR_STRIKE = 128;
+
+var GROUND = 0, ESCAPE = 1, CSI = 2, OSC = 3, OSC_ESCAPE = 4, CHARSET = 5, OTHER_CHARSET = 6;
+
+// Keys that don't produce text
+var KEYS = {
+  "Enter": "\r", "Backspace": "\x7f", "Tab": "\t", "Escape": "\x1b",
+  "Insert": "\x1b[2~", "Delete": "\x1b[3~", "PageUp": "\x1b[5~", "PageDown": "\x1b[6~",
+  "F1": "\x1bOP", "F2": "\x1bOQ", "F3": "\x1bOR", "F4": "\x1bOS",
+  "F5": "\x1b[15~", "F6": "\x1b[17~", "F7": "\x1b[18~", "F8": "\x1b[19~",
+  "F9": "\x1b[20~", "F10": "\x1b[21~", "F11": "\x1b[23~", "F12": "\x1b[24~"
+};
+
+// Cursor keys, which change with the application cursor mode
+var CURSOR_KEYS = {
+  "ArrowUp": "A", "ArrowDown": "B", "ArrowRight": "C", "ArrowLeft": "D", "Home": "H", "End": "F"
+};
+
+function blankCell(pen) {
+  return { ch: " ", fg: pen ? pen.fg : null, bg: pen ? pen.bg : null, attrs: 0 };
+}
+
+function Terminal() {
+  this.cols = 80;
+  this.rows = 24;
+
+  this.dataHandlers = [];
+  this.resizeHandlers = [];
+  this.decoder = new TextDecoder("utf-8");
+
+  this.element = null;
+  this.screen = null;
+  this.listeners = [];
+  this.renderPending = false;
+
+  this.reset();
+}
+
+Terminal.prototype.reset = function () {
+  this.pen = { fg: null, bg: null, attrs: 0 };
+  this.cursorX = 0;
+  this.cursorY = 0;
+  this.wrapPending = false;
+  this.cursorVisible = true;
+  this.applicationCursor = false;
+  this.lineDrawing = false;
+  this.saved = null;
+
+  this.scrollTop = 0;
+  this.scrollBottom = this.rows - 1;
+
+  this.state = GROUND;
+  this.params = "";
+
+  this.lines = [];
+  for (var i = 0; i < this.rows; i++) {
+    this.lines.push(this.blankLine());
+  }
+
+  this.dirty = [];
+  this.touchAll();
+};
+
+Terminal.prototype.blankLine = function () {
+  var line = [];
+  for (var j = 0; j < this.cols; j++) {
+    line.push(blankCell(this.pen));
+  }
+  return line;
+};
+
+Terminal.prototype.touch = function (i) {
+  this.dirty[i] = true;
+  this.scheduleRender();
+};
+
+Terminal.prototype.touchAll = function () {
+  for (var i = 0; i < this.rows; i++) {
+    this.dirty[i] = true;
+  }
+  this.scheduleRender();
+};
+
+Terminal.prototype.onData = function (fn) {
+  this.dataHandlers.push(fn);
+};
+
+Terminal.prototype.onResize = function (fn) {
+  this.resizeHandlers.push(fn);
+};
+
+Terminal.prototype.send = function (data) {
+  for (var k = 0; k < this.dataHandlers.length; k++) {
+    this.dataHandlers[k](data);
+  }
+};
+
+Terminal.prototype.listen = function (target, type, fn) {
+  target.addEventListener(type, fn);
+  this.listeners.push([target, type, fn]);
+};
+
+Terminal.prototype.open = function (parent) {
+  var self = this;
+
+  this.element = document.createElement("div");
+  this.element.className = "term";
+  this.element.tabIndex = 0;
+
+  this.screen = document.createElement("div");
+  this.screen.className = "term-screen";
+  this.element.appendChild(this.screen);
+
+  this.probe = document.createElement("span");
+  this.probe.className = "term-probe";
+  this.probe.textContent = "MMMMMMMMMM";
+  this.element.appendChild(this.probe);
+
+  parent.appendChild(this.element);
+
+  this.listen(this.element, "keydown", function (ev) { self.keyDown(ev); });
+  this.listen(this.element, "paste", function (ev) {
+    ev.preventDefault();
+    self.send(ev.clipboardData.getData("text").replace(/\r?\n/g, "\r"));
+  });
+
+  this.touchAll();
+  this.element.focus();
+};
+
+Terminal.prototype.dispose = function () {
+  for (var k = 0; k < this.listeners.length; k++) {
+    var l = this.listeners[k];
+    l[0].removeEventListener(l[1], l[2]);
+  }
+  this.listeners = [];
+
+  if (this.element && this.element.parentNode) {
+    this.element.parentNode.removeChild(this.element);
+  }
+  this.element = null;
+  this.screen = null;
+};
+
+// Sizes the terminal to fill its parent
+Terminal.prototype.fit = function () {
+  if (!this.element) {
+    return;
+  }
+
+  var parent = this.element.parentNode;
+  var rect = this.probe.getBoundingClientRect();
+  var cw = rect.width / this.probe.textContent.length;
+  var ch = rect.height;
+  if (cw <= 0 || ch <= 0) {
+    return;
+  }
+
+  var cols = Math.max(2, Math.floor(parent.clientWidth / cw));
+  var rows = Math.max(1, Math.floor(parent.clientHeight / ch));
+  this.resize(cols, rows);
+};
+
+Terminal.prototype.resize = function (cols, rows) {
+  if (cols === this.cols && rows === this.rows) {
+    return;
+  }
+
+  var i, j;
+  for (i = 0; i < this.lines.length; i++) {
+    var line = this.lines[i];
+    if (line.length > cols) {
+      line.length = cols;
+    }
+    for (j = line.length; j < cols; j++) {
+      line.push(blankCell(null));
+    }
+  }
+
+  this.cols = cols;
+  while (this.lines.length > rows) {
+    this.lines.shift();
+    this.cursorY--;
+  }
+  while (this.lines.length < rows) {
+    this.lines.push(this.blankLine());
+  }
+
+  this.rows = rows;
+  this.scrollTop = 0;
+  this.scrollBottom = rows - 1;
+  this.cursorX = Math.min(Math.max(this.cursorX, 0), cols - 1);
+  this.cursorY = Math.min(Math.max(this.cursorY, 0), rows - 1);
+  this.wrapPending = false;
+
+  if (this.screen) {
+    this.screen.textContent = "";
+  }
+  this.dirty = [];
+  this.touchAll();
+
+  for (var k = 0; k < this.resizeHandlers.length; k++) {
+    this.resizeHandlers[k]({ cols: cols, rows: rows });
+  }
+};
+
+Terminal.prototype.keyDown = function (ev) {
+  var data = null;
+
+  if (CURSOR_KEYS.hasOwnProperty(ev.key)) {
+    data = (this.applicationCursor ? "\x1bO" : "\x1b[") + CURSOR_KEYS[ev.key];
+  } else if (KEYS.hasOwnProperty(ev.key)) {
+    data = KEYS[ev.key];
+    if (ev.key === "Tab" && ev.shiftKey) {
+      data = "\x1b[Z";
+    }
+  } else if (ev.key.length === 1) {
+    if (ev.metaKey) {
+      return;
+    }
+
+    if (ev.ctrlKey && !ev.altKey) {
+      var code = ev.key.toUpperCase().charCodeAt(0);
+      if (code >= 64 && code <= 95) {
+        data = String.fromCharCode(code & 0x1f);
+      } else if (ev.key === " ") {
+        data = "\x00";
+      }
+    } else {
+      data = ev.key;
+    }
+
+    if (data !== null && ev.altKey && !ev.ctrlKey) {
+      data = "\x1b" + data;
+    }
+  }
+
+  if (data !== null) {
+    ev.preventDefault();
+    this.send(data);
+  }
+};
+
+Terminal.prototype.write = function (bytes) {
+  var text = typeof bytes === "string" ? bytes : this.decoder.decode(bytes, { stream: true });
+  for (var k = 0; k < text.length; k++) {
+    this.feed(text[k]);
+  }
+};
+
+Terminal.prototype.feed = function (c) {
+  switch (this.state) {
+  case GROUND:
+    if (c === "\x1b") {
+      this.state = ESCAPE;
+    } else if (c < " " || c === "\x7f") {
+      this.control(c);
+    } else {
+      this.print(c);
+    }
+    return;
+
+  case ESCAPE:
+    this.escape(c);
+    return;
+
+  case CSI:
+    if (c >= "@" && c <= "~") {
+      this.state = GROUND;
+      this.csi(c);
+    } else if (c === "\x1b") {
+      this.state = ESCAPE;
+    } else if (c < " ") {
+      this.control(c);
+    } else {
+      this.params += c;
+    }
+    return;
+
+  case OSC:
+    // window titles and the like are ignored
+    if (c === "\x07") {
+      this.state = GROUND;
+    } else if (c === "\x1b") {
+      this.state = OSC_ESCAPE;
+    }
+    return;
+
+  case OSC_ESCAPE:
+    this.state = c === "\\" ? GROUND : OSC;
+    return;
+
+  case CHARSET:
+    this.lineDrawing = c === "0";
+    this.state = GROUND;
+    return;
+
+  case OTHER_CHARSET:
+    // only G0 is used, so the other sets are ignored
+    this.state = GROUND;
+    return;
+  }
+};
+
+Terminal.prototype.control = function (c) {
+  switch (c) {
+  case "\r":
+    this.touch(this.cursorY);
+    this.cursorX = 0;
+    this.wrapPending = false;
+    break;
+  case "\n":
+  case "\x0b":
+  case "\x0c":
+    this.lineFeed();
+    break;
+  case "\b":
+    if (this.cursorX > 0) {
+      this.cursorX--;
+    }
+    this.wrapPending = false;
+    break;
+  case "\t":
+    this.cursorX = Math.min(this.cols - 1, (Math.floor(this.cursorX / 8) + 1) * 8);
+    this.wrapPending = false;
+    break;
+  }
+  this.touch(this.cursorY);
+};
+
+Terminal.prototype.escape = function (c) {
+  this.state = GROUND;
+
+  switch (c) {
+  case "[":
+    this.state = CSI;
+    this.params = "";
+    break;
+  case "]":
+    this.state = OSC;
+    break;
+  case "(":
+    this.state = CHARSET;
+    break;
+  case ")":
+  case "*":
+  case "+":
+    this.state = OTHER_CHARSET;
+    break;
+  case "7":
+    this.saveCursor();
+    break;
+  case "8":
+    this.restoreCursor();
+    break;
+  case "D":
+    this.lineFeed();
+    break;
+  case "E":
+    this.cursorX = 0;
+    this.lineFeed();
+    break;
+  case "M":
+    this.reverseIndex();
+    break;
+  case "c":
+    this.reset();
+    break;
+  }
+};
+
+Terminal.prototype.print = function (c) {
+  if (this.lineDrawing && LINE_DRAWING.hasOwnProperty(c)) {
+    c = LINE_DRAWING[c];
+  }
+
+  if (this.wrapPending) {
+    this.cursorX = 0;
+    this.lineFeed();
+  }
+
+  var cell = this.lines[this.cursorY][this.cursorX];
+  cell.ch = c;
+  cell.fg = this.pen.fg;
+  cell.bg = this.pen.bg;
+  cell.attrs = this.pen.attrs;
+  this.touch(this.cursorY);
+
+  if (this.cursorX === this.cols - 1) {
+    this.wrapPending = true;
+  } else {
+    this.cursorX++;
+  }
+};
+
+Terminal.prototype.lineFeed = function () {
+  this.wrapPending = false;
+  this.touch(this.cursorY);
+  if (this.cursorY === this.scrollBottom) {
+    this.scrollUp(1);
+  } else if (this.cursorY < this.rows - 1) {
+    this.cursorY++;
+  }
+};
+
+Terminal.prototype.reverseIndex = function () {
+  this.wrapPending = false;
+  this.touch(this.cursorY);
+  if (this.cursorY === this.scrollTop) {
+    this.scrollDown(1);
+  } else if (this.cursorY > 0) {
+    this.cursorY--;
+  }
+};
+
+Terminal.prototype.scrollUp = function (n) {
+  for (var k = 0; k < n; k++) {
+    this.lines.splice(this.scrollTop, 1);
+    this.lines.splice(this.scrollBottom, 0, this.blankLine());
+  }
+  for (var i = this.scrollTop; i <= this.scrollBottom; i++) {
+    this.touch(i);
+  }
+};
+
+Terminal.prototype.scrollDown = function (n) {
+  for (var k = 0; k < n; k++) {
+    this.lines.splice(this.scrollBottom, 1);
+    this.lines.splice(this.scrollTop, 0, this.blankLine());
+  }
+  for (var i = this.scrollTop; i <= this.scrollBottom; i++) {
+    this.touch(i);
+  }
+};
+
+Terminal.prototype.saveCursor = function () {
+  this.saved = {
+    x: this.cursorX,
+    y: this.cursorY,
+    pen: { fg: this.pen.fg, bg: this.pen.bg, attrs: this.pen.attrs },
+    lineDrawing: this.lineDrawing
+  };
+};
+
+Terminal.prototype.restoreCursor = function () {
+  if (this.saved) {
+    this.touch(this.cursorY);
+    this.cursorX = Math.min(this.saved.x, this.cols - 1);
+    this.cursorY = Math.min(this.saved.y, this.rows - 1);
+    this.pen = { fg: this.saved.pen.fg, bg: this.saved.pen.bg, attrs: this.saved.pen.attrs };
+    this.lineDrawing = this.saved.lineDrawing;
+    this.wrapPending = false;
+    this.touch(this.cursorY);
+  }
+};
+
+Terminal.prototype.eraseCells = function (i, from, to) {
+  var line = this.lines[i];
+  for (var j = Math.max(from, 0); j < Math.min(to, this.cols); j++) {
+    line[j] = blankCell(this.pen);
+  }
+  this.touch(i);
+};
+
+Terminal.prototype.moveTo = function (y, x) {
+  this.touch(this.cursorY);
+  this.cursorY = Math.min(Math.max(y, 0), this.rows - 1);
+  this.cursorX = Math.min(Math.max(x, 0), this.cols - 1);
+  this.wrapPending = false;
+  this.touch(this.cursorY);
+};
+
+Terminal.prototype.csi = function (final) {
+  var priv = "";
+  var params = this.params;
+  if (params.length > 0 && "?>=<".indexOf(params[0]) >= 0) {
+    priv = params[0];
+    params = params.slice(1);
+  }
+
+  var args = params === "" ? [] : params.split(";").map(function (p) { return parseInt(p, 10) || 0; });
+  var n = args.length > 0 && args[0] > 0 ? args[0] : 1;
+  var i;
+
+  if (priv === "?") {
+    if (final === "h" || final === "l") {
+      this.setMode(args, final === "h");
+    }
+    return;
+  } else if (priv !== "") {
+    return;
+  }
+
+  switch (final) {
+  case "A":
+    this.moveTo(this.cursorY - n, this.cursorX);
+    break;
+  case "B":
+  case "e":
+    this.moveTo(this.cursorY + n, this.cursorX);
+    break;
+  case "C":
+  case "a":
+    this.moveTo(this.cursorY, this.cursorX + n);
+    break;
+  case "D":
+    this.moveTo(this.cursorY, this.cursorX - n);
+    break;
+  case "E":
+    this.moveTo(this.cursorY + n, 0);
+    break;
+  case "F":
+    this.moveTo(this.cursorY - n, 0);
+    break;
+  case "G":
+  case "`":
+    this.moveTo(this.cursorY, n - 1);
+    break;
+  case "d":
+    this.moveTo(n - 1, this.cursorX);
+    break;
+  case "H":
+  case "f":
+    this.moveTo((args[0] || 1) - 1, (args[1] || 1) - 1);
+    break;
+  case "J":
+    switch (args[0] || 0) {
+    case 0:
+      this.eraseCells(this.cursorY, this.cursorX, this.cols);
+      for (i = this.cursorY + 1; i < this.rows; i++) {
+        this.eraseCells(i, 0, this.cols);
+      }
+      break;
+    case 1:
+      this.eraseCells(this.cursorY, 0, this.cursorX + 1);
+      for (i = 0; i < this.cursorY; i++) {
+        this.eraseCells(i, 0, this.cols);
+      }
+      break;
+    default:
+      for (i = 0; i < this.rows; i++) {
+        this.eraseCells(i, 0, this.cols);
+      }
+    }
+    break;
+  case "K":
+    switch (args[0] || 0) {
+    case 0:
+      this.eraseCells(this.cursorY, this.cursorX, this.cols);
+      break;
+    case 1:
+      this.eraseCells(this.cursorY, 0, this.cursorX + 1);
+      break;
+    default:
+      this.eraseCells(this.cursorY, 0, this.cols);
+    }
+    break;
+  case "X":
+    this.eraseCells(this.cursorY, this.cursorX, this.cursorX + n);
+    break;
+  case "@":
+    var line = this.lines[this.cursorY];
+    for (i = 0; i < n; i++) {
+      line.splice(this.cursorX, 0, blankCell(this.pen));
+    }
+    line.length = this.cols;
+    this.touch(this.cursorY);
+    break;
+  case "P":
+    this.lines[this.cursorY].splice(this.cursorX, n);
+    while (this.lines[this.cursorY].length < this.cols) {
+      this.lines[this.cursorY].push(blankCell(this.pen));
+    }
+    this.touch(this.cursorY);
+    break;
+  case "L":
+  case "M":
+    if (this.cursorY >= this.scrollTop && this.cursorY <= this.scrollBottom) {
+      var top = this.scrollTop;
+      this.scrollTop = this.cursorY;
+      if (final === "L") {
+        this.scrollDown(n);
+      } else {
+        this.scrollUp(n);
+      }
+      this.scrollTop = top;
+    }
+    break;
+  case "S":
+    this.scrollUp(n);
+    break;
+  case "T":
+    this.scrollDown(n);
+    break;
+  case "r":
+    var t = (args[0] || 1) - 1;
+    var b = (args[1] || this.rows) - 1;
+    if (t < b && b < this.rows) {
+      this.scrollTop = t;
+      this.scrollBottom = b;
+      this.moveTo(0, 0);
+    }
+    break;
+  case "s":
+    this.saveCursor();
+    break;
+  case "u":
+    this.restoreCursor();
+    break;
+  case "m":
+    this.sgr(args);
+    break;
+  case "n":
+    if (args[0] === 6) {
+      this.send("\x1b[" + (this.cursorY + 1) + ";" + (this.cursorX + 1) + "R");
+    }
+    break;
+  }
+};
+
+Terminal.prototype.setMode = function (args, on) {
+  for (var k = 0; k < args.length; k++) {
+    switch (args[k]) {
+    case 1:
+      this.applicationCursor = on;
+      break;
+    case 25:
+      this.cursorVisible = on;
+      this.touch(this.cursorY);
+      break;
+    case 47:
+    case 1047:
+    case 1049:
+      // only one screen is kept; switching clears it
+      if (args[k] === 1049 && on) {
+        this.saveCursor();
+      }
+      for (var i = 0; i < this.rows; i++) {
+        this.eraseCells(i, 0, this.cols);
+      }
+      if (args[k] === 1049 && !on) {
+        this.restoreCursor();
+      }
+      break;
+    }
+  }
+};
+
+// Reads an extended color (38;5;n or 38;2;r;g;b) starting at args[k]
+function extendedColor(args, k) {
+  if (args[k + 1] === 5 && k + 2 < args.length) {
+    return { color: color256(args[k + 2] & 0xff), next: k + 2 };
+  }
+
+  if (args[k + 1] === 2 && k + 4 < args.length) {
+    return { color: rgb(args[k + 2] & 0xff, args[k + 3] & 0xff, args[k + 4] & 0xff), next: k + 4 };
+  }
+
+  return { color: null, next: args.length };
+}
+
+Terminal.prototype.sgr = function (args) {
+  if (args.length === 0) {
+    args = [0];
+  }
+
+  var pen = this.pen;
+  for (var k = 0; k < args.length; k++) {
+    var a = args[k];
+    var ext;
+
+    if (a === 0) {
+      pen.fg = null;
+      pen.bg = null;
+      pen.attrs = 0;
+    } else if (a === 1) {
+      pen.attrs |= ATTR_BOLD;
+    } else if (a === 2) {
+      pen.attrs |= ATTR_DIM;
+    } else if (a === 3) {
+      pen.attrs |= ATTR_ITALIC;
+    } else if (a === 4) {
+      pen.attrs |= ATTR_UNDERLINE;
+    } else if (a === 5) {
+      pen.attrs |= ATTR_BLINK;
+    } else if (a === 7) {
+      pen.attrs |= ATTR_REVERSE;
+    } else if (a === 8) {
+      pen.attrs |= ATTR_HIDDEN;
+    } else if (a === 9) {
+      pen.attrs |= ATTR_STRIKE;
+    } else if (a === 22) {
+      pen.attrs &= ~(ATTR_BOLD | ATTR_DIM);
+    } else if (a === 23) {
+      pen.attrs &= ~ATTR_ITALIC;
+    } else if (a === 24) {
+      pen.attrs &= ~ATTR_UNDERLINE;
+    } else if (a === 25) {
+      pen.attrs &= ~ATTR_BLINK;
+    } else if (a === 27) {
+      pen.attrs &= ~ATTR_REVERSE;
+    } else if (a === 28) {
+      pen.attrs &= ~ATTR_HIDDEN;
+    } else if (a === 29) {
+      pen.attrs &= ~ATTR_STRIKE;
+    } else if (a >= 30 && a <= 37) {
+      pen.fg = ANSI_COLORS[a - 30];
+    } else if (a === 38) {
+      ext = extendedColor(args, k);
+      pen.fg = ext.color;
+      k = ext.next;
+    } else if (a === 39) {
+      pen.fg = null;
+    } else if (a >= 40 && a <= 47) {
+      pen.bg = ANSI_COLORS[a - 40];
+    } else if (a === 48) {
+      ext = extendedColor(args, k);
+      pen.bg = ext.color;
+      k = ext.next;
+    } else if (a === 49) {
+      pen.bg = null;
+    } else if (a >= 90 && a <= 97) {
+      pen.fg = ANSI_COLORS[a - 90 + 8];
+    } else if (a >= 100 && a <= 107) {
+      pen.bg = ANSI_COLORS[a - 100 + 8];
+    }
+  }
+};
+
+Terminal.prototype.scheduleRender = function () {
+  if (this.renderPending || !this.screen) {
+    return;
+  }
+
+  var self = this;
+  this.renderPending = true;
+  window.requestAnimationFrame(function () {
+    self.renderPending = false;
+    self.render();
+  });
+};
+
+function escapeHTML(s) {
+  return s.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+}
+
+function cellStyle(cell, cursor) {
+  var fg = cell.fg, bg = cell.bg;
+  var reverse = (cell.attrs & ATTR_REVERSE) !== 0;
+  if (reverse !== cursor) {
+    var t = fg;
+    fg = bg || "var(--term-bg)";
+    bg = t || "var(--term-fg)";
+  }
+
+  var style = "";
+  if (fg) {
+    style += "color:" + fg + ";";
+  }
+  if (bg) {
+    style += "background:" + bg + ";";
+  }
+  if (cell.attrs & ATTR_BOLD) {
+    style += "font-weight:bold;";
+  }
+  if (cell.attrs & ATTR_DIM) {
+    style += "opacity:0.6;";
+  }
+  if (cell.attrs & ATTR_ITALIC) {
+    style += "font-style:italic;";
+  }
+  if (cell.attrs & (ATTR_UNDERLINE | ATTR_STRIKE)) {
+    style += "text-decoration:" +
+      (cell.attrs & ATTR_UNDERLINE ? " underline" : "") +
+      (cell.attrs & ATTR_STRIKE ? " line-through" : "") + ";";
+  }
+  if (cell.attrs & ATTR_HIDDEN) {
+    style += "visibility:hidden;";
+  }
+  return style;
+}
+
+Terminal.prototype.render = function () {
+  if (!this.screen) {
+    return;
+  }
+
+  while (this.screen.childNodes.length < this.rows) {
+    this.screen.appendChild(document.createElement("div"));
+  }
+
+  for (var i = 0; i < this.rows; i++) {
+    if (!this.dirty[i]) {
+      continue;
+    }
+    this.dirty[i] = false;
+
+    var html = "";
+    var run = "";
+    var runStyle = null;
+    var line = this.lines[i];
+
+    for (var j = 0; j < this.cols; j++) {
+      var cursor = this.cursorVisible && i === this.cursorY && j === this.cursorX;
+      var style = cellStyle(line[j], cursor);
+      if (style !== runStyle) {
+        if (run !== "") {
+          html += "<span style=\"" + runStyle + "\">" + escapeHTML(run) + "</span>";
+        }
+        run = "";
+        runStyle = style;
+      }
+      run += line[j].ch;
+    }
+
+    if (run !== "") {
+      html += "<span style=\"" + runStyle + "\">" + escapeHTML(run) + "</span>";
+    }
+
+    this.screen.childNodes[i].innerHTML = html;
+  }
+};
+
+global.Terminal = Terminal;
+})(window);
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebClientHandler(t *testing.T) {
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/", "text/html"},
+		{"/term.js", "javascript"},
+		{"/term.css", "text/css"},
+	}
+
+	srv := httptest.NewServer(webClientHandler())
+	defer srv.Close()
+
+	for _, tc := range tests {
+		resp, err := http.Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.path, err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), tc.contentType) {
+			t.Errorf("%s: expected %s, found status %d and type %q", tc.path, tc.contentType, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		// everything the client needs is served by the game
+		if strings.Contains(string(body), "http://") || strings.Contains(string(body), "https://") {
+			t.Errorf("%s: expected no outside links", tc.path)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/missing.js")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a missing file to be not found, found status %d", resp.StatusCode)
+	}
+}
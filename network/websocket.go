@@ -0,0 +1,304 @@
+package network
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// WebSocket opcodes (RFC 6455)
+const (
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+)
+
+const (
+	wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// Largest message accepted from a client
+	MaxWebSocketMessage = 64 * 1024
+)
+
+var (
+	ErrNotWebSocket      = errors.New("not a websocket request")
+	ErrBadOrigin         = errors.New("websocket origin does not match host")
+	ErrUnmaskedFrame     = errors.New("client sent an unmasked websocket frame")
+	ErrWebSocketTooLarge = errors.New("websocket message too large")
+)
+
+// Server side of a WebSocket connection.  Binary messages carry terminal
+// data; text messages are passed to the OnText callback.
+type WebSocketConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	wmu    sync.Mutex
+	closed bool
+
+	// unread data from the last binary message
+	pending []byte
+
+	// Called with each text message.  Set before reading.
+	OnText func(msg []byte)
+}
+
+// Completes the WebSocket handshake for an HTTP request
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocketConn, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrNotWebSocket
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+
+	// browsers always send an origin; refuse pages from other sites
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			http.Error(w, "bad origin", http.StatusForbidden)
+			return nil, ErrBadOrigin
+		}
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, ErrNotWebSocket
+	}
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", accept)
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &WebSocketConn{conn: conn, br: brw.Reader}, nil
+}
+
+func headerHasToken(h http.Header, name string, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, tok := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(tok), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Reads terminal data from binary messages
+func (ws *WebSocketConn) Read(p []byte) (int, error) {
+	for len(ws.pending) == 0 {
+		op, msg, err := ws.readMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case wsOpBinary:
+			ws.pending = msg
+		case wsOpText:
+			if ws.OnText != nil {
+				ws.OnText(msg)
+			}
+		}
+	}
+
+	n := copy(p, ws.pending)
+	ws.pending = ws.pending[n:]
+
+	return n, nil
+}
+
+// Reads the next data message, answering pings and close requests
+func (ws *WebSocketConn) readMessage() (byte, []byte, error) {
+	var (
+		msgOp byte
+		msg   []byte
+	)
+
+	for {
+		fin, op, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := ws.writeFrame(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+
+		case wsOpPong:
+			continue
+
+		case wsOpClose:
+			ws.writeFrame(wsOpClose, payload)
+			ws.conn.Close()
+			return 0, nil, io.EOF
+
+		case wsOpContinuation:
+			if msgOp == 0 {
+				return 0, nil, errors.New("unexpected websocket continuation frame")
+			}
+
+		default:
+			msgOp = op
+			msg = msg[:0]
+		}
+
+		if len(msg)+len(payload) > MaxWebSocketMessage {
+			return 0, nil, ErrWebSocketTooLarge
+		}
+
+		msg = append(msg, payload...)
+		if fin {
+			return msgOp, msg, nil
+		}
+	}
+}
+
+func (ws *WebSocketConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(ws.br, hdr[:]); err != nil {
+		return
+	}
+
+	fin = hdr[0]&0x80 != 0
+	op = hdr[0] & 0x0F
+
+	if hdr[1]&0x80 == 0 {
+		err = ErrUnmaskedFrame
+		return
+	}
+
+	length := uint64(hdr[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > MaxWebSocketMessage {
+		err = ErrWebSocketTooLarge
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+		return
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return
+}
+
+func (ws *WebSocketConn) writeFrame(op byte, payload []byte) error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+
+	if ws.closed {
+		return net.ErrClosed
+	}
+
+	hdr := make([]byte, 2, 10)
+	hdr[0] = 0x80 | op
+
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xFFFF:
+		hdr[1] = 126
+		hdr = hdr[:4]
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = hdr[:10]
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+
+	if _, err := ws.conn.Write(hdr); err != nil {
+		return err
+	}
+
+	_, err := ws.conn.Write(payload)
+	return err
+}
+
+// Writes terminal data as a binary message
+func (ws *WebSocketConn) Write(p []byte) (int, error) {
+	if err := ws.writeFrame(wsOpBinary, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Writes a text message
+func (ws *WebSocketConn) WriteText(msg string) error {
+	return ws.writeFrame(wsOpText, []byte(msg))
+}
+
+func (ws *WebSocketConn) Close() error {
+	ws.writeFrame(wsOpClose, []byte{0x03, 0xE8}) // normal closure
+
+	ws.wmu.Lock()
+	ws.closed = true
+	ws.wmu.Unlock()
+
+	return ws.conn.Close()
+}
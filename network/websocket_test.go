@@ -0,0 +1,187 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// Client frame, masked unless unmasked is set
+func wsFrame(fin bool, op byte, payload []byte, unmasked bool) []byte {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+
+	var maskBit byte = 0x80
+	if unmasked {
+		maskBit = 0
+	}
+
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+
+	if unmasked {
+		return append(frame, payload...)
+	}
+
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+func newTestWebSocket(frames ...[]byte) (*WebSocketConn, *fakeConn) {
+	conn := newFakeConn(bytes.Join(frames, nil))
+	return &WebSocketConn{conn: conn, br: bufio.NewReader(conn)}, conn
+}
+
+func TestWebSocketRead(t *testing.T) {
+	half := make([]byte, MaxWebSocketMessage/2+1)
+
+	// length header claiming more than the limit, with no payload
+	oversized := []byte{0x82, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+	binary.BigEndian.PutUint64(oversized[2:10], MaxWebSocketMessage+1)
+
+	tests := []struct {
+		name   string
+		frames [][]byte
+		data   string
+
+		// set if the read fails, and to the error expected if it matters
+		fails bool
+		err   error
+	}{
+		{"binary", [][]byte{wsFrame(true, wsOpBinary, []byte("hello"), false)}, "hello", false, nil},
+		{"extended length", [][]byte{wsFrame(true, wsOpBinary, bytes.Repeat([]byte("x"), 300), false)},
+			string(bytes.Repeat([]byte("x"), 300)), false, nil},
+		{"unmasked", [][]byte{wsFrame(true, wsOpBinary, []byte("hello"), true)}, "", true, ErrUnmaskedFrame},
+		{"oversized frame", [][]byte{oversized}, "", true, ErrWebSocketTooLarge},
+		{"oversized message", [][]byte{
+			wsFrame(false, wsOpBinary, half, false),
+			wsFrame(true, wsOpContinuation, half, false),
+		}, "", true, ErrWebSocketTooLarge},
+		{"continuation", [][]byte{
+			wsFrame(false, wsOpBinary, []byte("hel"), false),
+			wsFrame(false, wsOpContinuation, []byte("l"), false),
+			wsFrame(true, wsOpContinuation, []byte("o"), false),
+		}, "hello", false, nil},
+		{"ping between fragments", [][]byte{
+			wsFrame(false, wsOpBinary, []byte("hel"), false),
+			wsFrame(true, wsOpPing, []byte("?"), false),
+			wsFrame(true, wsOpContinuation, []byte("lo"), false),
+		}, "hello", false, nil},
+		{"stray continuation", [][]byte{wsFrame(true, wsOpContinuation, []byte("x"), false)}, "", true, nil},
+		{"close", [][]byte{wsFrame(true, wsOpClose, []byte{0x03, 0xE8}, false)}, "", true, io.EOF},
+	}
+
+	for _, tc := range tests {
+		ws, _ := newTestWebSocket(tc.frames...)
+
+		buf := make([]byte, MaxWebSocketMessage)
+		n, err := ws.Read(buf)
+
+		switch {
+		case !tc.fails && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.fails && err == nil:
+			t.Errorf("%s: expected the read to fail", tc.name)
+		case tc.err != nil && !errors.Is(err, tc.err):
+			t.Errorf("%s: expected error %v but found %v", tc.name, tc.err, err)
+		case string(buf[:n]) != tc.data:
+			t.Errorf("%s: expected %q but found %q", tc.name, tc.data, buf[:n])
+		}
+	}
+}
+
+func TestWebSocketControl(t *testing.T) {
+	var texts []string
+	ws, conn := newTestWebSocket(
+		wsFrame(true, wsOpPing, []byte("hi"), false),
+		wsFrame(true, wsOpText, []byte(`{"type":"resize"}`), false),
+		wsFrame(true, wsOpPong, nil, false),
+		wsFrame(true, wsOpBinary, []byte("data"), false),
+		wsFrame(true, wsOpClose, []byte{0x03, 0xE8}, false),
+	)
+	ws.OnText = func(msg []byte) { texts = append(texts, string(msg)) }
+
+	buf := make([]byte, 2)
+	var data []byte
+	for {
+		n, err := ws.Read(buf)
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	if string(data) != "data" {
+		t.Errorf("expected \"data\" but found %q", data)
+	}
+
+	if len(texts) != 1 || texts[0] != `{"type":"resize"}` {
+		t.Errorf("expected the text message to be passed on, found %q", texts)
+	}
+
+	// a pong echoing the ping, then the close echoed back
+	expected := []byte{0x80 | wsOpPong, 2, 'h', 'i', 0x80 | wsOpClose, 2, 0x03, 0xE8}
+	if !bytes.Equal(conn.out.Bytes(), expected) {
+		t.Errorf("expected replies %v but found %v", expected, conn.out.Bytes())
+	}
+}
+
+func TestWebSocketWrite(t *testing.T) {
+	tests := []struct {
+		n   int
+		hdr []byte
+	}{
+		{5, []byte{0x82, 5}},
+		{300, []byte{0x82, 126, 0x01, 0x2C}},
+		{70000, []byte{0x82, 127, 0, 0, 0, 0, 0, 0x01, 0x11, 0x70}},
+	}
+
+	for _, tc := range tests {
+		ws, conn := newTestWebSocket()
+		payload := bytes.Repeat([]byte("z"), tc.n)
+
+		if n, err := ws.Write(payload); err != nil || n != tc.n {
+			t.Errorf("%d bytes: expected %d written, found %d (error %v)", tc.n, tc.n, n, err)
+		}
+
+		expected := append(append([]byte{}, tc.hdr...), payload...)
+		if !bytes.Equal(conn.out.Bytes(), expected) {
+			t.Errorf("%d bytes: expected header %v but found %v", tc.n, tc.hdr, conn.out.Bytes()[:len(tc.hdr)])
+		}
+	}
+
+	ws, conn := newTestWebSocket()
+	if err := ws.WriteText("hi"); err != nil {
+		t.Fatalf("error writing text: %v", err)
+	}
+
+	if !bytes.Equal(conn.out.Bytes(), []byte{0x81, 2, 'h', 'i'}) {
+		t.Errorf("unexpected text frame %v", conn.out.Bytes())
+	}
+
+	ws.Close()
+	if _, err := ws.Write([]byte("late")); err == nil {
+		t.Errorf("expected an error writing to a closed websocket")
+	}
+}
@@ -0,0 +1,174 @@
+package mpnethack
+
+const (
+	// Pack members within this distance hear when another member is
+	// attacked or killed
+	PackAlertRadius = 8
+
+	// Distance between followers in a pack's formation
+	PackFormationSpacing = 2
+)
+
+// Puts the mob at index ind into a pack.  Pack zero means no pack.  Each pack
+// should have one leader; if the leader dies, another member takes over.
+func (l *Level) JoinPack(ind int, pack int, leader bool) {
+	if ind < 0 || ind >= len(l.Mobs) {
+		return
+	}
+
+	l.Mobs[ind].Pack = pack
+	l.Mobs[ind].PackLeader = leader && pack != 0
+}
+
+// Living members of the mob's pack, including the mob
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) packMembers(mob *Mob) []*Mob {
+	if mob.Pack == 0 {
+		return nil
+	}
+
+	members := []*Mob{}
+	for i := range g.Mobs {
+		m := &g.Mobs[i]
+		if m.Pack == mob.Pack && m.IsAlive() {
+			members = append(members, m)
+		}
+	}
+
+	return members
+}
+
+// Leader of the mob's pack.  If the pack's leader is dead, the first living
+// member becomes the leader.
+//
+// Assumes the write lock is held
+func (g *Game) packLeader(mob *Mob) *Mob {
+	members := g.packMembers(mob)
+	if len(members) == 0 {
+		return nil
+	}
+
+	for _, m := range members {
+		if m.PackLeader {
+			return m
+		}
+	}
+
+	members[0].PackLeader = true
+	return members[0]
+}
+
+// Tells the other members of the mob's pack near the mob about an event,
+// unless they are already dealing with their own events
+//
+// Assumes the write lock is held
+func (g *Game) alertPack(mob *Mob, ev MobEvent, cause Unit) {
+	if mob.Pack == 0 || cause == nil {
+		return
+	}
+
+	for i := range g.Mobs {
+		m := &g.Mobs[i]
+		if m == mob || m.Pack != mob.Pack || !m.IsAlive() {
+			continue
+		}
+
+		di := m.I - mob.I
+		dj := m.J - mob.J
+		if di*di+dj*dj > PackAlertRadius*PackAlertRadius {
+			continue
+		}
+
+		m.Threat.Add(cause, ThreatPerAttack)
+
+		if m.Event == MobEventNone {
+			m.Event = ev
+			m.EventCause = cause
+		}
+	}
+}
+
+// Keeps a follower in formation behind its pack leader: followers line up
+// in rows of two behind the leader
+//
+// Assumes the write lock is held
+func (g *Game) mobFollow(mob *Mob, moveRate int16) bool {
+	leader := g.packLeader(mob)
+	if leader == nil || leader == mob {
+		return false
+	}
+
+	slot := 0
+	for _, m := range g.packMembers(mob) {
+		if m == mob {
+			break
+		}
+
+		if m != leader {
+			slot++
+		}
+	}
+
+	facing := leader.Direc
+	if facing == NoDirection {
+		facing = Up
+	}
+
+	ui, uj, vi, vj := facing.Vectors()
+	row := 1 + slot/2
+	side := 1
+	if slot%2 == 1 {
+		side = -1
+	}
+
+	destI := leader.I - ui*row*PackFormationSpacing + side*vi*PackFormationSpacing/2
+	destJ := leader.J - uj*row*PackFormationSpacing + side*vj*PackFormationSpacing/2
+
+	if mob.MoveTick--; mob.MoveTick <= 0 {
+		mob.MoveTick = moveRate
+		if destI != mob.I || destJ != mob.J {
+			g.mobMoveRelative(mob, destI, destJ, MoveCloser)
+		}
+	}
+
+	return true
+}
+
+// Square next to the target where the mob should attack from.  Pack members
+// attacking the same target spread out around it; other mobs go straight
+// for the target.
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) flankPosition(mob *Mob, ti, tj int) (int, int) {
+	if mob.Pack == 0 {
+		return ti, tj
+	}
+
+	slot := 0
+	for _, m := range g.packMembers(mob) {
+		if m == mob {
+			break
+		}
+
+		if m.Target == mob.Target && m.State == MobAttack {
+			slot++
+		}
+	}
+
+	if slot == 0 {
+		return ti, tj
+	}
+
+	// opposite sides first, then the remaining sides
+	flanks := [...][2]int{{0, 1}, {0, -1}, {-1, 0}, {1, 0}}
+	off := flanks[(slot-1)%len(flanks)]
+
+	i := ti + off[0]
+	j := tj + off[1]
+	if _, hasColl := g.hasCollision(i, j); hasColl {
+		return ti, tj
+	}
+
+	return i, j
+}
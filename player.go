@@ -1,5 +1,10 @@
 package mpnethack
 
+import (
+	"log"
+	"time"
+)
+
 type Cooldowns []uint32
 
 var zeroCooldowns = [MaxActionType]uint32{}
@@ -49,7 +54,8 @@ type Player struct {
 
 	Cooldowns []uint64
 
-	Stats UnitStats
+	Stats   UnitStats
+	Effects StatusEffects
 
 	BusyTick   int16
 	HealthTick int16
@@ -58,6 +64,33 @@ type Player struct {
 	SwingTick   int16
 	SwingState  int16
 	SwingFacing Direction
+
+	// Death and respawn state
+	Dead        bool
+	Ghost       bool
+	Killer      string
+	RespawnTick int16
+
+	// A linkdead player's connection dropped.  The character stays in the
+	// game for LinkdeadTick more ticks, in case the user reconnects.
+	Linkdead     bool
+	LinkdeadTick int16
+
+	// Game log and session log sequence numbers when the connection
+	// dropped
+	linkdeadSeq    uint
+	linkdeadLogSeq uint
+
+	// Experience changed since the player's record was last saved
+	recordDirty bool
+
+	// Player-versus-player state
+	DuelWith      *Player
+	DuelChallenge *Player
+
+	MobKills    int
+	PlayerKills int
+	Deaths      int
 }
 
 var _ Unit = &Player{}
@@ -66,6 +99,10 @@ func (p *Player) GetStats() *UnitStats {
 	return &p.Stats
 }
 
+func (p *Player) GetStatusEffects() *StatusEffects {
+	return &p.Effects
+}
+
 func (p *Player) IsAlive() bool {
 	return p.Stats.HP > 0
 }
@@ -73,6 +110,13 @@ func (p *Player) IsAlive() bool {
 func (p *Player) TakeDamage(dmg int, u Unit) {
 	hp := p.Stats.HP - dmg
 	if hp <= 0 {
+		if p.Stats.HP > 0 {
+			p.Killer = "misadventure"
+			if u != nil {
+				p.Killer = u.Name()
+			}
+		}
+
 		hp = 0
 		p.BusyTick = 0
 		p.HealthTick = 0
@@ -87,6 +131,35 @@ func (p *Player) TakeDamage(dmg int, u Unit) {
 	}
 }
 
+// Persistent information about a player
+type PlayerRecord struct {
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+	XP    int    `json:"xp"`
+}
+
+func (p *Player) Record() *PlayerRecord {
+	return &PlayerRecord{
+		Name:  p.Name(),
+		Level: p.Stats.Level,
+		XP:    p.Stats.XP,
+	}
+}
+
+var LookupPlayerRecord func(name string) (*PlayerRecord, error)
+var SavePlayerRecord func(rec *PlayerRecord) error
+
+// Session whose players aren't loaded from or saved to the player records,
+// such as a bot
+type Recordless interface {
+	Recordless() bool
+}
+
+func hasRecord(sess Session) bool {
+	rl, ok := sess.(Recordless)
+	return !ok || !rl.Recordless()
+}
+
 func (p *Player) Name() string {
 	return p.S.UserName()
 }
@@ -102,3 +175,29 @@ func (p *Player) GetPos() (i int, j int, h int, w int) {
 	w = 1
 	return
 }
+
+// Time remaining until a dead player respawns
+func (p *Player) RespawnIn() time.Duration {
+	if !p.Dead || p.RespawnTick <= 0 {
+		return 0
+	}
+
+	return time.Duration(p.RespawnTick) * GameRefreshInterval
+}
+
+// Saves the player's record.  Experience changes only mark the record
+// dirty; it is saved on level-up, when the player leaves, and at shutdown.
+//
+// Assumes the write lock is held
+func (g *Game) savePlayer(pl *Player) {
+	if SavePlayerRecord == nil || g.offline || !hasRecord(pl.S) {
+		return
+	}
+
+	pl.recordDirty = false
+
+	rec := pl.Record()
+	if err := SavePlayerRecord(rec); err != nil {
+		log.Printf("error saving player record for \"%s\": %v", rec.Name, err)
+	}
+}
@@ -0,0 +1,142 @@
+package mpnethack
+
+import (
+	"fmt"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+// Stats granted at an experience level
+type ExperienceLevel struct {
+	// Total experience required to reach the level
+	XP int
+
+	MaxHP              int
+	THAC0              int
+	HealthRecoveryRate int16
+}
+
+// Experience levels, starting at level 1
+var ExperienceLevels = []ExperienceLevel{
+	{XP: 0, MaxHP: 16, THAC0: 0, HealthRecoveryRate: 50},
+	{XP: 20, MaxHP: 20, THAC0: 1, HealthRecoveryRate: 46},
+	{XP: 50, MaxHP: 24, THAC0: 2, HealthRecoveryRate: 42},
+	{XP: 100, MaxHP: 28, THAC0: 3, HealthRecoveryRate: 38},
+	{XP: 200, MaxHP: 32, THAC0: 4, HealthRecoveryRate: 34},
+	{XP: 400, MaxHP: 37, THAC0: 5, HealthRecoveryRate: 30},
+	{XP: 800, MaxHP: 42, THAC0: 6, HealthRecoveryRate: 27},
+	{XP: 1600, MaxHP: 48, THAC0: 7, HealthRecoveryRate: 24},
+	{XP: 3200, MaxHP: 54, THAC0: 8, HealthRecoveryRate: 21},
+	{XP: 6400, MaxHP: 60, THAC0: 9, HealthRecoveryRate: 18},
+}
+
+func LevelForXP(xp int) int {
+	level := 1
+	for i, lvl := range ExperienceLevels {
+		if xp < lvl.XP {
+			break
+		}
+
+		level = i + 1
+	}
+
+	return level
+}
+
+// Returns the total experience needed for the level after the given level.
+// Returns -1 if level is the maximum level.
+func NextLevelXP(level int) int {
+	if level < 1 {
+		return 0
+	}
+
+	if level >= len(ExperienceLevels) {
+		return -1
+	}
+
+	return ExperienceLevels[level].XP
+}
+
+// Sets the level-dependent stats.  If the level increases MaxHP, then HP is
+// increased by the same amount.
+func (s *UnitStats) ApplyLevel(level int) {
+	if level < 1 {
+		level = 1
+	}
+
+	if level > len(ExperienceLevels) {
+		level = len(ExperienceLevels)
+	}
+
+	lvl := &ExperienceLevels[level-1]
+
+	if delta := lvl.MaxHP - s.MaxHP; delta > 0 {
+		s.HP += delta
+	}
+
+	s.Level = level
+	s.MaxHP = lvl.MaxHP
+	s.THAC0 = lvl.THAC0
+	s.HealthRecoveryRate = lvl.HealthRecoveryRate
+
+	if s.HP > s.MaxHP {
+		s.HP = s.MaxHP
+	}
+}
+
+// Experience awarded for killing a unit
+func ExperienceFor(u Unit) int {
+	switch u := u.(type) {
+	case *Mob:
+		info, err := LookupMobInfo(u.Type)
+		if err != nil {
+			return 0
+		}
+		return info.XPReward
+	default:
+		return 0
+	}
+}
+
+// Assumes the write lock is held
+func (g *Game) awardExperience(pl *Player, xp int) {
+	if xp <= 0 {
+		return
+	}
+
+	stats := &pl.Stats
+	stats.XP += xp
+	g.messagef(chat.Game, "%s gains %d experience.", pl.Name(), xp)
+
+	pl.recordDirty = true
+	if level := LevelForXP(stats.XP); level > stats.Level {
+		stats.ApplyLevel(level)
+		g.messagef(chat.Game, "%s has reached level %d!", pl.Name(), level)
+		g.savePlayer(pl)
+	}
+}
+
+// Assumes the write lock is held
+func (g *Game) loseExperience(pl *Player, percent int) {
+	stats := &pl.Stats
+	if percent <= 0 || stats.XP <= 0 {
+		return
+	}
+
+	// Experience loss never drops the player below their current level
+	floor := 0
+	if stats.Level >= 1 && stats.Level <= len(ExperienceLevels) {
+		floor = ExperienceLevels[stats.Level-1].XP
+	}
+
+	loss := (stats.XP - floor) * percent / 100
+	if loss <= 0 {
+		return
+	}
+
+	stats.XP -= loss
+	pl.S.Message(chat.Game, fmt.Sprintf("You lost %d experience.", loss))
+
+	// saved when the player levels up or leaves
+	pl.recordDirty = true
+}
@@ -0,0 +1,113 @@
+package mpnethack
+
+import (
+	"testing"
+)
+
+func TestLevelForXP(t *testing.T) {
+	tests := []struct {
+		xp    int
+		level int
+	}{
+		{-5, 1},
+		{0, 1},
+		{19, 1},
+		{20, 2},
+		{49, 2},
+		{50, 3},
+		{6399, 9},
+		{6400, 10},
+		{100000, 10},
+	}
+
+	for _, tc := range tests {
+		if got := LevelForXP(tc.xp); got != tc.level {
+			t.Errorf("%d xp: expected level %d but found %d", tc.xp, tc.level, got)
+		}
+	}
+}
+
+func TestApplyLevel(t *testing.T) {
+	tests := []struct {
+		before UnitStats
+		level  int
+		after  UnitStats
+	}{
+		// gaining max hp heals by the same amount
+		{UnitStats{HP: 10, MaxHP: 16}, 2, UnitStats{Level: 2, HP: 14, MaxHP: 20, THAC0: 1, HealthRecoveryRate: 46}},
+		// losing max hp caps hp
+		{UnitStats{HP: 20, MaxHP: 20}, 1, UnitStats{Level: 1, HP: 16, MaxHP: 16, THAC0: 0, HealthRecoveryRate: 50}},
+		// levels out of range are clamped
+		{UnitStats{HP: 5, MaxHP: 16}, 0, UnitStats{Level: 1, HP: 5, MaxHP: 16, THAC0: 0, HealthRecoveryRate: 50}},
+		{UnitStats{HP: 60, MaxHP: 60}, 99, UnitStats{Level: 10, HP: 60, MaxHP: 60, THAC0: 9, HealthRecoveryRate: 18}},
+	}
+
+	for _, tc := range tests {
+		st := tc.before
+		st.ApplyLevel(tc.level)
+		if st != tc.after {
+			t.Errorf("level %d from %+v: expected %+v but found %+v", tc.level, tc.before, tc.after, st)
+		}
+	}
+}
+
+func TestExperience(t *testing.T) {
+	save := SavePlayerRecord
+	t.Cleanup(func() { SavePlayerRecord = save })
+
+	var saved []PlayerRecord
+	SavePlayerRecord = func(rec *PlayerRecord) error {
+		saved = append(saved, *rec)
+		return nil
+	}
+
+	g, alice := newTestGame(t)
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+	pl := alice.pl
+
+	// experience without a level-up only marks the record dirty
+	g.awardExperience(pl, 10)
+	if pl.Stats.XP != 10 || pl.Stats.Level != 1 || !pl.recordDirty || len(saved) != 0 {
+		t.Errorf("expected 10 xp at level 1, unsaved; found %d xp, level %d, dirty %v, %d saves",
+			pl.Stats.XP, pl.Stats.Level, pl.recordDirty, len(saved))
+	}
+
+	// levelling up saves the record
+	g.awardExperience(pl, 50)
+	if pl.Stats.XP != 60 || pl.Stats.Level != 3 || pl.Stats.MaxHP != 24 || pl.recordDirty {
+		t.Errorf("expected 60 xp at level 3, saved; found %d xp, level %d, max hp %d, dirty %v",
+			pl.Stats.XP, pl.Stats.Level, pl.Stats.MaxHP, pl.recordDirty)
+	}
+
+	if len(saved) != 1 || saved[0].Level != 3 || saved[0].XP != 60 {
+		t.Errorf("expected a saved level 3 record, found %+v", saved)
+	}
+
+	// losses come out of the experience earned toward the next level, and
+	// never drop the player below the current level
+	losses := []struct {
+		percent int
+		xp      int
+	}{
+		{50, 55},
+		{0, 55},
+		{100, 50},
+		{100, 50},
+	}
+
+	for _, loss := range losses {
+		g.loseExperience(pl, loss.percent)
+		if pl.Stats.XP != loss.xp || pl.Stats.Level != 3 || LevelForXP(pl.Stats.XP) != 3 {
+			t.Errorf("losing %d%%: expected %d xp at level 3, found %d xp at level %d",
+				loss.percent, loss.xp, pl.Stats.XP, pl.Stats.Level)
+		}
+	}
+
+	if !pl.recordDirty || len(saved) != 1 {
+		t.Errorf("expected losses to mark the record dirty without saving it")
+	}
+}
@@ -0,0 +1,202 @@
+// Package protocol defines the line-delimited JSON protocol used by
+// machine clients: graphical front ends and bots.
+//
+// Each message is one JSON object on its own line.  Every message has a
+// "type"; the other fields depend on the type.
+//
+// Server to client:
+//
+//	hello    - sent on connect, with the protocol version
+//	welcome  - login accepted, with the user name
+//	error    - a request failed, with text
+//	log      - a log or chat message, with level and text
+//	map      - the whole map, sent when joining a game
+//	update   - sent every tick while in a game: map changes, units, floor
+//	           items, the player's state and cooldowns
+//
+// Client to server:
+//
+//	login    - log in with a name (not needed over SSH)
+//	new_game - start or join a game
+//	action   - move (with direction), attack, defend or use (with item)
+//	chat     - console input: chat, #channel messages or /commands
+//	quit     - leave the game and disconnect
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+const Version = 1
+
+// Message types
+const (
+	TypeHello   = "hello"
+	TypeWelcome = "welcome"
+	TypeError   = "error"
+	TypeLog     = "log"
+	TypeMap     = "map"
+	TypeUpdate  = "update"
+
+	TypeLogin   = "login"
+	TypeNewGame = "new_game"
+	TypeAction  = "action"
+	TypeChat    = "chat"
+	TypeQuit    = "quit"
+)
+
+// Actions
+const (
+	ActionMove   = "move"
+	ActionAttack = "attack"
+	ActionDefend = "defend"
+	ActionUse    = "use"
+)
+
+// Map tiles
+const (
+	TileVoid    = '.'
+	TileEmpty   = ' '
+	TileWall    = '#'
+	TileCactus  = 'T'
+	TileUnknown = '?'
+)
+
+// Longest message line accepted
+const MaxLineLength = 64 * 1024
+
+type Message struct {
+	Type string `json:"type"`
+
+	Version int    `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+
+	// error, log and chat messages
+	Level string `json:"level,omitempty"`
+	Text  string `json:"text,omitempty"`
+
+	// action requests
+	Action    string `json:"action,omitempty"`
+	Direction string `json:"direction,omitempty"`
+	Item      *int   `json:"item,omitempty"`
+
+	Map    *Map    `json:"map,omitempty"`
+	Update *Update `json:"update,omitempty"`
+}
+
+// Map of the level, one string of tiles per row
+type Map struct {
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Rows   []string `json:"rows"`
+}
+
+// Change to one map tile
+type Cell struct {
+	I    int    `json:"i"`
+	J    int    `json:"j"`
+	Tile string `json:"tile"`
+}
+
+type Unit struct {
+	// "player:<name>" or "mob:<index>"
+	ID string `json:"id"`
+
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Marker string `json:"marker"`
+
+	I int `json:"i"`
+	J int `json:"j"`
+
+	HP    int  `json:"hp"`
+	MaxHP int  `json:"max_hp"`
+	Alive bool `json:"alive"`
+}
+
+type FloorItem struct {
+	I    int    `json:"i"`
+	J    int    `json:"j"`
+	Name string `json:"name"`
+}
+
+type PlayerState struct {
+	Name   string `json:"name"`
+	I      int    `json:"i"`
+	J      int    `json:"j"`
+	Facing string `json:"facing"`
+
+	HP    int `json:"hp"`
+	MaxHP int `json:"max_hp"`
+	Level int `json:"level"`
+	XP    int `json:"xp"`
+
+	Dead         bool `json:"dead"`
+	Ghost        bool `json:"ghost"`
+	RespawnTicks int  `json:"respawn_ticks,omitempty"`
+
+	Weapon    string   `json:"weapon"`
+	Inventory []string `json:"inventory"`
+	Effects   []string `json:"effects,omitempty"`
+}
+
+type Update struct {
+	Frame uint64 `json:"frame"`
+
+	Cells []Cell      `json:"cells,omitempty"`
+	Units []Unit      `json:"units"`
+	Items []FloorItem `json:"items"`
+
+	Player *PlayerState `json:"player,omitempty"`
+
+	// Ticks until each action can be used again
+	Cooldowns map[string]int `json:"cooldowns,omitempty"`
+}
+
+// Reads messages, one per line
+type Decoder struct {
+	sc *bufio.Scanner
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 4096), MaxLineLength)
+
+	return &Decoder{sc: sc}
+}
+
+// Reads the next message.  Blank lines are skipped.  Returns io.EOF at the
+// end of the stream.
+func (d *Decoder) Decode() (*Message, error) {
+	for d.sc.Scan() {
+		line := d.sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, err
+		}
+
+		return &msg, nil
+	}
+
+	if err := d.sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+// Encodes a message as a line
+func Encode(msg *Message) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	item := 2
+
+	input := strings.Join([]string{
+		`{"type":"login","name":"alice"}`,
+		``,
+		`{"type":"action","action":"move","direction":"up"}`,
+		`{"type":"action","action":"use","item":2}`,
+		`{"type":"chat","text":"/who","unknown":true}`,
+	}, "\n")
+
+	expected := []Message{
+		{Type: TypeLogin, Name: "alice"},
+		{Type: TypeAction, Action: ActionMove, Direction: "up"},
+		{Type: TypeAction, Action: ActionUse, Item: &item},
+		{Type: TypeChat, Text: "/who"},
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	for k, exp := range expected {
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("message %d: unexpected error %v", k, err)
+		}
+
+		if !reflect.DeepEqual(*msg, exp) {
+			t.Errorf("message %d: expected %+v but found %+v", k, exp, *msg)
+		}
+	}
+
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected EOF at the end, found %v", err)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"bad json", `{"type":`},
+		{"wrong type", `{"type":3}`},
+		{"too long", `{"text":"` + strings.Repeat("x", MaxLineLength) + `"}`},
+	}
+
+	for _, tc := range tests {
+		if msg, err := NewDecoder(strings.NewReader(tc.input)).Decode(); err == nil || err == io.EOF {
+			t.Errorf("%s: expected an error, found %+v (error %v)", tc.name, msg, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	data, err := Encode(&Message{Type: TypeHello, Version: Version})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if string(data) != `{"type":"hello","version":1}`+"\n" {
+		t.Errorf("unexpected encoding %q", data)
+	}
+
+	msg, err := NewDecoder(strings.NewReader(string(data))).Decode()
+	if err != nil || msg.Type != TypeHello || msg.Version != Version {
+		t.Errorf("expected the message to decode again, found %+v (error %v)", msg, err)
+	}
+}
@@ -0,0 +1,106 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrDuelsDisabled  = errors.New("duels are not allowed in this game")
+	ErrNoSuchPlayer   = errors.New("no such player")
+	ErrAlreadyDueling = errors.New("already in a duel")
+)
+
+// Whether attacker may damage victim under the game's PvP policy
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) canHurt(attacker, victim *Player) bool {
+	if attacker == victim || victim.Dead {
+		return false
+	}
+
+	switch g.Rules.PvP {
+	case PvPFreeForAll:
+		return true
+	case PvPDuel:
+		return attacker.DuelWith == victim && victim.DuelWith == attacker
+	default:
+		return false
+	}
+}
+
+// Challenges another player to a duel, or accepts that player's challenge
+func (g *Game) Duel(s Session, name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Rules.PvP != PvPDuel {
+		return ErrDuelsDisabled
+	}
+
+	pl := s.Player()
+	if pl == nil {
+		return ErrSpectating
+	}
+
+	other := g.Players[name]
+	if other == nil || other == pl {
+		return ErrNoSuchPlayer
+	}
+
+	if pl.DuelWith != nil {
+		return ErrAlreadyDueling
+	}
+
+	if other.DuelWith != nil {
+		return fmt.Errorf("%s is %w", other.Name(), ErrAlreadyDueling)
+	}
+
+	g.record(ReplayEvent{Kind: ReplayDuel, User: pl.Name(), Target: other.Name()})
+
+	if other.DuelChallenge == pl {
+		pl.DuelChallenge = nil
+		other.DuelChallenge = nil
+
+		pl.DuelWith = other
+		other.DuelWith = pl
+
+		g.messagef(chat.Game, "%s accepts the duel with %s.  Fight!", pl.Name(), other.Name())
+		return nil
+	}
+
+	pl.DuelChallenge = other
+	g.messagef(chat.Game, "%s challenges %s to a duel!", pl.Name(), other.Name())
+	other.S.Message(chat.Game, fmt.Sprintf("Type /duel %s to accept.", pl.Name()))
+
+	return nil
+}
+
+// Ends any duel involving the player and withdraws any challenges to or from
+// the player
+//
+// Assumes the write lock is held
+func (g *Game) endDuels(pl *Player) {
+	pl.DuelChallenge = nil
+	for _, other := range g.Players {
+		if other.DuelChallenge == pl {
+			other.DuelChallenge = nil
+		}
+	}
+
+	opponent := pl.DuelWith
+	if opponent == nil {
+		return
+	}
+
+	pl.DuelWith = nil
+	opponent.DuelWith = nil
+
+	if pl.Dead && pl.Killer == opponent.Name() {
+		g.messagef(chat.Game, "%s has won the duel against %s!", opponent.Name(), pl.Name())
+	} else {
+		g.messagef(chat.Game, "The duel between %s and %s is over.", opponent.Name(), pl.Name())
+	}
+}
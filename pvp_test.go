@@ -0,0 +1,124 @@
+package mpnethack
+
+import (
+	"errors"
+	"testing"
+)
+
+// Joins sessions for the named players to the game
+func joinTestPlayers(t *testing.T, g *Game, names ...string) []*replaySession {
+	var sessions []*replaySession
+	for _, name := range names {
+		sess := newReplaySession(name, g)
+
+		var err error
+		if sess.pl, err = g.PlayerJoin(sess); err != nil {
+			t.Fatalf("error joining %s: %v", name, err)
+		}
+
+		sessions = append(sessions, sess)
+	}
+
+	return sessions
+}
+
+func TestPvPPolicy(t *testing.T) {
+	g, _ := newTestGame(t)
+	players := joinTestPlayers(t, g, "alice", "bob")
+	alice, bob := players[0].pl, players[1].pl
+
+	tests := []struct {
+		policy PvPPolicy
+		hurts  bool
+	}{
+		{PvPOff, false},
+		{PvPDuel, false},
+		{PvPFreeForAll, true},
+	}
+
+	for _, tc := range tests {
+		g.Rules.PvP = tc.policy
+		if got := g.canHurt(alice, bob); got != tc.hurts {
+			t.Errorf("%v: expected alice hurting bob to be %v, found %v", tc.policy, tc.hurts, got)
+		}
+
+		if g.canHurt(alice, alice) {
+			t.Errorf("%v: expected players not to hurt themselves", tc.policy)
+		}
+	}
+
+	bob.Dead = true
+	if g.canHurt(alice, bob) {
+		t.Errorf("expected dead players not to be hurt")
+	}
+	bob.Dead = false
+
+	g.Rules.PvP = PvPOff
+	if err := g.Duel(players[0], "bob"); !errors.Is(err, ErrDuelsDisabled) {
+		t.Errorf("expected duels to be disabled, found %v", err)
+	}
+}
+
+func TestDuel(t *testing.T) {
+	g, _ := newTestGame(t)
+	g.Rules.PvP = PvPDuel
+
+	sessions := joinTestPlayers(t, g, "alice", "bob", "carol")
+	alice, bob, carol := sessions[0], sessions[1], sessions[2]
+
+	if err := g.Duel(alice, "bob"); err != nil {
+		t.Fatalf("error challenging bob: %v", err)
+	}
+
+	if alice.pl.DuelChallenge != bob.pl || alice.pl.DuelWith != nil {
+		t.Fatalf("expected alice to have challenged bob")
+	}
+
+	if g.canHurt(alice.pl, bob.pl) {
+		t.Errorf("expected no damage before the challenge is accepted")
+	}
+
+	if err := g.Duel(bob, "alice"); err != nil {
+		t.Fatalf("error accepting the duel: %v", err)
+	}
+
+	if alice.pl.DuelWith != bob.pl || bob.pl.DuelWith != alice.pl || alice.pl.DuelChallenge != nil {
+		t.Fatalf("expected alice and bob to be dueling")
+	}
+
+	if !g.canHurt(alice.pl, bob.pl) || !g.canHurt(bob.pl, alice.pl) {
+		t.Errorf("expected duelists to hurt each other")
+	}
+
+	if g.canHurt(carol.pl, alice.pl) || g.canHurt(alice.pl, carol.pl) {
+		t.Errorf("expected no damage outside the duel")
+	}
+
+	if err := g.Duel(carol, "alice"); !errors.Is(err, ErrAlreadyDueling) {
+		t.Errorf("expected alice to be busy, found %v", err)
+	}
+
+	if err := g.Duel(alice, "carol"); !errors.Is(err, ErrAlreadyDueling) {
+		t.Errorf("expected alice not to start a second duel, found %v", err)
+	}
+
+	if err := g.Duel(carol, "nobody"); !errors.Is(err, ErrNoSuchPlayer) {
+		t.Errorf("expected no such player, found %v", err)
+	}
+
+	// leaving ends the duel
+	g.PlayerLeave(bob)
+	if alice.pl.DuelWith != nil {
+		t.Errorf("expected the duel to end when bob left")
+	}
+
+	// and withdraws challenges to the player who left
+	if err := g.Duel(alice, "carol"); err != nil {
+		t.Fatalf("error challenging carol: %v", err)
+	}
+
+	g.PlayerLeave(carol)
+	if alice.pl.DuelChallenge != nil {
+		t.Errorf("expected the challenge to carol to be withdrawn")
+	}
+}
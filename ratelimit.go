@@ -0,0 +1,82 @@
+package mpnethack
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrInputRateLimited = errors.New("too much input, slow down")
+
+// Token bucket that limits how fast a session can send actions and chat.
+// Tokens refill at Rate per second, up to Burst.
+type InputLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// for tests
+	now func() time.Time
+}
+
+// Returns nil, meaning no limit, if rate isn't positive
+func NewInputLimiter(rate float64, burst int) *InputLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &InputLimiter{
+		Rate:   rate,
+		Burst:  burst,
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Uses up a token, and reports whether there was one to use.  A nil limiter
+// allows everything.
+func (l *InputLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.Rate
+		if max := float64(l.Burst); l.tokens > max {
+			l.tokens = max
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
+
+// Session whose input is rate limited
+type RateLimited interface {
+	InputLimiter() *InputLimiter
+}
+
+// Reports whether the session may send more input right now
+func allowInput(sess Session) bool {
+	if rl, ok := sess.(RateLimited); ok {
+		return rl.InputLimiter().Allow()
+	}
+
+	return true
+}
@@ -0,0 +1,377 @@
+package mpnethack
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrNotRecording   = errors.New("game is not being recorded")
+	ErrReplayMismatch = errors.New("replay does not match the recorded game")
+	ErrReplayOrder    = errors.New("replay events are out of order")
+)
+
+// Directory /replay saves replays in.  Set by the server.
+var ReplayDir = "replays"
+
+// Kind of replay event
+//
+// Join   - a player joined the game
+// Leave  - a player left the game
+// Action - a player's action was applied
+// Duel   - a player challenged another player to a duel, or accepted one
+type ReplayEventKind int
+
+const (
+	ReplayJoin ReplayEventKind = iota
+	ReplayLeave
+	ReplayAction
+	ReplayDuel
+)
+
+func (k ReplayEventKind) String() string {
+	switch k {
+	case ReplayJoin:
+		return "join"
+	case ReplayLeave:
+		return "leave"
+	case ReplayAction:
+		return "action"
+	case ReplayDuel:
+		return "duel"
+	default:
+		return fmt.Sprintf("replay_event_%d", int(k))
+	}
+}
+
+func (k ReplayEventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ReplayEventKind) UnmarshalText(text []byte) error {
+	s := string(text)
+	switch s {
+	case "join":
+		*k = ReplayJoin
+	case "leave":
+		*k = ReplayLeave
+	case "action":
+		*k = ReplayAction
+	case "duel":
+		*k = ReplayDuel
+	default:
+		return fmt.Errorf("unknown replay event \"%s\"", s)
+	}
+
+	return nil
+}
+
+// Something that changed the game.  Actions happen during the tick they are
+// stamped with; everything else happens between the previous tick and that
+// tick.
+type ReplayEvent struct {
+	Tick uint64          `toml:"tick"`
+	Kind ReplayEventKind `toml:"kind"`
+	User string          `toml:"user"`
+
+	Action ActionType `toml:"action,omitempty"`
+	Arg    int16      `toml:"arg,omitempty"`
+
+	// Experience of a joining player
+	XP int `toml:"xp,omitempty"`
+
+	// Player challenged to a duel
+	Target string `toml:"target,omitempty"`
+}
+
+// Recorded game: everything needed to rerun the game, and a digest of its
+// state at the end of the recording
+//
+// Admin changes (teleports, spawns, stat changes and the like) are not
+// recorded, so games changed by an admin will not replay.
+type Replay struct {
+	Seed  int64     `toml:"seed"`
+	Level string    `toml:"level"`
+	Rules GameRules `toml:"rules"`
+
+	FinalFrame uint64 `toml:"final_frame"`
+	FinalState string `toml:"final_state"`
+
+	Events []ReplayEvent `toml:"events"`
+}
+
+func (r *Replay) Write(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(r)
+}
+
+func (r *Replay) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func ReadReplay(r io.Reader) (*Replay, error) {
+	var replay Replay
+	if _, err := toml.NewDecoder(r).Decode(&replay); err != nil {
+		return nil, err
+	}
+
+	return &replay, nil
+}
+
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadReplay(f)
+}
+
+type ReplayRecorder struct {
+	Replay Replay
+}
+
+// Starts recording the game.  The recording should start before the game's
+// first tick.
+func NewReplayRecorder(g *Game) *ReplayRecorder {
+	return &ReplayRecorder{
+		Replay: Replay{
+			Seed:  g.Dice.Seed(),
+			Level: g.LevelName,
+			Rules: g.Rules,
+		},
+	}
+}
+
+// Assumes the write lock is held
+func (g *Game) record(ev ReplayEvent) {
+	if g.recorder == nil {
+		return
+	}
+
+	ev.Tick = g.FrameNum
+	g.recorder.Replay.Events = append(g.recorder.Replay.Events, ev)
+}
+
+// Replay of the game so far
+func (g *Game) Replay() (*Replay, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.recorder == nil {
+		return nil, ErrNotRecording
+	}
+
+	r := g.recorder.Replay
+	r.Events = append([]ReplayEvent{}, r.Events...)
+	r.FinalFrame = g.FrameNum
+	r.FinalState = g.stateDigest()
+
+	return &r, nil
+}
+
+// Digest of the game state that a replay must reproduce
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) stateDigest() string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "frame %d\n", g.FrameNum)
+
+	for _, pl := range g.playerList() {
+		fmt.Fprintf(h, "player %s %c %d,%d %v %+v dead=%v",
+			pl.Name(), pl.Marker, pl.I, pl.J, pl.Facing, pl.Stats, pl.Dead)
+		for _, itm := range pl.Inventory {
+			fmt.Fprintf(h, " %s", itm.ShortName())
+		}
+		fmt.Fprintln(h)
+	}
+
+	for i := range g.Mobs {
+		m := &g.Mobs[i]
+		fmt.Fprintf(h, "mob %d %v %d,%d %v %v %+v\n", i, m.Type, m.I, m.J, m.Direc, m.State, m.Stats)
+	}
+
+	for _, fi := range g.FloorItems {
+		fmt.Fprintf(h, "item %d,%d %s\n", fi.I, fi.J, fi.Item.ShortName())
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Stands in for a player's session while a replay runs
+type replaySession struct {
+	name string
+	g    *Game
+	pl   *Player
+	log  *chat.Log
+}
+
+func newReplaySession(name string, g *Game) *replaySession {
+	return &replaySession{name: name, g: g, log: chat.NewLog(GameLogNumLines)}
+}
+
+func (s *replaySession) IsAdministrator() bool { return false }
+func (s *replaySession) HasGame() bool         { return s.g != nil }
+func (s *replaySession) Game() *Game           { return s.g }
+func (s *replaySession) Player() *Player       { return s.pl }
+func (s *replaySession) UserName() string      { return s.name }
+func (s *replaySession) GetLog() *chat.Log     { return s.log }
+func (s *replaySession) ConsoleInput(string)   {}
+func (s *replaySession) Update() error         { return nil }
+func (s *replaySession) Quit()                 {}
+
+func (s *replaySession) Message(lvl chat.MsgLevel, txt string) error {
+	s.log.LogLine(lvl, txt)
+	return nil
+}
+
+func (s *replaySession) Join(g *Game) error {
+	s.g = g
+	return nil
+}
+
+// Reruns a recorded game and checks that it ends in the recorded state.
+// Returns the game, stopped at the replay's final frame.
+func RunReplay(r *Replay) (*Game, error) {
+	lvl, err := BuildLevel(r.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	g := newGame(lvl, r.Rules, NewDiceFromSeed(r.Seed))
+	g.LevelName = r.Level
+	g.offline = true
+	defer g.Shutdown()
+
+	sessions := map[string]*replaySession{}
+	events := r.Events
+
+	for {
+		for len(events) > 0 && events[0].Tick <= g.FrameNum {
+			ev := events[0]
+			events = events[1:]
+
+			if ev.Tick < g.FrameNum {
+				return g, fmt.Errorf("%w: %s event at tick %d after tick %d", ErrReplayOrder, ev.Kind, ev.Tick, g.FrameNum)
+			}
+
+			if err := g.replayEvent(sessions, ev); err != nil {
+				return g, fmt.Errorf("tick %d: %w", ev.Tick, err)
+			}
+		}
+
+		if g.FrameNum >= r.FinalFrame {
+			break
+		}
+
+		g.loopInner()
+	}
+
+	if len(events) > 0 {
+		return g, fmt.Errorf("%w: %d events after the final frame", ErrReplayOrder, len(events))
+	}
+
+	g.mu.RLock()
+	digest := g.stateDigest()
+	g.mu.RUnlock()
+
+	if digest != r.FinalState {
+		return g, fmt.Errorf("%w at frame %d", ErrReplayMismatch, r.FinalFrame)
+	}
+
+	return g, nil
+}
+
+func (g *Game) replayEvent(sessions map[string]*replaySession, ev ReplayEvent) error {
+	sess := sessions[ev.User]
+	if ev.Kind != ReplayJoin && sess == nil {
+		return fmt.Errorf("%w \"%s\"", ErrNoSuchPlayer, ev.User)
+	}
+
+	switch ev.Kind {
+	case ReplayJoin:
+		sess = newReplaySession(ev.User, g)
+
+		g.mu.Lock()
+		pl, err := g.addPlayer(sess, ev.XP)
+		g.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		sess.pl = pl
+		sessions[ev.User] = sess
+
+	case ReplayLeave:
+		g.PlayerLeave(sess)
+		delete(sessions, ev.User)
+
+	case ReplayAction:
+		if int(ev.Action) >= MaxActionType {
+			return fmt.Errorf("unknown action %v", ev.Action)
+		}
+
+		g.mu.Lock()
+		g.pendingActions = append(g.pendingActions, Action{sess.pl, ev.Action, ev.Arg})
+		g.mu.Unlock()
+
+	case ReplayDuel:
+		return g.Duel(sess, ev.Target)
+
+	default:
+		return fmt.Errorf("unknown replay event %v", ev.Kind)
+	}
+
+	return nil
+}
+
+func registerReplayCommands(r *CommandRegistry) {
+	r.MustRegister(&Command{
+		Name:       "replay",
+		Args:       []ArgSpec{{Name: "file"}},
+		Help:       "Saves a replay of the game so far",
+		Permission: PermAdmin,
+		NeedsGame:  true,
+		Run: func(ctx *CommandContext) error {
+			replay, err := ctx.Game.Replay()
+			if err != nil {
+				return err
+			}
+
+			path, err := commandFilePath(ReplayDir, ctx.Arg("file"))
+			if err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(ReplayDir, 0755); err != nil {
+				return err
+			}
+
+			if err := replay.Save(path); err != nil {
+				return err
+			}
+
+			ctx.Reply(chat.Info, "Saved replay of %d frames (%d events) to %s",
+				replay.FinalFrame, len(replay.Events), path)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,288 @@
+package mpnethack
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+func testLookupItem(tag string) (Item, error) {
+	return &MeleeWeapon{
+		BasicItem:   BasicItem{tag: tag, name: tag, shortName: tag},
+		damage:      Roll{M: 1, N: 4},
+		swingArc:    1,
+		swingLength: 1,
+		swingTicks:  3,
+	}, nil
+}
+
+func init() {
+	RegisterLevel("replay_test", func() *Level {
+		lvl := SingleRoomLevel(20, 20, 12, 12)
+		lvl.PlayerI0, lvl.PlayerJ0 = 10, 10
+
+		stats := UnitStats{ArmorClass: 8, THAC0: 4, HP: 10, MaxHP: 10, HealthRecoveryRate: 200}
+		lvl.AddMob(MobViciousLemming, stats, 7, 10, Down, MobWander)
+		lvl.AddMob(MobLemming, stats, 12, 12, Left, MobPatrol)
+
+		return lvl
+	})
+}
+
+// Sets up a game on the test level, and a session for alice.  Replaces
+// LookupItem until the test ends.
+func newTestGame(t *testing.T) (*Game, *replaySession) {
+	lookup := LookupItem
+	t.Cleanup(func() { LookupItem = lookup })
+	LookupItem = testLookupItem
+
+	lvl, err := BuildLevel("replay_test")
+	if err != nil {
+		t.Fatalf("error building level: %v", err)
+	}
+
+	g := newGame(lvl, DefaultGameRules, NewDiceFromSeed(42))
+	g.LevelName = "replay_test"
+
+	return g, newReplaySession("alice", g)
+}
+
+// Runs ticks [from,to) of a scripted game
+func playTestGame(g *Game, alice *replaySession, from, to int) {
+	moves := map[int]Direction{3: Up, 10: Up, 20: Left, 40: Right, 130: Down}
+	for tick := from; tick < to; tick++ {
+		if direc, ok := moves[tick]; ok {
+			g.Move(alice, direc)
+		} else if tick%7 == 0 {
+			g.UserAction(alice, Attack, 0)
+		}
+
+		g.loopInner()
+	}
+}
+
+func TestReplay(t *testing.T) {
+
+	g, alice := newTestGame(t)
+	g.recorder = NewReplayRecorder(g)
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+
+	playTestGame(g, alice, 0, 120)
+
+	replay, err := g.Replay()
+	if err != nil {
+		t.Fatalf("error getting replay: %v", err)
+	}
+
+	if len(replay.Events) < 2 || replay.Events[0].Kind != ReplayJoin {
+		t.Fatalf("expected a join and actions, found %+v", replay.Events)
+	}
+
+	var buf bytes.Buffer
+	if err := replay.Write(&buf); err != nil {
+		t.Fatalf("error writing replay: %v", err)
+	}
+
+	loaded, err := ReadReplay(&buf)
+	if err != nil {
+		t.Fatalf("error reading replay: %v", err)
+	}
+
+	if _, err := RunReplay(loaded); err != nil {
+		t.Errorf("error running replay: %v", err)
+	}
+
+	for i := range loaded.Events {
+		if ev := &loaded.Events[i]; ev.Kind == ReplayAction && ev.Action == Move {
+			ev.Arg = int16(Down)
+			break
+		}
+	}
+
+	if _, err := RunReplay(loaded); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("expected a mismatch after changing an action, found %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	g, alice := newTestGame(t)
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+
+	playTestGame(g, alice, 0, 100)
+
+	var buf bytes.Buffer
+	if err := g.Snapshot().Write(&buf); err != nil {
+		t.Fatalf("error writing snapshot: %v", err)
+	}
+
+	// enumerations are saved by name
+	for _, key := range []string{`state = "`, `aggression = "`} {
+		if !strings.Contains(buf.String(), key) {
+			t.Errorf("expected snapshot to contain %s", key)
+		}
+	}
+
+	snap, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("error reading snapshot: %v", err)
+	}
+
+	restored, err := restoreGame(snap)
+	if err != nil {
+		t.Fatalf("error restoring snapshot: %v", err)
+	}
+
+	if !restored.IsWaitingFor("alice") {
+		t.Fatalf("expected alice to be waiting to rejoin")
+	}
+
+	alice2 := newReplaySession("alice", restored)
+	if alice2.pl, err = restored.PlayerJoin(alice2); err != nil {
+		t.Fatalf("error rejoining game: %v", err)
+	}
+
+	if alice2.pl.Stats != alice.pl.Stats || alice2.pl.I != alice.pl.I || alice2.pl.J != alice.pl.J {
+		t.Errorf("expected alice restored as %+v but found %+v", alice.pl, alice2.pl)
+	}
+
+	if got, expected := restored.stateDigest(), g.stateDigest(); got != expected {
+		t.Fatalf("restored state differs from saved state")
+	}
+
+	// both games should carry on the same way
+	playTestGame(g, alice, 100, 200)
+	playTestGame(restored, alice2, 100, 200)
+
+	if got, expected := restored.stateDigest(), g.stateDigest(); got != expected {
+		t.Errorf("restored game diverged from the original")
+	}
+
+	snap.Version = SnapshotVersion + 1
+	if _, err := restoreGame(snap); !errors.Is(err, ErrSnapshotVersion) {
+		t.Errorf("expected version error, found %v", err)
+	}
+}
+
+func TestPlayerLeaveTwice(t *testing.T) {
+	g, alice := newTestGame(t)
+	g.recorder = NewReplayRecorder(g)
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+
+	// a kicked session leaves again when it disconnects
+	g.PlayerLeave(alice)
+	g.PlayerLeave(alice)
+
+	replay, err := g.Replay()
+	if err != nil {
+		t.Fatalf("error getting replay: %v", err)
+	}
+
+	leaves := 0
+	for _, ev := range replay.Events {
+		if ev.Kind == ReplayLeave {
+			leaves++
+		}
+	}
+
+	if leaves != 1 || len(g.Players) != 0 {
+		t.Errorf("expected one leave and no players, found %d leaves and %d players", leaves, len(g.Players))
+	}
+}
+
+func TestLinkdead(t *testing.T) {
+	g, alice := newTestGame(t)
+	g.recorder = NewReplayRecorder(g)
+	g.Rules.LinkdeadTicks = 20
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+
+	playTestGame(g, alice, 0, 5)
+
+	g.PlayerDisconnect(alice)
+	if !g.IsLinkdead("alice") || len(g.Active) != 0 || g.Players["alice"] != alice.pl {
+		t.Fatalf("expected alice's player to be linkdead")
+	}
+
+	g.Message(chat.Game, "a lemming sneezes")
+	alice.Message(chat.Private, "[bob] psst")
+	for i := 0; i < 5; i++ {
+		g.loopInner()
+	}
+
+	// alice reconnects with a new session
+	again := newReplaySession("alice", g)
+	if again.pl, err = g.PlayerJoin(again); err != nil {
+		t.Fatalf("error rejoining game: %v", err)
+	}
+
+	if again.pl != alice.pl || again.pl.S != again || again.pl.Linkdead {
+		t.Fatalf("expected the new session to take over alice's player")
+	}
+
+	var missed []string
+	again.log.VisitLines(0, func(msg chat.Message) bool {
+		missed = append(missed, msg.Text)
+		return true
+	})
+
+	if !strings.Contains(strings.Join(missed, "\n"), "joined the game!\n--- while you were away ---\n[bob] psst\na lemming sneezes") {
+		t.Errorf("expected the missed lines to be replayed, found %q", missed)
+	}
+
+	// kicking a linkdead player removes it from the game
+	g.PlayerDisconnect(again)
+	lobby := &Lobby{Games: []*Game{g}}
+	if err := lobby.Kick(newTestSession("admin"), "ALICE", ""); err != nil {
+		t.Fatalf("error kicking linkdead player: %v", err)
+	}
+
+	if len(g.Players) != 0 || g.IsLinkdead("alice") {
+		t.Fatalf("expected the kick to remove alice's linkdead player")
+	}
+
+	if err := lobby.Kick(newTestSession("admin"), "alice", ""); !errors.Is(err, ErrNoSuchPlayer) {
+		t.Errorf("expected no such player error, found %v", err)
+	}
+
+	// alice joins again, and this time doesn't come back
+	again = newReplaySession("alice", g)
+	if again.pl, err = g.PlayerJoin(again); err != nil {
+		t.Fatalf("error rejoining game: %v", err)
+	}
+
+	g.PlayerDisconnect(again)
+	for i := 0; i < 25; i++ {
+		g.loopInner()
+	}
+
+	if len(g.Players) != 0 {
+		t.Fatalf("expected alice's player to leave after the grace period")
+	}
+
+	replay, err := g.Replay()
+	if err != nil {
+		t.Fatalf("error getting replay: %v", err)
+	}
+
+	if _, err := RunReplay(replay); err != nil {
+		t.Errorf("error running replay: %v", err)
+	}
+}
@@ -12,17 +12,50 @@ import (
 
 type Dice struct {
 	rng  *rand.Rand
+	src  *countingSource
 	seed int64
 }
 
+// Counts the values drawn from a source, so dice can be saved and restored
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.draws = 0
+}
+
 func NewDiceFromSeed(seed int64) Dice {
-	source := rand.NewSource(seed)
+	src := &countingSource{src: rand.NewSource(seed).(rand.Source64)}
 	return Dice{
-		rng:  rand.New(source),
+		rng:  rand.New(src),
+		src:  src,
 		seed: seed,
 	}
 }
 
+// Dice with the seed that have already drawn the given number of values
+func NewDiceAt(seed int64, draws uint64) Dice {
+	d := NewDiceFromSeed(seed)
+	for d.src.draws < draws {
+		d.src.Int63()
+	}
+
+	return d
+}
+
 func NewDice() (Dice, error) {
 	var randBytes [8]byte
 
@@ -43,6 +76,11 @@ func (d Dice) Seed() int64 {
 	return d.seed
 }
 
+// Number of values drawn since the dice were seeded
+func (d Dice) Draws() uint64 {
+	return d.src.draws
+}
+
 func (d Dice) RollD20() int {
 	return d.rng.Intn(20) + 1
 }
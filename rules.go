@@ -0,0 +1,87 @@
+package mpnethack
+
+import "fmt"
+
+// Player-versus-player policy
+//
+// Off           - players cannot hurt each other
+// Duel          - players can only hurt each other after agreeing to a duel
+// Free for all  - players can hurt any other player
+type PvPPolicy int
+
+const (
+	PvPOff PvPPolicy = iota
+	PvPDuel
+	PvPFreeForAll
+)
+
+func (pvp PvPPolicy) String() string {
+	switch pvp {
+	case PvPOff:
+		return "off"
+	case PvPDuel:
+		return "duel"
+	case PvPFreeForAll:
+		return "free_for_all"
+	default:
+		return fmt.Sprintf("pvp_%d", int(pvp))
+	}
+}
+
+func (pvp PvPPolicy) MarshalText() ([]byte, error) {
+	return []byte(pvp.String()), nil
+}
+
+func (pvp *PvPPolicy) UnmarshalText(text []byte) error {
+	s := string(text)
+	switch s {
+	case "off":
+		*pvp = PvPOff
+	case "duel":
+		*pvp = PvPDuel
+	case "free_for_all", "ffa":
+		*pvp = PvPFreeForAll
+	default:
+		return fmt.Errorf("unknown pvp policy \"%s\"", s)
+	}
+
+	return nil
+}
+
+// Per-game rules
+//
+// Rules are copied into each Game when it is created, so servers can tune
+// them without affecting games that are already running.
+type GameRules struct {
+	// Number of ticks a dead player waits before respawning
+	RespawnTicks int16
+
+	// Dead players drop their inventory where they died
+	DropInventoryOnDeath bool
+
+	// Percent of the experience earned toward the next level that is lost
+	// on death
+	XPLossOnDeathPercent int
+
+	// Dead players may watch the game as ghosts until they respawn
+	AllowGhosts bool
+
+	// Number of ticks a disconnected player's character waits for the user
+	// to reconnect.  If zero, the character leaves when the connection drops.
+	LinkdeadTicks int16
+
+	// Whether players can hurt each other
+	PvP PvPPolicy
+
+	// Spectators may chat with the players
+	SpectatorChat bool
+}
+
+var DefaultGameRules = GameRules{
+	RespawnTicks:         100,
+	DropInventoryOnDeath: true,
+	XPLossOnDeathPercent: 10,
+	AllowGhosts:          true,
+	LinkdeadTicks:        3000,
+	PvP:                  PvPOff,
+}
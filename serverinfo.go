@@ -0,0 +1,222 @@
+package mpnethack
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+// Lists every stored player record.  Set by the server, like
+// LookupPlayerRecord.
+var ListPlayerRecords func() ([]PlayerRecord, error)
+
+// Summary of a running game
+type GameInfo struct {
+	Number     int      `json:"number"`
+	Level      string   `json:"level"`
+	Frame      uint64   `json:"frame"`
+	Paused     bool     `json:"paused"`
+	Players    []string `json:"players"`
+	Spectators int      `json:"spectators"`
+}
+
+// Lists the running games, numbered as in Who
+func (l *Lobby) GameList() []GameInfo {
+	l.mu.Lock()
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	infos := make([]GameInfo, 0, len(games))
+	for i, g := range games {
+		names := g.PlayerNames()
+
+		g.mu.RLock()
+		infos = append(infos, GameInfo{
+			Number:     i + 1,
+			Level:      g.LevelName,
+			Frame:      g.FrameNum,
+			Paused:     g.paused,
+			Players:    names,
+			Spectators: len(g.Spectators),
+		})
+		g.mu.RUnlock()
+	}
+
+	return infos
+}
+
+// A player's stats.  Kills, deaths and health are only known for players in
+// a game.
+type PlayerStats struct {
+	Name   string `json:"name"`
+	Online bool   `json:"online"`
+	Where  string `json:"where,omitempty"`
+
+	Level int `json:"level"`
+	XP    int `json:"xp"`
+
+	HP          int `json:"hp,omitempty"`
+	MaxHP       int `json:"max_hp,omitempty"`
+	MobKills    int `json:"mob_kills"`
+	PlayerKills int `json:"player_kills"`
+	Deaths      int `json:"deaths"`
+}
+
+// Looks up the stats of a connected player, or the stored record of one
+// who isn't connected
+func (l *Lobby) PlayerStats(name string) (*PlayerStats, error) {
+	l.mu.Lock()
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	for i, g := range games {
+		g.mu.RLock()
+		for plName, pl := range g.Players {
+			if !strings.EqualFold(plName, name) {
+				continue
+			}
+
+			st := &PlayerStats{
+				Name:        plName,
+				Online:      !pl.Linkdead,
+				Where:       fmt.Sprintf("game %d", i+1),
+				Level:       pl.Stats.Level,
+				XP:          pl.Stats.XP,
+				HP:          pl.Stats.HP,
+				MaxHP:       pl.Stats.MaxHP,
+				MobKills:    pl.MobKills,
+				PlayerKills: pl.PlayerKills,
+				Deaths:      pl.Deaths,
+			}
+			g.mu.RUnlock()
+
+			return st, nil
+		}
+		g.mu.RUnlock()
+	}
+
+	sess := l.FindSession(name)
+	if sess != nil {
+		name = sess.UserName()
+	}
+
+	var rec *PlayerRecord
+	if LookupPlayerRecord != nil {
+		var err error
+		if rec, err = LookupPlayerRecord(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if rec == nil {
+		return nil, fmt.Errorf("%w \"%s\"", ErrNoSuchPlayer, name)
+	}
+
+	st := &PlayerStats{
+		Name:  rec.Name,
+		Level: rec.Level,
+		XP:    rec.XP,
+	}
+
+	if sess != nil {
+		st.Online = true
+		st.Where = "lobby"
+	}
+
+	return st, nil
+}
+
+// Players with the most experience, best first.  Players in a game are
+// ranked by their current experience; bots and other recordless sessions
+// aren't ranked.  Returns at most n records, or all of
+// them if n isn't positive.
+func (l *Lobby) Scores(n int) ([]PlayerRecord, error) {
+	byName := make(map[string]PlayerRecord)
+
+	if ListPlayerRecords != nil {
+		recs, err := ListPlayerRecords()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rec := range recs {
+			byName[rec.Name] = rec
+		}
+	}
+
+	l.mu.Lock()
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	for _, g := range games {
+		g.mu.RLock()
+		for _, pl := range g.Players {
+			if hasRecord(pl.S) {
+				byName[pl.Name()] = *pl.Record()
+			}
+		}
+		g.mu.RUnlock()
+	}
+
+	scores := make([]PlayerRecord, 0, len(byName))
+	for _, rec := range byName {
+		scores = append(scores, rec)
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].XP != scores[j].XP {
+			return scores[i].XP > scores[j].XP
+		}
+
+		return strings.ToLower(scores[i].Name) < strings.ToLower(scores[j].Name)
+	})
+
+	if n > 0 && len(scores) > n {
+		scores = scores[:n]
+	}
+
+	return scores, nil
+}
+
+// Sends an admin message to everyone connected
+func (l *Lobby) Broadcast(txt string) {
+	line := fmt.Sprintf("[broadcast] %s", txt)
+	for _, sess := range l.AllSessions() {
+		sess.Message(chat.Admin, line)
+	}
+}
+
+// Warns everyone, saves the players and stops the games, then calls
+// OnShutdown so the server can exit
+func (l *Lobby) Shutdown(reason string) {
+	msg := "The server is shutting down"
+	if reason != "" {
+		msg += ": " + reason
+	}
+
+	log.Printf("server shutdown: %q", reason)
+	l.Broadcast(msg)
+
+	l.mu.Lock()
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	for _, g := range games {
+		g.mu.Lock()
+		for _, pl := range g.playerList() {
+			if pl.recordDirty {
+				g.savePlayer(pl)
+			}
+		}
+		g.mu.Unlock()
+
+		g.Shutdown()
+	}
+
+	if l.OnShutdown != nil {
+		l.OnShutdown()
+	}
+}
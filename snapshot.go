@@ -0,0 +1,755 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+// Version of the snapshot file format.  Bump this when the format changes in
+// a way older code can't read.
+const SnapshotVersion = 2
+
+var (
+	ErrSnapshotVersion = errors.New("unsupported snapshot version")
+	ErrBadSnapshot     = errors.New("invalid snapshot")
+)
+
+// Directory /save and /restore keep snapshots in.  Set by the server.
+var SnapshotDir = "snapshots"
+
+// Saved state of a running game
+//
+// Snapshots are taken between ticks.  Actions queued for the next tick are
+// not saved.  Mob states, aggression and status effects are saved by name;
+// other enumerations are saved as numbers.
+type Snapshot struct {
+	Version int `toml:"version"`
+
+	Level string    `toml:"level"`
+	Rules GameRules `toml:"rules"`
+
+	Seed  int64  `toml:"seed"`
+	Draws uint64 `toml:"draws"`
+
+	Frame        uint64        `toml:"frame"`
+	Paused       bool          `toml:"paused"`
+	TickInterval time.Duration `toml:"tick_interval"`
+
+	Board BoardSnapshot `toml:"board"`
+
+	Players []PlayerSnapshot    `toml:"players"`
+	Mobs    []MobSnapshot       `toml:"mobs"`
+	Items   []FloorItemSnapshot `toml:"items"`
+}
+
+type BoardSnapshot struct {
+	W        int      `toml:"w"`
+	H        int      `toml:"h"`
+	PlayerI0 int      `toml:"player_i0"`
+	PlayerJ0 int      `toml:"player_j0"`
+	Elements []Marker `toml:"elements"`
+}
+
+type EffectSnapshot struct {
+	Type      StatusEffectType `toml:"type"`
+	Remaining int16            `toml:"remaining"`
+	Tick      int16            `toml:"tick"`
+	Stacks    int              `toml:"stacks"`
+	Magnitude int              `toml:"magnitude"`
+}
+
+type PlayerSnapshot struct {
+	Name   string `toml:"name"`
+	I      int    `toml:"i"`
+	J      int    `toml:"j"`
+	Marker string `toml:"marker"`
+	Facing int    `toml:"facing"`
+
+	Inventory []string `toml:"inventory"`
+	Weapon    string   `toml:"weapon"`
+
+	Cooldowns []uint64 `toml:"cooldowns"`
+
+	Stats   UnitStats        `toml:"stats"`
+	Effects []EffectSnapshot `toml:"effects"`
+
+	BusyTick    int16 `toml:"busy_tick"`
+	HealthTick  int16 `toml:"health_tick"`
+	SwingRate   int16 `toml:"swing_rate"`
+	SwingTick   int16 `toml:"swing_tick"`
+	SwingState  int16 `toml:"swing_state"`
+	SwingFacing int   `toml:"swing_facing"`
+
+	Dead        bool   `toml:"dead"`
+	Ghost       bool   `toml:"ghost"`
+	Killer      string `toml:"killer"`
+	RespawnTick int16  `toml:"respawn_tick"`
+
+	DuelWith      string `toml:"duel_with"`
+	DuelChallenge string `toml:"duel_challenge"`
+
+	MobKills    int `toml:"mob_kills"`
+	PlayerKills int `toml:"player_kills"`
+	Deaths      int `toml:"deaths"`
+}
+
+type ThreatSnapshot struct {
+	Unit   string `toml:"unit"`
+	Threat int    `toml:"threat"`
+}
+
+type MobSnapshot struct {
+	I int `toml:"i"`
+	J int `toml:"j"`
+
+	Stats   UnitStats        `toml:"stats"`
+	Effects []EffectSnapshot `toml:"effects"`
+	Type    int              `toml:"type"`
+
+	MoveTick   int16     `toml:"move_tick"`
+	StunTick   int16     `toml:"stun_tick"`
+	SeekTick   int16     `toml:"seek_tick"`
+	AttackTick int16     `toml:"attack_tick"`
+	ActionTick [4]uint16 `toml:"action_tick"`
+
+	Direc  int    `toml:"direc"`
+	Weapon string `toml:"weapon"`
+
+	Event      int    `toml:"event"`
+	EventCause string `toml:"event_cause"`
+
+	State    MobState `toml:"state"`
+	StateArg int      `toml:"state_arg"`
+
+	Aggression  Aggression `toml:"aggression"`
+	Target      string     `toml:"target"`
+	LastTargetI int        `toml:"last_target_i"`
+	LastTargetJ int        `toml:"last_target_j"`
+
+	Threat          []ThreatSnapshot `toml:"threat"`
+	ThreatDecayTick int16            `toml:"threat_decay_tick"`
+
+	Pack       int  `toml:"pack"`
+	PackLeader bool `toml:"pack_leader"`
+}
+
+type FloorItemSnapshot struct {
+	I    int    `toml:"i"`
+	J    int    `toml:"j"`
+	Item string `toml:"item"`
+}
+
+func (snap *Snapshot) Write(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(snap)
+}
+
+func (snap *Snapshot) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := snap.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var snap Snapshot
+	if _, err := toml.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, err
+	}
+
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w %d (expected %d)", ErrSnapshotVersion, snap.Version, SnapshotVersion)
+	}
+
+	return &snap, nil
+}
+
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
+
+// Tag of an item, or "" for no item or bare hands
+func itemTag(itm Item) string {
+	if itm == nil {
+		return ""
+	}
+
+	if w, ok := itm.(*MeleeWeapon); ok && w == nil {
+		return ""
+	}
+
+	return itm.Tag()
+}
+
+func lookupItemTag(tag string) (Item, error) {
+	if tag == "" {
+		return BareHands, nil
+	}
+
+	if LookupItem == nil {
+		return nil, fmt.Errorf("cannot look up item \"%s\"", tag)
+	}
+
+	return LookupItem(tag)
+}
+
+func snapshotEffects(effs StatusEffects) []EffectSnapshot {
+	var snaps []EffectSnapshot
+	for _, eff := range effs {
+		snaps = append(snaps, EffectSnapshot{
+			Type:      eff.Type,
+			Remaining: eff.Remaining,
+			Tick:      eff.Tick,
+			Stacks:    eff.Stacks,
+			Magnitude: eff.Magnitude,
+		})
+	}
+
+	return snaps
+}
+
+func restoreEffects(snaps []EffectSnapshot) StatusEffects {
+	var effs StatusEffects
+	for _, s := range snaps {
+		effs = append(effs, StatusEffect{
+			Type:      s.Type,
+			Remaining: s.Remaining,
+			Tick:      s.Tick,
+			Stacks:    s.Stacks,
+			Magnitude: s.Magnitude,
+		})
+	}
+
+	return effs
+}
+
+// Reference to a unit in a snapshot: "player:<name>" or "mob:<index>"
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) unitRef(u Unit) string {
+	switch u := u.(type) {
+	case *Player:
+		if u != nil && u.S != nil {
+			return "player:" + u.Name()
+		}
+
+	case *Mob:
+		for i := range g.Mobs {
+			if &g.Mobs[i] == u {
+				return "mob:" + strconv.Itoa(i)
+			}
+		}
+	}
+
+	return ""
+}
+
+// Resolves a unit reference.  Players are not in the game when it is
+// restored, so references to players resolve to nil.
+//
+// Assumes the write lock is held
+func (g *Game) resolveUnitRef(ref string) Unit {
+	if !strings.HasPrefix(ref, "mob:") {
+		return nil
+	}
+
+	ind, err := strconv.Atoi(ref[len("mob:"):])
+	if err != nil || ind < 0 || ind >= len(g.Mobs) {
+		return nil
+	}
+
+	return &g.Mobs[ind]
+}
+
+func (g *Game) Snapshot() *Snapshot {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.snapshot()
+}
+
+// Assumes the lock is held (either read or write)
+func (g *Game) snapshot() *Snapshot {
+	snap := &Snapshot{
+		Version: SnapshotVersion,
+
+		Level: g.LevelName,
+		Rules: g.Rules,
+
+		Seed:  g.Dice.Seed(),
+		Draws: g.Dice.Draws(),
+
+		Frame:        g.FrameNum,
+		Paused:       g.paused,
+		TickInterval: g.tickInterval,
+
+		Board: BoardSnapshot{
+			W:        g.Level.W,
+			H:        g.Level.H,
+			PlayerI0: g.Level.PlayerI0,
+			PlayerJ0: g.Level.PlayerJ0,
+			Elements: append([]Marker{}, g.Level.Elements...),
+		},
+	}
+
+	names := make(map[*Player]string)
+	for name, pl := range g.Players {
+		names[pl] = name
+	}
+	for name, pl := range g.waiting {
+		names[pl] = name
+	}
+
+	for _, pl := range g.allPlayers() {
+		ps := PlayerSnapshot{
+			Name:   names[pl],
+			I:      pl.I,
+			J:      pl.J,
+			Marker: string(pl.Marker),
+			Facing: int(pl.Facing),
+
+			Weapon:    itemTag(pl.Weapon),
+			Cooldowns: append([]uint64{}, pl.Cooldowns...),
+
+			Stats:   pl.Stats,
+			Effects: snapshotEffects(pl.Effects),
+
+			BusyTick:    pl.BusyTick,
+			HealthTick:  pl.HealthTick,
+			SwingRate:   pl.SwingRate,
+			SwingTick:   pl.SwingTick,
+			SwingState:  pl.SwingState,
+			SwingFacing: int(pl.SwingFacing),
+
+			Dead:        pl.Dead,
+			Ghost:       pl.Ghost,
+			Killer:      pl.Killer,
+			RespawnTick: pl.RespawnTick,
+
+			DuelWith:      names[pl.DuelWith],
+			DuelChallenge: names[pl.DuelChallenge],
+
+			MobKills:    pl.MobKills,
+			PlayerKills: pl.PlayerKills,
+			Deaths:      pl.Deaths,
+		}
+
+		for _, itm := range pl.Inventory {
+			ps.Inventory = append(ps.Inventory, itemTag(itm))
+		}
+
+		snap.Players = append(snap.Players, ps)
+	}
+
+	for i := range g.Mobs {
+		m := &g.Mobs[i]
+
+		ms := MobSnapshot{
+			I: m.I,
+			J: m.J,
+
+			Stats:   m.Stats,
+			Effects: snapshotEffects(m.Effects),
+			Type:    int(m.Type),
+
+			MoveTick:   m.MoveTick,
+			StunTick:   m.StunTick,
+			SeekTick:   m.SeekTick,
+			AttackTick: m.AttackTick,
+			ActionTick: m.ActionTick,
+
+			Direc:  int(m.Direc),
+			Weapon: itemTag(m.Weapon),
+
+			Event:      int(m.Event),
+			EventCause: g.unitRef(m.EventCause),
+
+			State:    m.State,
+			StateArg: m.StateArg,
+
+			Aggression:  m.Aggression,
+			Target:      g.unitRef(m.Target),
+			LastTargetI: m.LastTargetI,
+			LastTargetJ: m.LastTargetJ,
+
+			ThreatDecayTick: m.Threat.DecayTick,
+
+			Pack:       m.Pack,
+			PackLeader: m.PackLeader,
+		}
+
+		for _, ent := range m.Threat.Entries {
+			if ref := g.unitRef(ent.Unit); ref != "" {
+				ms.Threat = append(ms.Threat, ThreatSnapshot{Unit: ref, Threat: ent.Threat})
+			}
+		}
+
+		snap.Mobs = append(snap.Mobs, ms)
+	}
+
+	for _, fi := range g.FloorItems {
+		snap.Items = append(snap.Items, FloorItemSnapshot{I: fi.I, J: fi.J, Item: itemTag(fi.Item)})
+	}
+
+	return snap
+}
+
+// Players in the game and players waiting to rejoin it, sorted by name
+//
+// Assumes the lock is held (either read or write)
+func (g *Game) allPlayers() []*Player {
+	players := g.playerList()
+	if len(g.waiting) == 0 {
+		return players
+	}
+
+	names := make([]string, 0, len(g.waiting))
+	for name := range g.waiting {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		players = append(players, g.waiting[name])
+	}
+
+	return players
+}
+
+// Starts a game from a snapshot.  The snapshot's players wait for their
+// users to rejoin the game.
+func NewGameFromSnapshot(snap *Snapshot) (*Game, error) {
+	g, err := restoreGame(snap)
+	if err != nil {
+		return nil, err
+	}
+
+	go g.Loop()
+	return g, nil
+}
+
+// Builds a game from a snapshot without starting its loop
+func restoreGame(snap *Snapshot) (*Game, error) {
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w %d (expected %d)", ErrSnapshotVersion, snap.Version, SnapshotVersion)
+	}
+
+	b := snap.Board
+	if b.W <= 0 || b.H <= 0 || len(b.Elements) < b.W*b.H {
+		return nil, fmt.Errorf("%w: board is %dx%d with %d elements", ErrBadSnapshot, b.W, b.H, len(b.Elements))
+	}
+
+	lvl := &Level{
+		Board: Board{
+			Elements: append([]Marker{}, b.Elements...),
+			W:        b.W,
+			H:        b.H,
+		},
+		PlayerI0: b.PlayerI0,
+		PlayerJ0: b.PlayerJ0,
+	}
+
+	g := newGame(lvl, snap.Rules, NewDiceAt(snap.Seed, snap.Draws))
+	g.LevelName = snap.Level
+	g.FrameNum = snap.Frame
+	g.paused = snap.Paused
+	if snap.TickInterval != 0 {
+		g.tickInterval = snap.TickInterval
+		g.pump.Reset(snap.TickInterval)
+	}
+
+	if len(snap.Mobs) > cap(g.Mobs) {
+		g.Mobs = make([]Mob, 0, len(snap.Mobs)+MobSpawnHeadroom)
+	}
+	g.Mobs = g.Mobs[:len(snap.Mobs)]
+
+	var err error
+	for i, ms := range snap.Mobs {
+		m := &g.Mobs[i]
+		*m = Mob{
+			I: ms.I,
+			J: ms.J,
+
+			Stats:   ms.Stats,
+			Effects: restoreEffects(ms.Effects),
+			Type:    MobType(ms.Type),
+
+			MoveTick:   ms.MoveTick,
+			StunTick:   ms.StunTick,
+			SeekTick:   ms.SeekTick,
+			AttackTick: ms.AttackTick,
+			ActionTick: ms.ActionTick,
+
+			Direc: Direction(ms.Direc),
+
+			Event:    MobEvent(ms.Event),
+			State:    ms.State,
+			StateArg: ms.StateArg,
+
+			Aggression:  ms.Aggression,
+			LastTargetI: ms.LastTargetI,
+			LastTargetJ: ms.LastTargetJ,
+
+			Pack:       ms.Pack,
+			PackLeader: ms.PackLeader,
+		}
+
+		if m.Weapon, err = lookupItemTag(ms.Weapon); err != nil {
+			return nil, fmt.Errorf("mob %d: %w", i, err)
+		}
+	}
+
+	// now that every mob exists, resolve references between mobs
+	for i, ms := range snap.Mobs {
+		m := &g.Mobs[i]
+		m.EventCause = g.resolveUnitRef(ms.EventCause)
+		m.Target = g.resolveUnitRef(ms.Target)
+
+		for _, ts := range ms.Threat {
+			m.Threat.Add(g.resolveUnitRef(ts.Unit), ts.Threat)
+		}
+		m.Threat.DecayTick = ms.ThreatDecayTick
+	}
+
+	g.FloorItems = g.FloorItems[:0]
+	for _, fs := range snap.Items {
+		if fs.Item == "" {
+			continue
+		}
+
+		itm, err := lookupItemTag(fs.Item)
+		if err != nil {
+			return nil, err
+		}
+
+		g.FloorItems = append(g.FloorItems, FloorItem{I: fs.I, J: fs.J, Item: itm})
+	}
+
+	g.waiting = make(map[string]*Player)
+	for _, ps := range snap.Players {
+		pl := &Player{
+			I:      ps.I,
+			J:      ps.J,
+			Facing: Direction(ps.Facing),
+
+			Cooldowns: append([]uint64{}, ps.Cooldowns...),
+
+			Stats:   ps.Stats,
+			Effects: restoreEffects(ps.Effects),
+
+			BusyTick:    ps.BusyTick,
+			HealthTick:  ps.HealthTick,
+			SwingRate:   ps.SwingRate,
+			SwingTick:   ps.SwingTick,
+			SwingState:  ps.SwingState,
+			SwingFacing: Direction(ps.SwingFacing),
+
+			Dead:        ps.Dead,
+			Ghost:       ps.Ghost,
+			Killer:      ps.Killer,
+			RespawnTick: ps.RespawnTick,
+
+			MobKills:    ps.MobKills,
+			PlayerKills: ps.PlayerKills,
+			Deaths:      ps.Deaths,
+		}
+
+		for _, r := range ps.Marker {
+			pl.Marker = r
+			break
+		}
+
+		if pl.Weapon, err = lookupItemTag(ps.Weapon); err != nil {
+			return nil, fmt.Errorf("player %s: %w", ps.Name, err)
+		}
+
+		pl.Inventory = []Item{}
+		for _, tag := range ps.Inventory {
+			itm, err := lookupItemTag(tag)
+			if err != nil {
+				return nil, fmt.Errorf("player %s: %w", ps.Name, err)
+			}
+
+			pl.Inventory = append(pl.Inventory, itm)
+		}
+
+		g.waiting[ps.Name] = pl
+	}
+
+	for _, ps := range snap.Players {
+		pl := g.waiting[ps.Name]
+		pl.DuelWith = g.waiting[ps.DuelWith]
+		pl.DuelChallenge = g.waiting[ps.DuelChallenge]
+	}
+
+	return g, nil
+}
+
+// Whether a player from a snapshot is waiting for the user to rejoin
+func (g *Game) IsWaitingFor(name string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.waiting[name] != nil
+}
+
+// Names of the players waiting to rejoin, sorted
+func (g *Game) WaitingPlayers() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var names []string
+	for name := range g.waiting {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Gives a returning user back their player from a snapshot.  Returns nil if
+// no player is waiting for the user.
+//
+// Assumes the write lock is held
+func (g *Game) rejoinPlayer(sess Session) (*Player, error) {
+	name := sess.UserName()
+	pl := g.waiting[name]
+	if pl == nil {
+		return nil, nil
+	}
+
+	if other := g.Markers[pl.Marker]; other != nil || pl.Marker == 0 {
+		marker, err := g.pickMarker(name)
+		if err != nil {
+			return nil, err
+		}
+
+		pl.Marker = marker
+	}
+
+	delete(g.waiting, name)
+
+	pl.S = sess
+	g.Players[name] = pl
+	g.Markers[pl.Marker] = pl
+
+	g.Active = append(g.Active, sess)
+	g.messagef(chat.Info, "%s (%c) rejoined the game!", name, pl.Marker)
+
+	return pl, nil
+}
+
+// Restores a saved game into the lobby
+func (l *Lobby) RestoreGame(path string) (*Game, error) {
+	snap, err := LoadSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := NewGameFromSnapshot(snap)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.Games = append(l.Games, g)
+	l.mu.Unlock()
+
+	return g, nil
+}
+
+// Game with a player waiting for the user, if any: a player restored from
+// a snapshot, or a linkdead player
+func (l *Lobby) waitingGame(name string) *Game {
+	l.mu.Lock()
+	games := append([]*Game{}, l.Games...)
+	l.mu.Unlock()
+
+	for _, g := range games {
+		if g.IsWaitingFor(name) || g.IsLinkdead(name) {
+			return g
+		}
+	}
+
+	return nil
+}
+
+func registerSnapshotCommands(r *CommandRegistry) {
+	r.MustRegister(&Command{
+		Name:       "save",
+		Args:       []ArgSpec{{Name: "file"}},
+		Help:       "Saves a snapshot of the game",
+		Permission: PermAdmin,
+		NeedsGame:  true,
+		Run: func(ctx *CommandContext) error {
+			path, err := commandFilePath(SnapshotDir, ctx.Arg("file"))
+			if err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(SnapshotDir, 0755); err != nil {
+				return err
+			}
+
+			snap := ctx.Game.Snapshot()
+			if err := snap.Save(path); err != nil {
+				return err
+			}
+
+			ctx.Reply(chat.Info, "Saved frame %d (%d players, %d mobs) to %s",
+				snap.Frame, len(snap.Players), len(snap.Mobs), path)
+			return nil
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:       "restore",
+		Args:       []ArgSpec{{Name: "file"}},
+		Help:       "Restores a saved game; its players rejoin it when they start a game",
+		Permission: PermAdmin,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			path, err := commandFilePath(SnapshotDir, ctx.Arg("file"))
+			if err != nil {
+				return err
+			}
+
+			g, err := ctx.Lobby.RestoreGame(path)
+			if err != nil {
+				return err
+			}
+
+			c := g.Clock()
+			ctx.Reply(chat.Info, "Restored game at frame %d", c.Frame)
+			if names := g.WaitingPlayers(); len(names) > 0 {
+				ctx.Reply(chat.Info, "Waiting for: %s", strings.Join(names, ", "))
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,184 @@
+package mpnethack
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/sfstewman/mpnethack/chat"
+)
+
+var (
+	ErrSpectating        = errors.New("spectators can't do that")
+	ErrAlreadySpectating = errors.New("already spectating")
+	ErrNotSpectating     = errors.New("not spectating")
+	ErrSpectatorChat     = errors.New("spectators can't chat in this game")
+	ErrCannotSpectate    = errors.New("this session can't spectate")
+	ErrAlreadyInGame     = errors.New("already in a game")
+)
+
+// Session that can watch a game without playing.  A spectating session's
+// Game is the game it watches, and its Player is nil.
+type Spectator interface {
+	Session
+
+	// Starts watching the game.  follow names the player the session's
+	// camera starts on, and may be empty.
+	Spectate(g *Game, follow string) error
+
+	// Stops watching the game
+	StopSpectating() error
+}
+
+// Adds a session that watches the game without a player
+func (g *Game) AddSpectator(sess Session) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.isSpectator(sess) {
+		return ErrAlreadySpectating
+	}
+
+	for _, active := range g.Active {
+		if active == sess {
+			return ErrAlreadyInGame
+		}
+	}
+
+	g.Spectators = append(g.Spectators, sess)
+	g.messagef(chat.Info, "%s is watching the game", sess.UserName())
+
+	return nil
+}
+
+func (g *Game) RemoveSpectator(sess Session) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.isSpectator(sess) {
+		return ErrNotSpectating
+	}
+
+	g.Spectators = removeSession(g.Spectators, sess)
+	g.messagef(chat.Info, "%s stopped watching the game", sess.UserName())
+
+	return nil
+}
+
+func (g *Game) IsSpectator(sess Session) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.isSpectator(sess)
+}
+
+// Assumes the lock is held (either read or write)
+func (g *Game) isSpectator(sess Session) bool {
+	for _, s := range g.Spectators {
+		if s == sess {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Names of the players in the game, sorted
+func (g *Game) PlayerNames() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	names := make([]string, 0, len(g.Players))
+	for name := range g.Players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Sends chat from a session to everyone in the game.  Spectators may only
+// chat if the game's rules allow it.
+func (g *Game) Say(sess Session, txt string) error {
+	if sess.Player() != nil {
+		return g.Input(chat.Chat, fmt.Sprintf("%s: %s", sess.UserName(), txt))
+	}
+
+	if !g.Rules.SpectatorChat {
+		return ErrSpectatorChat
+	}
+
+	return g.Input(chat.Chat, fmt.Sprintf("%s (spectating): %s", sess.UserName(), txt))
+}
+
+// Starts the session watching the game that the named player is in
+func (l *Lobby) Spectate(sess Session, name string) error {
+	if sess.Game() != nil {
+		return ErrAlreadyInGame
+	}
+
+	sp, ok := sess.(Spectator)
+	if !ok {
+		return ErrCannotSpectate
+	}
+
+	g, pl, err := l.findPlayer(name)
+	if err != nil {
+		return err
+	}
+
+	if err := sp.Spectate(g, pl.Name()); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.removeSession(sess)
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Stops the session watching its game, and returns it to the lobby
+func (l *Lobby) StopSpectating(sess Session) error {
+	sp, ok := sess.(Spectator)
+	if !ok {
+		return ErrNotSpectating
+	}
+
+	if err := sp.StopSpectating(); err != nil {
+		return err
+	}
+
+	l.AddSession(sess)
+	return nil
+}
+
+func registerSpectatorCommands(r *CommandRegistry) {
+	r.MustRegister(&Command{
+		Name:    "spectate",
+		Aliases: []string{"watch"},
+		Args:    []ArgSpec{{Name: "player"}},
+		Help:    "Watches the game a player is in",
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			return ctx.Lobby.Spectate(ctx.Session, ctx.Arg("player"))
+		},
+	})
+
+	r.MustRegister(&Command{
+		Name:      "unspectate",
+		Aliases:   []string{"unwatch"},
+		Help:      "Stops watching a game and returns to the lobby",
+		NeedsGame: true,
+		Run: func(ctx *CommandContext) error {
+			if ctx.Lobby == nil {
+				return ErrNoLobby
+			}
+
+			return ctx.Lobby.StopSpectating(ctx.Session)
+		},
+	})
+}
@@ -0,0 +1,351 @@
+package mpnethack
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/sfstewman/mpnethack/chat"
+	"github.com/sfstewman/mpnethack/config"
+)
+
+type StatusEffectType int
+
+const (
+	NoStatusEffect StatusEffectType = iota
+	StatusPoison
+	StatusStun
+	StatusRegen
+	StatusHaste
+	StatusMapping
+)
+
+func (st StatusEffectType) String() string {
+	switch st {
+	case NoStatusEffect:
+		return "none"
+	case StatusPoison:
+		return "poison"
+	case StatusStun:
+		return "stun"
+	case StatusRegen:
+		return "regen"
+	case StatusHaste:
+		return "haste"
+	case StatusMapping:
+		return "mapping"
+	default:
+		return fmt.Sprintf("status_%d", int(st))
+	}
+}
+
+func (st StatusEffectType) MarshalText() ([]byte, error) {
+	return []byte(st.String()), nil
+}
+
+func (st *StatusEffectType) UnmarshalText(text []byte) error {
+	s := string(text)
+	switch s {
+	case "none":
+		*st = NoStatusEffect
+	case "poison":
+		*st = StatusPoison
+	case "stun":
+		*st = StatusStun
+	case "regen":
+		*st = StatusRegen
+	case "haste":
+		*st = StatusHaste
+	case "mapping":
+		*st = StatusMapping
+	default:
+		return fmt.Errorf("unknown status effect \"%s\"", s)
+	}
+
+	return nil
+}
+
+// How a status effect combines with an active effect of the same type
+//
+// Refresh   - duration is reset to the longer of the two durations
+// Extend    - durations are added together
+// Intensify - duration is reset and a stack is added, up to MaxStacks
+//
+// Intensified effects keep their magnitude; tick effects multiply it by the
+// number of stacks.
+type StackRule int
+
+const (
+	StackRefresh StackRule = iota
+	StackExtend
+	StackIntensify
+)
+
+// Changes to a unit's stats while a status effect is active
+type StatModifiers struct {
+	ArmorClass int
+	THAC0      int
+
+	// Extra ticks counted off cooldowns and movement timers each tick
+	Speed int
+}
+
+func (m *StatModifiers) Add(other StatModifiers) {
+	m.ArmorClass += other.ArmorClass
+	m.THAC0 += other.THAC0
+	m.Speed += other.Speed
+}
+
+type StatusEffectInfo struct {
+	Type StatusEffectType
+
+	// Shown in the status frame and in messages ("X is poisoned")
+	Name      string
+	Adjective string
+
+	Stacking  StackRule
+	MaxStacks int
+
+	Modifiers StatModifiers
+
+	// OnTick is called every TickRate ticks while the effect is active
+	TickRate int16
+	OnTick   func(g *Game, u Unit, eff *StatusEffect)
+}
+
+var statusEffectTypes = map[StatusEffectType]*StatusEffectInfo{
+	StatusPoison: &StatusEffectInfo{
+		Type:      StatusPoison,
+		Name:      "Poison",
+		Adjective: "poisoned",
+		Stacking:  StackIntensify,
+		MaxStacks: 3,
+		TickRate:  10,
+		OnTick:    poisonTick,
+	},
+	StatusStun: &StatusEffectInfo{
+		Type:      StatusStun,
+		Name:      "Stun",
+		Adjective: "stunned",
+		Stacking:  StackRefresh,
+		MaxStacks: 1,
+		Modifiers: StatModifiers{ArmorClass: 4},
+	},
+	StatusRegen: &StatusEffectInfo{
+		Type:      StatusRegen,
+		Name:      "Regen",
+		Adjective: "regenerating",
+		Stacking:  StackExtend,
+		MaxStacks: 1,
+		TickRate:  10,
+		OnTick:    regenTick,
+	},
+	StatusHaste: &StatusEffectInfo{
+		Type:      StatusHaste,
+		Name:      "Haste",
+		Adjective: "hasted",
+		Stacking:  StackRefresh,
+		MaxStacks: 1,
+		Modifiers: StatModifiers{Speed: 1},
+	},
+	StatusMapping: &StatusEffectInfo{
+		Type:      StatusMapping,
+		Name:      "Mapping",
+		Adjective: "clairvoyant",
+		Stacking:  StackExtend,
+		MaxStacks: 1,
+	},
+}
+
+func LookupStatusEffectInfo(st StatusEffectType) (*StatusEffectInfo, error) {
+	info := statusEffectTypes[st]
+	if info == nil {
+		return nil, fmt.Errorf("invalid status effect type %v", st)
+	}
+
+	return info, nil
+}
+
+func poisonTick(g *Game, u Unit, eff *StatusEffect) {
+	dmg := eff.Magnitude * eff.Stacks
+	u.TakeDamage(dmg, nil)
+	g.messagef(chat.Game, "%s takes %d damage from poison", u.Name(), dmg)
+
+	if pl, ok := u.(*Player); ok && !pl.IsAlive() {
+		pl.Killer = "poison"
+	}
+}
+
+func regenTick(g *Game, u Unit, eff *StatusEffect) {
+	stats := u.GetStats()
+	if stats.HP < stats.MaxHP {
+		stats.HP = MinInt(stats.HP+eff.Magnitude, stats.MaxHP)
+	}
+}
+
+// Describes a status effect applied by an item
+type StatusEffectSpec struct {
+	Type      StatusEffectType
+	Duration  int16
+	Magnitude int
+
+	// Effect is applied if a d20 roll is at most Chance.  Zero means the
+	// effect is always applied.
+	Chance int
+}
+
+func (spec *StatusEffectSpec) UnmarshalTOML(data interface{}) error {
+	*spec = StatusEffectSpec{}
+	return config.UnmarshalHelper(data, map[string]interface{}{
+		"effect":    &spec.Type,
+		"duration":  &spec.Duration,
+		"magnitude": &spec.Magnitude,
+		"chance":    &spec.Chance,
+	}, config.NoFlags)
+}
+
+func (spec *StatusEffectSpec) IsValid() bool {
+	return spec.Type != NoStatusEffect && spec.Duration > 0
+}
+
+// Rolls the spec's chance
+func (spec *StatusEffectSpec) Roll(d Dice) bool {
+	return spec.Chance <= 0 || d.RollD20() <= spec.Chance
+}
+
+type StatusEffect struct {
+	Type      StatusEffectType
+	Remaining int16
+	Tick      int16
+	Stacks    int
+	Magnitude int
+}
+
+type StatusEffects []StatusEffect
+
+func (effs StatusEffects) Find(st StatusEffectType) *StatusEffect {
+	for i := range effs {
+		if effs[i].Type == st {
+			return &effs[i]
+		}
+	}
+
+	return nil
+}
+
+func (effs StatusEffects) Has(st StatusEffectType) bool {
+	return effs.Find(st) != nil
+}
+
+func (effs StatusEffects) Modifiers() StatModifiers {
+	var mods StatModifiers
+	for i := range effs {
+		info, err := LookupStatusEffectInfo(effs[i].Type)
+		if err != nil {
+			continue
+		}
+
+		mods.Add(info.Modifiers)
+	}
+
+	return mods
+}
+
+// Stats of the unit with status effect modifiers applied
+func EffectiveStats(u Unit) UnitStats {
+	stats := *u.GetStats()
+	mods := u.GetStatusEffects().Modifiers()
+
+	stats.ArmorClass += mods.ArmorClass
+	stats.THAC0 += mods.THAC0
+
+	return stats
+}
+
+// Assumes the write lock is held
+func (g *Game) applyStatusEffect(u Unit, spec StatusEffectSpec, cause Unit) {
+	if !spec.IsValid() || !u.IsAlive() {
+		return
+	}
+
+	info, err := LookupStatusEffectInfo(spec.Type)
+	if err != nil {
+		log.Printf("error applying status effect to %s: %v", u.Name(), err)
+		return
+	}
+
+	effs := u.GetStatusEffects()
+	if eff := effs.Find(spec.Type); eff != nil {
+		switch info.Stacking {
+		case StackRefresh:
+			if spec.Duration > eff.Remaining {
+				eff.Remaining = spec.Duration
+			}
+
+		case StackExtend:
+			eff.Remaining += spec.Duration
+
+		case StackIntensify:
+			eff.Remaining = spec.Duration
+			if eff.Stacks < info.MaxStacks {
+				eff.Stacks++
+			}
+		}
+	} else {
+		*effs = append(*effs, StatusEffect{
+			Type:      spec.Type,
+			Remaining: spec.Duration,
+			Tick:      info.TickRate,
+			Stacks:    1,
+			Magnitude: spec.Magnitude,
+		})
+
+		g.messagef(chat.Game, "%s is %s!", u.Name(), info.Adjective)
+	}
+
+	if spec.Type == StatusStun {
+		if mob, ok := u.(*Mob); ok {
+			if spec.Duration > mob.StunTick {
+				mob.StunTick = spec.Duration
+			}
+
+			mob.Event = MobEventStunned
+			mob.EventCause = cause
+			mob.Threat.Add(cause, ThreatPerAttack)
+		}
+	}
+}
+
+// Assumes the write lock is held
+func (g *Game) updateStatusEffects(u Unit) {
+	effs := u.GetStatusEffects()
+	if len(*effs) == 0 {
+		return
+	}
+
+	kept := (*effs)[:0]
+	for _, eff := range *effs {
+		info, err := LookupStatusEffectInfo(eff.Type)
+		if err != nil {
+			continue
+		}
+
+		if info.OnTick != nil && info.TickRate > 0 {
+			if eff.Tick--; eff.Tick <= 0 {
+				eff.Tick = info.TickRate
+				info.OnTick(g, u, &eff)
+			}
+		}
+
+		if !u.IsAlive() {
+			continue
+		}
+
+		if eff.Remaining--; eff.Remaining > 0 {
+			kept = append(kept, eff)
+		} else {
+			g.messagef(chat.Game, "%s is no longer %s.", u.Name(), info.Adjective)
+		}
+	}
+
+	*effs = kept
+}
@@ -0,0 +1,143 @@
+package mpnethack
+
+import (
+	"testing"
+)
+
+func TestStatusEffectStacking(t *testing.T) {
+	g, alice := newTestGame(t)
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+	pl := alice.pl
+
+	tests := []struct {
+		name  string
+		specs []StatusEffectSpec
+
+		remaining int16
+		stacks    int
+		magnitude int
+	}{
+		// refresh keeps the longer duration
+		{"refresh", []StatusEffectSpec{
+			{Type: StatusStun, Duration: 10},
+			{Type: StatusStun, Duration: 5},
+		}, 10, 1, 0},
+		{"refresh longer", []StatusEffectSpec{
+			{Type: StatusStun, Duration: 10},
+			{Type: StatusStun, Duration: 20},
+		}, 20, 1, 0},
+
+		// extend adds the durations
+		{"extend", []StatusEffectSpec{
+			{Type: StatusRegen, Duration: 10, Magnitude: 1},
+			{Type: StatusRegen, Duration: 5, Magnitude: 3},
+		}, 15, 1, 1},
+
+		// intensify resets the duration and adds stacks, up to MaxStacks
+		{"intensify", []StatusEffectSpec{
+			{Type: StatusPoison, Duration: 30, Magnitude: 2},
+			{Type: StatusPoison, Duration: 20, Magnitude: 5},
+		}, 20, 2, 2},
+		{"intensify capped", []StatusEffectSpec{
+			{Type: StatusPoison, Duration: 30, Magnitude: 2},
+			{Type: StatusPoison, Duration: 30, Magnitude: 2},
+			{Type: StatusPoison, Duration: 30, Magnitude: 2},
+			{Type: StatusPoison, Duration: 40, Magnitude: 2},
+		}, 40, 3, 2},
+	}
+
+	for _, tc := range tests {
+		pl.Effects = nil
+		for _, spec := range tc.specs {
+			g.applyStatusEffect(pl, spec, nil)
+		}
+
+		if len(pl.Effects) != 1 {
+			t.Errorf("%s: expected one effect, found %+v", tc.name, pl.Effects)
+			continue
+		}
+
+		eff := pl.Effects[0]
+		if eff.Remaining != tc.remaining || eff.Stacks != tc.stacks || eff.Magnitude != tc.magnitude {
+			t.Errorf("%s: expected remaining %d, stacks %d, magnitude %d; found %+v",
+				tc.name, tc.remaining, tc.stacks, tc.magnitude, eff)
+		}
+	}
+}
+
+func TestStatusEffectTicks(t *testing.T) {
+	g, alice := newTestGame(t)
+
+	var err error
+	if alice.pl, err = g.PlayerJoin(alice); err != nil {
+		t.Fatalf("error joining game: %v", err)
+	}
+	pl := alice.pl
+
+	poison := statusEffectTypes[StatusPoison]
+
+	// poison does magnitude times stacks damage every TickRate ticks
+	pl.Effects = nil
+	pl.Stats.HP, pl.Stats.MaxHP = 100, 100
+	for k := 0; k < 3; k++ {
+		g.applyStatusEffect(pl, StatusEffectSpec{Type: StatusPoison, Duration: 50, Magnitude: 2}, nil)
+	}
+
+	for k := int16(1); k < poison.TickRate; k++ {
+		g.updateStatusEffects(pl)
+	}
+
+	if pl.Stats.HP != 100 {
+		t.Errorf("expected no damage before the first tick, found %d hp", pl.Stats.HP)
+	}
+
+	g.updateStatusEffects(pl)
+	if pl.Stats.HP != 94 {
+		t.Errorf("expected 3 stacks of 2 damage, found %d hp", pl.Stats.HP)
+	}
+
+	if eff := pl.Effects.Find(StatusPoison); eff == nil || eff.Remaining != 50-poison.TickRate {
+		t.Errorf("expected %d ticks of poison left, found %+v", 50-poison.TickRate, eff)
+	}
+
+	// regen heals up to max hp
+	pl.Effects = nil
+	pl.Stats.HP = 95
+	g.applyStatusEffect(pl, StatusEffectSpec{Type: StatusRegen, Duration: 100, Magnitude: 4}, nil)
+
+	regen := statusEffectTypes[StatusRegen]
+	for k := int16(0); k < regen.TickRate; k++ {
+		g.updateStatusEffects(pl)
+	}
+
+	if pl.Stats.HP != 99 {
+		t.Errorf("expected regen to heal 4, found %d hp", pl.Stats.HP)
+	}
+
+	for k := int16(0); k < regen.TickRate; k++ {
+		g.updateStatusEffects(pl)
+	}
+
+	if pl.Stats.HP != 100 {
+		t.Errorf("expected regen to stop at max hp, found %d hp", pl.Stats.HP)
+	}
+
+	// effects wear off
+	pl.Effects = nil
+	g.applyStatusEffect(pl, StatusEffectSpec{Type: StatusStun, Duration: 3}, nil)
+	for k := 0; k < 3; k++ {
+		if !pl.Effects.Has(StatusStun) {
+			t.Fatalf("expected the stun to last 3 ticks, but it ended after %d", k)
+		}
+
+		g.updateStatusEffects(pl)
+	}
+
+	if pl.Effects.Has(StatusStun) {
+		t.Errorf("expected the stun to wear off")
+	}
+}
@@ -11,8 +11,9 @@ import (
 
 func LoadItems(db *DB, r io.Reader) error {
 	var configItems struct {
-		Items   []mpnethack.BasicItem   `toml:"items"`
-		Weapons []mpnethack.MeleeWeapon `toml:"weapons"`
+		Items       []mpnethack.BasicItem   `toml:"items"`
+		Weapons     []mpnethack.MeleeWeapon `toml:"weapons"`
+		Consumables []mpnethack.Consumable  `toml:"consumables"`
 	}
 
 	dec := toml.NewDecoder(r)
@@ -42,5 +43,16 @@ func LoadItems(db *DB, r io.Reader) error {
 		}
 	}
 
+	for i := range configItems.Consumables {
+		itm := &configItems.Consumables[i]
+
+		err := db.addItem(itm)
+		if err != nil {
+			log.Printf("Error adding consumable \"%s\" to store: %v", itm.Tag(), err)
+		} else {
+			log.Printf("Added consumable %+v[\"%s\"] to db store", itm.Id(), itm.Tag())
+		}
+	}
+
 	return nil
 }
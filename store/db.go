@@ -12,7 +12,7 @@ import (
 
 type DB struct {
 	// db *bolt.DB
-	// map[string]*mpnethack.Player
+	path string
 
 	mobs   map[string]mpnethack.MobType
 	levels map[string]*mpnethack.Level
@@ -20,6 +20,15 @@ type DB struct {
 	lastItemId mpnethack.ItemId
 	items      map[string]mpnethack.Item
 
+	players map[string]mpnethack.PlayerRecord
+
+	// player records are written by writeLoop, so saving a record doesn't
+	// wait on the disk
+	writeCh   chan struct{}
+	writeDone chan struct{}
+	closed    bool
+	writeMu   sync.Mutex
+
 	mu sync.RWMutex
 }
 
@@ -33,13 +42,26 @@ func Open(path string) (*DB, error) {
 	//db, err := bolt.Open(path,
 
 	db := &DB{
+		path: path,
+
 		mobs:   make(map[string]mpnethack.MobType),
 		levels: make(map[string]*mpnethack.Level),
 
 		lastItemId: FirstItemId,
 		items:      make(map[string]mpnethack.Item),
+
+		players: make(map[string]mpnethack.PlayerRecord),
+
+		writeCh:   make(chan struct{}, 1),
+		writeDone: make(chan struct{}),
 	}
 
+	if err := db.loadPlayers(); err != nil {
+		return nil, err
+	}
+
+	go db.writeLoop()
+
 	return db, nil
 }
 
@@ -86,12 +108,6 @@ func (db *DB) addItem(item mpnethack.Item) error {
 	return nil
 }
 
-/*
-func (db *DB) LookupPlayer(session *mpnethack.Session, name string) (*mpnethack.Player, error) {
-	return nil, nil
-}
-*/
-
 func (db *DB) LookupMob(name string) (mpnethack.MobType, error) {
 	return 0, nil
 }
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/sfstewman/mpnethack"
+)
+
+type storedData struct {
+	Players map[string]mpnethack.PlayerRecord `json:"players"`
+}
+
+// Assumes the lock is held or the db is not yet shared
+func (db *DB) loadPlayers() error {
+	if db.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(db.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("error reading store \"%s\": %w", db.path, err)
+	}
+
+	var stored storedData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("error decoding store \"%s\": %w", db.path, err)
+	}
+
+	for name, rec := range stored.Players {
+		db.players[name] = rec
+	}
+
+	return nil
+}
+
+// Writes the player records to the store file
+func (db *DB) savePlayers() error {
+	if db.path == "" {
+		return nil
+	}
+
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+
+	db.mu.RLock()
+	data, err := json.MarshalIndent(storedData{Players: db.players}, "", "  ")
+	db.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("error encoding store: %w", err)
+	}
+
+	// write to a temporary file and rename it so a crash can't leave a
+	// partially written store behind
+	tmpPath := db.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("error writing store \"%s\": %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, db.path); err != nil {
+		return fmt.Errorf("error replacing store \"%s\": %w", db.path, err)
+	}
+
+	return nil
+}
+
+// Writes the store whenever records change.  Saves that arrive during a
+// write are coalesced into the next one.
+func (db *DB) writeLoop() {
+	defer close(db.writeDone)
+
+	for range db.writeCh {
+		if err := db.savePlayers(); err != nil {
+			log.Printf("error saving players: %v", err)
+		}
+	}
+}
+
+// Stops the background writer, and writes any records it hadn't written yet
+func (db *DB) Close() error {
+	db.mu.Lock()
+	if !db.closed {
+		db.closed = true
+		close(db.writeCh)
+	}
+	db.mu.Unlock()
+
+	<-db.writeDone
+
+	return db.savePlayers()
+}
+
+func (db *DB) LookupPlayer(name string) (*mpnethack.PlayerRecord, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rec, ok := db.players[name]
+	if !ok {
+		return nil, nil
+	}
+
+	return &rec, nil
+}
+
+// Updates a player record.  The store file is written in the background.
+func (db *DB) SavePlayer(rec *mpnethack.PlayerRecord) error {
+	db.mu.Lock()
+	db.players[rec.Name] = *rec
+	closed := db.closed
+
+	// the writer picks up every record changed before it runs, so one
+	// pending signal is enough
+	if !closed {
+		select {
+		case db.writeCh <- struct{}{}:
+		default:
+		}
+	}
+	db.mu.Unlock()
+
+	if closed {
+		return db.savePlayers()
+	}
+
+	return nil
+}
+
+// Lists the stored player records, sorted by name
+func (db *DB) ListPlayers() ([]mpnethack.PlayerRecord, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	recs := make([]mpnethack.PlayerRecord, 0, len(db.players))
+	for _, rec := range db.players {
+		recs = append(recs, rec)
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].Name < recs[j].Name
+	})
+
+	return recs, nil
+}
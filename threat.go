@@ -0,0 +1,174 @@
+package mpnethack
+
+const (
+	// Threat added per point of damage dealt to a mob
+	ThreatPerDamage = 10
+
+	// Threat added for an attack that misses
+	ThreatPerAttack = 5
+
+	// Threat added each tick a unit on the threat table stays next to the
+	// mob
+	ThreatPerProximityTick = 1
+
+	// Every ThreatDecayTicks ticks, threat drops by ThreatDecayPercent
+	// percent (and by at least one)
+	ThreatDecayTicks   = 10
+	ThreatDecayPercent = 10
+
+	// A mob only switches to a new target when the new target's threat is
+	// this percentage of the current target's threat.  This keeps mobs from
+	// bouncing between attackers with similar threat.
+	ThreatSwitchPercent = 110
+)
+
+type ThreatEntry struct {
+	Unit   Unit
+	Threat int
+}
+
+// Threat that units have built up against a mob
+type ThreatTable struct {
+	Entries   []ThreatEntry
+	DecayTick int16
+}
+
+func (tt *ThreatTable) find(u Unit) *ThreatEntry {
+	for i := range tt.Entries {
+		if tt.Entries[i].Unit == u {
+			return &tt.Entries[i]
+		}
+	}
+
+	return nil
+}
+
+func (tt *ThreatTable) Add(u Unit, threat int) {
+	if u == nil || threat <= 0 {
+		return
+	}
+
+	if ent := tt.find(u); ent != nil {
+		ent.Threat += threat
+		return
+	}
+
+	tt.Entries = append(tt.Entries, ThreatEntry{Unit: u, Threat: threat})
+}
+
+// Threat of the unit, or zero if the unit isn't on the table
+func (tt *ThreatTable) Of(u Unit) int {
+	if ent := tt.find(u); ent != nil {
+		return ent.Threat
+	}
+
+	return 0
+}
+
+func (tt *ThreatTable) Has(u Unit) bool {
+	return tt.find(u) != nil
+}
+
+func (tt *ThreatTable) Remove(u Unit) {
+	for i := range tt.Entries {
+		if tt.Entries[i].Unit == u {
+			tt.Entries = append(tt.Entries[:i], tt.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (tt *ThreatTable) Clear() {
+	tt.Entries = nil
+	tt.DecayTick = 0
+}
+
+// Living unit with the most threat, or nil if the table is empty
+func (tt *ThreatTable) Top() Unit {
+	var top *ThreatEntry
+	for i := range tt.Entries {
+		ent := &tt.Entries[i]
+		if !ent.Unit.IsAlive() {
+			continue
+		}
+
+		if top == nil || ent.Threat > top.Threat {
+			top = ent
+		}
+	}
+
+	if top == nil {
+		return nil
+	}
+
+	return top.Unit
+}
+
+// Decays threat and removes units that are dead or have no threat left.
+// Called once per tick.
+func (tt *ThreatTable) Update() {
+	if len(tt.Entries) == 0 {
+		tt.DecayTick = 0
+		return
+	}
+
+	decay := false
+	if tt.DecayTick--; tt.DecayTick <= 0 {
+		tt.DecayTick = ThreatDecayTicks
+		decay = true
+	}
+
+	kept := tt.Entries[:0]
+	for _, ent := range tt.Entries {
+		if !ent.Unit.IsAlive() {
+			continue
+		}
+
+		if decay {
+			ent.Threat -= MaxInt(1, ent.Threat*ThreatDecayPercent/100)
+		}
+
+		if ent.Threat > 0 {
+			kept = append(kept, ent)
+		}
+	}
+
+	tt.Entries = kept
+}
+
+// Adds proximity threat for units on the mob's threat table that are next to
+// the mob
+//
+// Assumes the write lock is held
+func (g *Game) updateThreat(mob *Mob) {
+	mob.Threat.Update()
+
+	for i := range mob.Threat.Entries {
+		ent := &mob.Threat.Entries[i]
+
+		ui, uj, _, _ := ent.Unit.GetPos()
+		di := ui - mob.I
+		dj := uj - mob.J
+		if di*di+dj*dj <= 2 {
+			ent.Threat += ThreatPerProximityTick
+		}
+	}
+}
+
+// Removes a unit from every mob's threat table and target
+//
+// Assumes the write lock is held
+func (g *Game) forgetUnit(u Unit) {
+	for i := range g.Mobs {
+		mob := &g.Mobs[i]
+		mob.Threat.Remove(u)
+
+		if mob.Target == u {
+			mob.Target = nil
+		}
+
+		if mob.EventCause == u {
+			mob.EventCause = nil
+		}
+	}
+}
@@ -53,6 +53,9 @@ type IOScreenConfig struct {
 	Width     int
 	Height    int
 	TrueColor bool
+
+	// Character set the terminal displays; empty means UTF-8
+	Charset string
 }
 
 // NewIOScreen returns a Screen that uses the terminfo description given by the
@@ -117,15 +120,20 @@ func NewIOScreenFromTty(tty tcell.Tty, cfg IOScreenConfig) (*IOScreen, error) {
 			terminfo.AddTerminfo(ti)
 		}
 	*/
-	ti, e := terminfo.LookupTerminfo(cfg.Term)
+	ti, e := lookupTerminfo(cfg.Term)
 	if e != nil {
 		return nil, e
 	}
 
+	if cfg.TrueColor {
+		ti = withRGB(ti)
+	}
+
 	t := &IOScreen{ti: ti, tty: tty}
 	t.w = cfg.Width
 	t.h = cfg.Height
 	t.truecolor = cfg.TrueColor
+	t.charset = cfg.Charset
 
 	t.keyexist = make(map[tcell.Key]bool)
 	t.keycodes = make(map[string]*tKeyCode)
@@ -139,6 +147,9 @@ func NewIOScreenFromTty(tty tcell.Tty, cfg IOScreenConfig) (*IOScreen, error) {
 	for k, v := range tcell.RuneFallbacks {
 		t.fallback[k] = v
 	}
+	for k, v := range ASCIIFallbacks {
+		t.fallback[k] = v
+	}
 
 	return t, nil
 }
@@ -219,7 +230,9 @@ func (t *IOScreen) Init() error {
 	t.evch = make(chan tcell.Event, 10)
 	t.keychan = make(chan []byte, 10)
 	t.keytimer = time.NewTimer(time.Millisecond * 50)
-	t.charset = "UTF-8"
+	if t.charset == "" {
+		t.charset = DefaultCharset
+	}
 
 	// t.charset = getCharset()
 	if enc := tcell.GetEncoding(t.charset); enc != nil {
@@ -30,6 +30,10 @@ func (fr *ItemFrame) Draw(screen tcell.Screen) {
 
 	session := fr.UI.Session
 	player := session.Player()
+	if player == nil {
+		// spectators have no items
+		return
+	}
 
 	ymax := y0 + h
 	y := y0
@@ -48,4 +52,26 @@ func (fr *ItemFrame) Draw(screen tcell.Screen) {
 		// ... HANDLE BETTER ...
 		return
 	}
+
+	g := session.Game()
+	if g == nil {
+		return
+	}
+
+	g.RLock()
+	defer g.RUnlock()
+
+	for i, itm := range player.Inventory {
+		s := fmt.Sprintf("%d) %s", i+1, tview.Escape(itm.ShortName()))
+		if i >= 9 {
+			s = fmt.Sprintf("   %s", tview.Escape(itm.ShortName()))
+		}
+
+		tview.Print(screen, s, x0, y, w, tview.AlignLeft, tcell.ColorDefault)
+
+		if y++; y >= ymax {
+			// ... HANDLE BETTER ...
+			return
+		}
+	}
 }
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -14,6 +15,12 @@ type MapArea struct {
 	Session mpnethack.Session
 
 	first bool
+
+	// Spectator camera: follows the player named follow, or stays at
+	// camI,camJ if follow is empty
+	follow     string
+	camI, camJ int
+	camSet     bool
 }
 
 func NewMapArea(session mpnethack.Session /* session *Session */ /* ui *UI */) *MapArea {
@@ -30,9 +37,10 @@ func NewMapArea(session mpnethack.Session /* session *Session */ /* ui *UI */) *
 }
 
 const (
-	VoidChar   rune = '\u2591'
-	BorderChar rune = '\u2580'
-	CactusChar rune = '%' // '\U0001F335'
+	VoidChar      rune = '\u2591'
+	BorderChar    rune = '\u2580'
+	CactusChar    rune = '%' // '\U0001F335'
+	FloorItemChar rune = '*'
 )
 
 func (m *MapArea) Draw(screen tcell.Screen) {
@@ -57,15 +65,32 @@ func (m *MapArea) Draw(screen tcell.Screen) {
 	players := g.Players
 	mobs := g.Mobs
 	effects := g.EffectsOverlay
+	floorItems := g.FloorItems
+
+	var plI, plJ int
 
 	pl := session.Player()
-	if pl.S == nil {
-		tview.Print(screen, "[red:white]No user[-:-]", x0, ctrY, w, tview.AlignCenter, tcell.ColorDefault)
-		return
-	}
+	if pl == nil {
+		plI, plJ = m.camera(g)
+	} else {
+		if pl.S == nil {
+			tview.Print(screen, "[red:white]No user[-:-]", x0, ctrY, w, tview.AlignCenter, tcell.ColorDefault)
+			return
+		}
+
+		if pl.Dead && !pl.Ghost {
+			m.drawDeathScreen(screen, pl, g.Rules.AllowGhosts)
+			return
+		}
 
-	plI := pl.I
-	plJ := pl.J
+		if pl.Effects.Has(mpnethack.StatusMapping) {
+			m.drawOverview(screen, g)
+			return
+		}
+
+		plI = pl.I
+		plJ = pl.J
+	}
 
 	deltaI := h/2 - plI
 	deltaJ := w/2 - plJ
@@ -144,25 +169,49 @@ func (m *MapArea) Draw(screen tcell.Screen) {
 			numVoid, numEmpty, numBorder, numWall, size))
 	}
 
+	floorItemStyle := defaultStyle.Foreground(tcell.ColorYellow)
+	for _, fi := range floorItems {
+		x := x0 + fi.J + deltaJ
+		y := y0 + fi.I + deltaI
+
+		if x >= x0 && x < (x0+w) && y >= y0 && y < (y0+h) {
+			screen.SetContent(x, y, FloorItemChar, nil, floorItemStyle)
+		}
+	}
+
 	playerStyle := tcell.StyleDefault.
 		Background(tcell.ColorBlue).
 		Foreground(tcell.ColorWhite)
-	for _, pl := range players {
-		x := x0 + pl.J + deltaJ
-		y := y0 + pl.I + deltaI
 
-		ch := pl.Marker
+	ghostStyle := tcell.StyleDefault.
+		Background(tcell.ColorGray).
+		Foreground(tcell.ColorWhite)
+	for _, other := range players {
+		// only the ghost itself can see a dead player
+		if other.Dead && other != pl {
+			continue
+		}
+
+		x := x0 + other.J + deltaJ
+		y := y0 + other.I + deltaI
+
+		ch := other.Marker
 		if ch == 0 {
 			ch = '@'
 		}
 
+		sty := playerStyle
+		if other.Dead {
+			sty = ghostStyle
+		}
+
 		if x >= x0 && x < (x0+w) && y >= y0 && y < (y0+h) { // m.InRect(x, y) {
-			screen.SetContent(x, y, ch, nil, playerStyle)
+			screen.SetContent(x, y, ch, nil, sty)
 		}
 
 		if m.first {
 			session.Message(chat.System, fmt.Sprintf("player (%d,%d) x=%d, y=%d, marker=\"%c\"",
-				pl.J, pl.I, x, y, ch))
+				other.J, other.I, x, y, ch))
 		}
 	}
 
@@ -215,5 +264,207 @@ func (m *MapArea) Draw(screen tcell.Screen) {
 		}
 	}
 
+	if pl == nil {
+		s := "[black:yellow]SPECTATING - free camera[-:-]"
+		if m.follow != "" {
+			s = fmt.Sprintf("[black:yellow]SPECTATING %s[-:-]", tview.Escape(m.follow))
+		}
+		tview.Print(screen, s, x0, y0, w, tview.AlignCenter, tcell.ColorDefault)
+	} else if pl.Dead {
+		secs := int(math.Ceil(pl.RespawnIn().Seconds()))
+		s := fmt.Sprintf("[white:gray]GHOST - respawn in %ds[-:-]", secs)
+		tview.Print(screen, s, x0, y0, w, tview.AlignCenter, tcell.ColorDefault)
+	}
+
 	m.first = false
 }
+
+// Cells the spectator camera moves for each pan
+const SpectatorPanStep = 4
+
+// Points the spectator camera at a player
+func (m *MapArea) FollowPlayer(name string) {
+	m.follow = name
+}
+
+// Player the spectator camera follows, or "" for a free camera
+func (m *MapArea) Following() string {
+	return m.follow
+}
+
+// Moves the spectator camera, which stops following a player
+func (m *MapArea) Pan(di, dj int) {
+	m.follow = ""
+	m.camI += di * SpectatorPanStep
+	m.camJ += dj * SpectatorPanStep
+}
+
+// Follows the next (step > 0) or previous (step < 0) player in the game
+func (m *MapArea) FollowNext(g *mpnethack.Game, step int) {
+	names := g.PlayerNames()
+	if len(names) == 0 {
+		return
+	}
+
+	ind := -1
+	for i, name := range names {
+		if name == m.follow {
+			ind = i
+			break
+		}
+	}
+
+	switch {
+	case ind < 0 && step < 0:
+		ind = len(names) - 1
+	case ind < 0:
+		ind = 0
+	default:
+		ind = (ind + step%len(names) + len(names)) % len(names)
+	}
+
+	m.follow = names[ind]
+}
+
+// Where the spectator camera points.  A camera following a player who has
+// left the game stays where the player was.
+//
+// Assumes the game lock is held
+func (m *MapArea) camera(g *mpnethack.Game) (int, int) {
+	lvl := g.Level
+
+	if m.follow != "" {
+		if pl := g.Players[m.follow]; pl != nil {
+			m.camI, m.camJ = pl.I, pl.J
+			m.camSet = true
+		} else {
+			m.follow = ""
+		}
+	}
+
+	if !m.camSet {
+		m.camI, m.camJ = lvl.PlayerI0, lvl.PlayerJ0
+		m.camSet = true
+	}
+
+	m.camI = mpnethack.ClipCoord(m.camI, 0, lvl.H)
+	m.camJ = mpnethack.ClipCoord(m.camJ, 0, lvl.W)
+
+	return m.camI, m.camJ
+}
+
+func (m *MapArea) drawDeathScreen(screen tcell.Screen, pl *mpnethack.Player, allowGhosts bool) {
+	x0, y0, w, h := m.GetInnerRect()
+
+	secs := int(math.Ceil(pl.RespawnIn().Seconds()))
+	lines := []string{
+		"[red::b]You have died[-:-:-]",
+		"",
+		fmt.Sprintf("Killed by %s", tview.Escape(pl.Killer)),
+		"",
+		fmt.Sprintf("Respawning in %d seconds", secs),
+	}
+
+	if allowGhosts {
+		lines = append(lines, "", "Press 'g' to watch as a ghost")
+	}
+
+	y := y0 + (h-len(lines))/2
+	for _, line := range lines {
+		if y >= y0 && y < y0+h {
+			tview.Print(screen, line, x0, y, w, tview.AlignCenter, tcell.ColorWhite)
+		}
+		y++
+	}
+}
+
+// Draws the entire level scaled down to fit in the map area.  Each screen
+// cell shows the most interesting thing in the block of the level it covers.
+//
+// Assumes the game lock is held
+func (m *MapArea) drawOverview(screen tcell.Screen, g *mpnethack.Game) {
+	x0, y0, w, h := m.GetInnerRect()
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	lvl := g.Level
+
+	// level cells per screen cell
+	si := (lvl.H + h - 1) / h
+	sj := (lvl.W + w - 1) / w
+
+	type cell struct {
+		ch   rune
+		sty  tcell.Style
+		rank int
+	}
+
+	defaultStyle := tcell.StyleDefault.
+		Background(tview.Styles.PrimitiveBackgroundColor).
+		Foreground(tcell.ColorWhite)
+
+	nh := (lvl.H + si - 1) / si
+	nw := (lvl.W + sj - 1) / sj
+	cells := make([]cell, nh*nw)
+
+	mark := func(i, j int, ch rune, sty tcell.Style, rank int) {
+		if i < 0 || j < 0 || i >= lvl.H || j >= lvl.W {
+			return
+		}
+
+		c := &cells[(i/si)*nw+(j/sj)]
+		if rank > c.rank {
+			*c = cell{ch: ch, sty: sty, rank: rank}
+		}
+	}
+
+	for i := 0; i < lvl.H; i++ {
+		for j := 0; j < lvl.W; j++ {
+			switch lvl.Get(i, j) {
+			case mpnethack.MarkerVoid:
+				mark(i, j, '.', defaultStyle, 1)
+			case mpnethack.MarkerEmpty:
+				mark(i, j, ' ', defaultStyle, 2)
+			case mpnethack.MarkerBorder, mpnethack.MarkerWall:
+				mark(i, j, BorderChar, defaultStyle, 3)
+			case mpnethack.MarkerCactus:
+				mark(i, j, CactusChar, defaultStyle.Foreground(tcell.ColorGreen), 4)
+			}
+		}
+	}
+
+	for _, fi := range g.FloorItems {
+		mark(fi.I, fi.J, FloorItemChar, defaultStyle.Foreground(tcell.ColorYellow), 5)
+	}
+
+	mobStyle := tcell.StyleDefault.
+		Background(tcell.ColorRed).
+		Foreground(tcell.ColorWhite)
+	for i := range g.Mobs {
+		mob := &g.Mobs[i]
+		if mob.IsAlive() {
+			mark(mob.I, mob.J, mob.GetMarker(), mobStyle, 6)
+		}
+	}
+
+	playerStyle := tcell.StyleDefault.
+		Background(tcell.ColorBlue).
+		Foreground(tcell.ColorWhite)
+	for _, pl := range g.Players {
+		if !pl.Dead {
+			mark(pl.I, pl.J, pl.Marker, playerStyle, 7)
+		}
+	}
+
+	for ci := 0; ci < nh && ci < h; ci++ {
+		for cj := 0; cj < nw && cj < w; cj++ {
+			c := cells[ci*nw+cj]
+			if c.rank > 0 {
+				screen.SetContent(x0+cj, y0+ci, c.ch, nil, c.sty)
+			}
+		}
+	}
+
+	tview.Print(screen, "[black:yellow]MAGIC MAPPING[-:-]", x0, y0, w, tview.AlignCenter, tcell.ColorDefault)
+}
@@ -3,6 +3,8 @@ package tui
 import (
 	"fmt"
 	"log"
+	"math"
+	"time"
 
 	tcell "github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -39,7 +41,14 @@ func (fr *StatusFrame) Draw(screen tcell.Screen) {
 	ymax := y0 + h
 	y := y0
 
-	s := fmt.Sprintf("[:]%s %s[-:-:-]", session.UserName(), adminStr)
+	clockStr := ""
+	if g := session.Game(); g != nil {
+		if lbl := g.Clock().Label(); lbl != "" {
+			clockStr = "[yellow::b]" + lbl
+		}
+	}
+
+	s := fmt.Sprintf("[:]%s %s[-:-:-] %s[-:-:-]", session.UserName(), adminStr, clockStr)
 	if w > 0 && h > 0 {
 		tview.Print(screen, s, x0, y, w, tview.AlignCenter, tcell.ColorDefault)
 	}
@@ -57,6 +66,11 @@ func (fr *StatusFrame) Draw(screen tcell.Screen) {
 	}
 
 	g := session.Game()
+	if pl == nil {
+		fr.drawSpectator(screen, g, y)
+		return
+	}
+
 	fr.cooldowns = g.GetCooldowns(session, fr.cooldowns)
 	cooldowns := fr.cooldowns
 
@@ -90,6 +104,8 @@ func (fr *StatusFrame) Draw(screen tcell.Screen) {
 
 		case mpnethack.Defend:
 			s = "DEF"
+		case mpnethack.Use:
+			s = "USE"
 		default:
 			s = fmt.Sprintf("[%d]", int(act))
 		}
@@ -154,7 +170,31 @@ func (fr *StatusFrame) Draw(screen tcell.Screen) {
 		if stats.HP < 3 {
 			tag = "[red:]"
 		}
-		tview.Print(screen, fmt.Sprintf("%sHealth %d[-:-]", tag, stats.HP), x0, y, w, tview.AlignLeft, tcell.ColorWhite)
+
+		s := fmt.Sprintf("%sHealth %d[-:-]", tag, stats.HP)
+		if pl.Dead {
+			secs := int(math.Ceil(pl.RespawnIn().Seconds()))
+			s = fmt.Sprintf("[red:]DEAD[-:-] respawn %ds", secs)
+		}
+		tview.Print(screen, s, x0, y, w, tview.AlignLeft, tcell.ColorWhite)
+
+		if y++; y >= ymax {
+			// ... handle better ...
+			return
+		}
+
+		tview.Print(screen, fmt.Sprintf("Level %d", stats.Level), x0, y, w, tview.AlignLeft, tcell.ColorWhite)
+
+		if y++; y >= ymax {
+			// ... handle better ...
+			return
+		}
+
+		xpStr := fmt.Sprintf("XP %d", stats.XP)
+		if next := mpnethack.NextLevelXP(stats.Level); next > 0 {
+			xpStr = fmt.Sprintf("XP %d/%d", stats.XP, next)
+		}
+		tview.Print(screen, xpStr, x0, y, w, tview.AlignLeft, tcell.ColorWhite)
 
 		if y++; y >= ymax {
 			// ... handle better ...
@@ -169,4 +209,62 @@ func (fr *StatusFrame) Draw(screen tcell.Screen) {
 		return
 	}
 
+	for _, eff := range pl.Effects {
+		info, err := mpnethack.LookupStatusEffectInfo(eff.Type)
+		if err != nil {
+			continue
+		}
+
+		name := info.Name
+		if eff.Stacks > 1 {
+			name = fmt.Sprintf("%s x%d", name, eff.Stacks)
+		}
+
+		secs := int(math.Ceil((time.Duration(eff.Remaining) * mpnethack.GameRefreshInterval).Seconds()))
+		tview.Print(screen, fmt.Sprintf("[yellow:]%s[-:-] %ds", name, secs), x0, y, w, tview.AlignLeft, tcell.ColorWhite)
+
+		if y++; y >= ymax {
+			// ... HANDLE BETTER ...
+			return
+		}
+	}
+
+}
+
+// Draws the status of a spectator, starting at line y
+func (fr *StatusFrame) drawSpectator(screen tcell.Screen, g *mpnethack.Game, y int) {
+	x0, y0, w, h := fr.GetInnerRect()
+	ymax := y0 + h
+
+	lines := []string{"[yellow::b]SPECTATING[-:-:-]"}
+
+	if name := fr.UI.Map.Following(); name == "" {
+		lines = append(lines, "Free camera")
+	} else {
+		lines = append(lines, fmt.Sprintf("Following %s", tview.Escape(name)))
+
+		g.RLock()
+		if pl := g.Players[name]; pl != nil {
+			stats := pl.GetStats()
+			lines = append(lines,
+				fmt.Sprintf("Health %d/%d", stats.HP, stats.MaxHP),
+				fmt.Sprintf("Level %d", stats.Level))
+		}
+		g.RUnlock()
+	}
+
+	lines = append(lines,
+		"",
+		"[gray]Tab/n: next player[-]",
+		"[gray]arrows: pan camera[-]",
+		"[gray]/unspectate: leave[-]")
+
+	for _, line := range lines {
+		if y >= ymax {
+			return
+		}
+
+		tview.Print(screen, line, x0, y, w, tview.AlignLeft, tcell.ColorWhite)
+		y++
+	}
 }
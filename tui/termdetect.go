@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/gdamore/tcell/v2/encoding"
+	"github.com/gdamore/tcell/v2/terminfo"
+
+	"github.com/sfstewman/mpnethack"
+)
+
+// Terminals tried, in order, when a client's terminal isn't in the terminfo
+// database
+var FallbackTerms = []string{"xterm-256color", "xterm", "vt100"}
+
+// Returns term if the terminfo database knows it, or else the first
+// fallback terminal it knows.  Reports whether it fell back.
+func ResolveTerm(term string) (string, bool) {
+	if _, err := terminfo.LookupTerminfo(term); err == nil {
+		return term, false
+	}
+
+	for _, fb := range FallbackTerms {
+		if _, err := terminfo.LookupTerminfo(fb); err == nil {
+			return fb, true
+		}
+	}
+
+	return term, false
+}
+
+// Reports whether a terminal shows 24-bit color, going by the COLORTERM
+// variable the client sent and the terminal's terminfo entry
+func DetectTrueColor(term string, colorterm string) bool {
+	switch strings.ToLower(colorterm) {
+	case "truecolor", "24bit":
+		return true
+	}
+
+	ti, err := terminfo.LookupTerminfo(term)
+	return err == nil && hasRGB(ti)
+}
+
+func hasRGB(ti *terminfo.Terminfo) bool {
+	return ti.SetFgBgRGB != "" || ti.SetFgRGB != "" || ti.SetBgRGB != ""
+}
+
+// Looks up a terminal's terminfo entry, falling back to FallbackTerms if
+// the terminal is unknown
+func lookupTerminfo(term string) (*terminfo.Terminfo, error) {
+	term, _ = ResolveTerm(term)
+	return terminfo.LookupTerminfo(term)
+}
+
+// Copy of a terminfo entry with the usual 24-bit color sequences, for
+// terminals that claim truecolor through COLORTERM but whose entries lack
+// them
+func withRGB(ti *terminfo.Terminfo) *terminfo.Terminfo {
+	if hasRGB(ti) {
+		return ti
+	}
+
+	rgb := *ti
+	rgb.SetFgRGB = "\x1b[38;2;%p1%d;%p2%d;%p3%dm"
+	rgb.SetBgRGB = "\x1b[48;2;%p1%d;%p2%d;%p3%dm"
+	rgb.SetFgBgRGB = "\x1b[38;2;%p1%d;%p2%d;%p3%d;48;2;%p4%d;%p5%d;%p6%dm"
+
+	return &rgb
+}
+
+// Charset used when a client's locale doesn't name one
+const DefaultCharset = "UTF-8"
+
+// Charset named by a locale such as "en_US.UTF-8".  "C" and "POSIX" mean
+// US-ASCII, and an empty locale means DefaultCharset.
+func LocaleCharset(locale string) string {
+	switch locale {
+	case "":
+		return DefaultCharset
+	case "C", "POSIX":
+		return "US-ASCII"
+	}
+
+	if ind := strings.IndexByte(locale, '@'); ind >= 0 {
+		locale = locale[:ind]
+	}
+
+	ind := strings.IndexByte(locale, '.')
+	if ind < 0 {
+		return DefaultCharset
+	}
+
+	return locale[ind+1:]
+}
+
+// Charset for a client's locale variables, which are checked in the order
+// the C library checks them.  Charsets IOScreen can't encode fall back to
+// US-ASCII.
+func CharsetFromEnv(env map[string]string) string {
+	locale := env["LC_ALL"]
+	if locale == "" {
+		locale = env["LC_CTYPE"]
+	}
+	if locale == "" {
+		locale = env["LANG"]
+	}
+
+	charset := LocaleCharset(locale)
+	if tcell.GetEncoding(charset) == nil {
+		return "US-ASCII"
+	}
+
+	return charset
+}
+
+// ASCII stand-ins for the player markers in mpnethack.PlayerTokens that
+// aren't ASCII, in order.  None of them may be a player token or a map
+// fallback.
+const asciiPlayerTokens = "0$&-?^~<>{}[]|/\\:;()"
+
+// ASCII stand-ins for runes the map draws, used when the client's charset
+// can't show them
+var ASCIIFallbacks = map[rune]string{
+	VoidChar:   ".",
+	BorderChar: "=",
+}
+
+func init() {
+	encoding.Register()
+
+	k := 0
+	for _, r := range mpnethack.PlayerTokens {
+		if r < utf8.RuneSelf || k >= len(asciiPlayerTokens) {
+			continue
+		}
+
+		ASCIIFallbacks[r] = asciiPlayerTokens[k : k+1]
+		k++
+	}
+}
@@ -0,0 +1,127 @@
+package tui
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sfstewman/mpnethack"
+)
+
+func TestResolveTerm(t *testing.T) {
+	tests := []struct {
+		term     string
+		expected string
+		fellBack bool
+	}{
+		{"xterm", "xterm", false},
+		{"vt100", "vt100", false},
+		{"xterm-256color", "xterm-256color", false},
+		{"no-such-terminal", FallbackTerms[0], true},
+		{"", FallbackTerms[0], true},
+	}
+
+	for _, tc := range tests {
+		term, fellBack := ResolveTerm(tc.term)
+		if term != tc.expected || fellBack != tc.fellBack {
+			t.Errorf("%q: expected (%s, %v) but found (%s, %v)", tc.term, tc.expected, tc.fellBack, term, fellBack)
+		}
+	}
+}
+
+func TestDetectTrueColor(t *testing.T) {
+	tests := []struct {
+		term      string
+		colorterm string
+		expected  bool
+	}{
+		{"xterm-256color", "", false},
+		{"xterm-256color", "truecolor", true},
+		{"vt100", "24bit", true},
+		{"vt100", "TrueColor", true},
+		{"xterm", "yes", false},
+		{"xterm-direct", "", true},
+		{"no-such-terminal", "", false},
+	}
+
+	for _, tc := range tests {
+		if got := DetectTrueColor(tc.term, tc.colorterm); got != tc.expected {
+			t.Errorf("(%q, %q): expected %v but found %v", tc.term, tc.colorterm, tc.expected, got)
+		}
+	}
+}
+
+func TestLocaleCharset(t *testing.T) {
+	tests := []struct {
+		locale   string
+		expected string
+	}{
+		{"", DefaultCharset},
+		{"C", "US-ASCII"},
+		{"POSIX", "US-ASCII"},
+		{"en_US.UTF-8", "UTF-8"},
+		{"en_US.UTF-8@euro", "UTF-8"},
+		{"de_DE.ISO-8859-1", "ISO-8859-1"},
+		{"de_DE@euro", DefaultCharset},
+		{"en_US", DefaultCharset},
+	}
+
+	for _, tc := range tests {
+		if got := LocaleCharset(tc.locale); got != tc.expected {
+			t.Errorf("%q: expected %s but found %s", tc.locale, tc.expected, got)
+		}
+	}
+}
+
+func TestCharsetFromEnv(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{nil, DefaultCharset},
+		{map[string]string{"LANG": "C"}, "US-ASCII"},
+		{map[string]string{"LANG": "en_US.UTF-8@euro"}, "UTF-8"},
+		{map[string]string{"LANG": "de_DE.ISO-8859-1"}, "ISO-8859-1"},
+
+		// LC_ALL overrides LC_CTYPE, which overrides LANG
+		{map[string]string{"LANG": "en_US.UTF-8", "LC_CTYPE": "C"}, "US-ASCII"},
+		{map[string]string{"LANG": "C", "LC_CTYPE": "C", "LC_ALL": "en_US.UTF-8"}, "UTF-8"},
+
+		// charsets tcell can't encode fall back to ASCII
+		{map[string]string{"LANG": "xx_XX.NO-SUCH-CHARSET"}, "US-ASCII"},
+	}
+
+	for _, tc := range tests {
+		if got := CharsetFromEnv(tc.env); got != tc.expected {
+			t.Errorf("%v: expected %s but found %s", tc.env, tc.expected, got)
+		}
+	}
+}
+
+func TestASCIIFallbacks(t *testing.T) {
+	// what the map draws instead of runes an ASCII terminal can't show
+	mapGlyphs := map[string]bool{
+		ASCIIFallbacks[VoidChar]:   true,
+		ASCIIFallbacks[BorderChar]: true,
+	}
+
+	players := map[string]rune{}
+	for _, r := range mpnethack.PlayerTokens {
+		glyph := string(r)
+		if r >= utf8.RuneSelf {
+			var ok bool
+			if glyph, ok = ASCIIFallbacks[r]; !ok {
+				t.Errorf("no ASCII stand-in for player token %q", r)
+				continue
+			}
+		}
+
+		if mapGlyphs[glyph] {
+			t.Errorf("player token %q looks like the map's %q", r, glyph)
+		}
+
+		if prev, ok := players[glyph]; ok {
+			t.Errorf("player tokens %q and %q both look like %q", prev, r, glyph)
+		}
+		players[glyph] = r
+	}
+}
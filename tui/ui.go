@@ -2,6 +2,7 @@ package tui
 
 import (
 	"log"
+	"strings"
 	"sync"
 
 	tcell "github.com/gdamore/tcell/v2"
@@ -209,6 +210,17 @@ func setupAdminPage(ui *UI, sysLog *chat.SystemLog) {
 	ui.AdminLog = adminLog
 	ui.AdminInput = widgets.NewInputArea(adminLog)
 
+	// console input runs commands, with replies going to the admin log
+	adminSess := &adminSession{Session: ui.Session, log: adminLog}
+	ui.AdminInput.ConsoleInputFunc = func(txt string) {
+		if !strings.HasPrefix(txt, "/") {
+			txt = "/" + txt
+		}
+
+		adminLog.LogLine(chat.Admin, "> "+txt)
+		mpnethack.Commands.Dispatch(adminSess, ui.Lobby, txt)
+	}
+
 	ui.AdminInput.DirectKeyFunc = func(e *tcell.EventKey) *tcell.EventKey {
 		k := e.Key()
 		m := e.Modifiers()
@@ -232,6 +244,22 @@ func setupAdminPage(ui *UI, sysLog *chat.SystemLog) {
 	// ui.LogView = logView
 }
 
+// Session used by the admin console, which replies in the admin log rather
+// than the session's game log
+type adminSession struct {
+	mpnethack.Session
+	log *chat.Log
+}
+
+func (s *adminSession) GetLog() *chat.Log {
+	return s.log
+}
+
+func (s *adminSession) Message(lvl chat.MsgLevel, msg string) error {
+	s.log.LogLine(lvl, msg)
+	return nil
+}
+
 func (ui *UI) handleGameKeys(e *tcell.EventKey) *tcell.EventKey {
 	k := e.Key()
 	m := e.Modifiers()
@@ -240,6 +268,10 @@ func (ui *UI) handleGameKeys(e *tcell.EventKey) *tcell.EventKey {
 	s := ui.Session
 	g := s.Game()
 
+	if s.Player() == nil {
+		return ui.handleSpectatorKeys(e)
+	}
+
 	if m == tcell.ModNone {
 		switch k {
 		case tcell.KeyEsc:
@@ -274,8 +306,14 @@ func (ui *UI) handleGameKeys(e *tcell.EventKey) *tcell.EventKey {
 			case 'v', 'z':
 				g.UserAction(s, mpnethack.Defend, 0)
 
-				// case '1', '2', '3', '4', '5':
-				// Special
+			case 'g':
+				if err := g.ToggleGhost(s); err == mpnethack.ErrGhostsDisabled {
+					s.Message(chat.Info, "Ghosts are not allowed in this game")
+				}
+
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				// use the item in the inventory slot
+				g.UserAction(s, mpnethack.Use, int16(r-'1'))
 
 			default:
 				return e
@@ -291,6 +329,62 @@ func (ui *UI) handleGameKeys(e *tcell.EventKey) *tcell.EventKey {
 	return nil
 }
 
+// Keys for a session watching a game: the arrow keys pan the camera, and
+// tab cycles through the players
+func (ui *UI) handleSpectatorKeys(e *tcell.EventKey) *tcell.EventKey {
+	g := ui.Session.Game()
+	if g == nil || e.Modifiers()&^tcell.ModShift != tcell.ModNone {
+		return e
+	}
+
+	switch e.Key() {
+	case tcell.KeyEsc:
+		ui.toggleModal(ModalMenu)
+	case tcell.KeyLeft:
+		ui.Map.Pan(0, -1)
+	case tcell.KeyRight:
+		ui.Map.Pan(0, 1)
+	case tcell.KeyUp:
+		ui.Map.Pan(-1, 0)
+	case tcell.KeyDown:
+		ui.Map.Pan(1, 0)
+	case tcell.KeyTab:
+		ui.Map.FollowNext(g, 1)
+	case tcell.KeyBacktab:
+		ui.Map.FollowNext(g, -1)
+
+	case tcell.KeyRune:
+		switch e.Rune() {
+		case 'w':
+			ui.Map.Pan(-1, 0)
+		case 'a':
+			ui.Map.Pan(0, -1)
+		case 's':
+			ui.Map.Pan(1, 0)
+		case 'd':
+			ui.Map.Pan(0, 1)
+		case 'n':
+			ui.Map.FollowNext(g, 1)
+		case 'p':
+			ui.Map.FollowNext(g, -1)
+		default:
+			return e
+		}
+
+	default:
+		return e
+	}
+
+	return nil
+}
+
+// Shows the game or the lobby, after the session joins or leaves a game
+func (ui *UI) GameChanged() {
+	ui.App.QueueUpdateDraw(func() {
+		ui.showPage(PageMain)
+	})
+}
+
 func (ui *UI) globalKeyHandler(e *tcell.EventKey) *tcell.EventKey {
 	k := e.Key()
 	r := e.Rune()
@@ -362,6 +456,7 @@ type LobbyScreen struct {
 	*tview.Frame
 	Menu *tview.List
 	RHS  *tview.Pages
+	Chat *widgets.InputArea
 
 	GameList *tview.List
 
@@ -398,14 +493,45 @@ func NewLobbyScreen(ui *UI) *LobbyScreen {
 	menu := tview.NewList()
 	rhs := tview.NewPages()
 
+	// lobby chat; the chat takes the focus while a message is typed
+	chatArea := widgets.NewInputArea(ui.Session.GetLog())
+	chatArea.ConsoleInputFunc = ui.Session.ConsoleInput
+	chatArea.DoneFunc = func() {
+		ui.App.SetFocus(menu)
+	}
+	chatArea.SetBorder(true).SetTitle("Chat")
+
 	scr := &LobbyScreen{
-		Frame: tview.NewFrame(flx),
+		Frame: tview.NewFrame(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(flx, 0, 1, true).
+			AddItem(chatArea, 10, 1, false)),
 
 		Menu: menu,
 		RHS:  rhs,
+		Chat: chatArea,
 		UI:   ui,
 	}
 
+	menu.SetInputCapture(func(e *tcell.EventKey) *tcell.EventKey {
+		if e.Key() != tcell.KeyRune || e.Modifiers() != tcell.ModNone {
+			return e
+		}
+
+		switch r := e.Rune(); r {
+		case '/', '`', '~':
+			txt := ""
+			if r == '/' {
+				txt = "/"
+			}
+
+			chatArea.StartConsole(txt)
+			ui.App.SetFocus(chatArea.Input)
+			return nil
+		}
+
+		return e
+	})
+
 	menu.SetBorder(true).SetTitle("Menu")
 	menu.ShowSecondaryText(false).
 		AddItem("New game", "Creates a new game", 'n', scr.newGame).
@@ -422,6 +548,7 @@ func NewLobbyScreen(ui *UI) *LobbyScreen {
 		AddItem(rhs, 0, 1, true)
 
 	scr.AddText("Lobby", true, tview.AlignCenter, tcell.ColorWhite)
+	scr.AddText("Press / to enter a command or ` to chat", false, tview.AlignCenter, tcell.ColorGray)
 
 	return scr
 }
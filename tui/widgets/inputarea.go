@@ -26,6 +26,9 @@ type InputArea struct {
 	DirectKeyFunc    func(e *tcell.EventKey) *tcell.EventKey
 	ConsoleInputFunc func(string)
 
+	// Called when the input area leaves console mode
+	DoneFunc func()
+
 	LastKey    tcell.Key
 	LastMods   tcell.ModMask
 	LastRune   rune
@@ -67,6 +70,20 @@ func (inp *InputArea) Draw(scr tcell.Screen) {
 	}
 }
 
+// Switches the input area into console mode, with the input field holding
+// txt
+func (inp *InputArea) StartConsole(txt string) {
+	inp.InputMode = InputConsole
+	inp.Input.SetText(txt)
+}
+
+func (inp *InputArea) endConsole() {
+	inp.InputMode = InputGame
+	if inp.DoneFunc != nil {
+		inp.DoneFunc()
+	}
+}
+
 func (inp *InputArea) handleConsoleCmd(key tcell.Key) {
 	switch key {
 	case tcell.KeyEnter:
@@ -74,7 +91,7 @@ func (inp *InputArea) handleConsoleCmd(key tcell.Key) {
 		txt := inp.Input.GetText()
 		if txt != "" {
 			inp.Input.SetText("")
-			inp.InputMode = InputGame
+			inp.endConsole()
 
 			if inp.ConsoleInputFunc != nil {
 				inp.ConsoleInputFunc(txt)
@@ -85,7 +102,7 @@ func (inp *InputArea) handleConsoleCmd(key tcell.Key) {
 		}
 
 	case tcell.KeyEsc:
-		inp.InputMode = InputGame
+		inp.endConsole()
 	}
 }
 
@@ -120,16 +137,22 @@ func (inp *InputArea) handleInput(e *tcell.EventKey) *tcell.EventKey {
 			return nil
 		}
 
-		if k == tcell.KeyRune && m == tcell.ModNone && (r == '`' || r == '~' || r == '/') {
-			inp.InputMode = InputConsole
+		if k == tcell.KeyRune && m == tcell.ModNone && (r == '`' || r == '~') {
+			inp.StartConsole("")
+			return nil
+		}
+
+		// slash starts a command, so keep it
+		if k == tcell.KeyRune && m == tcell.ModNone && r == '/' {
+			inp.StartConsole("/")
 			return nil
 		}
 
 	case InputConsole:
 		if k == tcell.KeyEsc && m == tcell.ModNone {
-			inp.InputMode = InputGame
+			inp.endConsole()
 		} else if k == tcell.KeyTab && m == tcell.ModNone {
-			inp.InputMode = InputGame
+			inp.endConsole()
 		}
 	}
 
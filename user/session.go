@@ -41,6 +41,8 @@ type Session struct {
 
 	UI *tui.UI
 
+	Lobby *mpnethack.Lobby
+
 	G *mpnethack.Game
 	P *mpnethack.Player
 
@@ -48,6 +50,9 @@ type Session struct {
 
 	State SessionState
 	Flags SessionFlag
+
+	// Limits actions and chat; nil for no limit
+	Input *mpnethack.InputLimiter
 }
 
 func (s *Session) GetLog() *chat.Log {
@@ -66,6 +71,10 @@ func (s *Session) UserName() string {
 	return s.User
 }
 
+func (s *Session) InputLimiter() *mpnethack.InputLimiter {
+	return s.Input
+}
+
 const SessionGameLogLines = 100
 
 func NewSession(user string, flags SessionFlag) *Session {
@@ -131,15 +140,7 @@ func (s *Session) Defend() {
 }
 
 func (s *Session) ConsoleInput(txt string) {
-	switch {
-	case txt == "":
-		/* nop */
-
-	case txt[0] == '/':
-		s.G.Command(s, txt)
-	default:
-		s.G.Input(chat.Chat, txt)
-	}
+	mpnethack.HandleConsoleInput(s, s.Lobby, txt)
 }
 
 var ErrNoGame = errors.New("game is nil")
@@ -160,6 +161,45 @@ func (s *Session) Join(g *mpnethack.Game) error {
 	return nil
 }
 
+var _ mpnethack.Spectator = &Session{}
+
+func (s *Session) Spectate(g *mpnethack.Game, follow string) error {
+	if s.G != nil {
+		return mpnethack.ErrAlreadyInGame
+	}
+
+	if err := g.AddSpectator(s); err != nil {
+		return err
+	}
+
+	s.G = g
+
+	if s.UI != nil {
+		s.UI.Map.FollowPlayer(follow)
+		s.UI.GameChanged()
+	}
+
+	return nil
+}
+
+func (s *Session) StopSpectating() error {
+	if s.G == nil || s.P != nil {
+		return mpnethack.ErrNotSpectating
+	}
+
+	if err := s.G.RemoveSpectator(s); err != nil {
+		return err
+	}
+
+	s.G = nil
+
+	if s.UI != nil {
+		s.UI.GameChanged()
+	}
+
+	return nil
+}
+
 func (s *Session) Loop() error {
 	s.Screen.Clear()
 